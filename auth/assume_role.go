@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
+	"github.com/aliyun/credentials-go/credentials"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/klog/v2"
+)
+
+const (
+	StsAuthType = "sts"
+	// Lifetime requested for assumed role sessions, in seconds.
+	assumeRoleDurationSeconds = 3600
+	// Assumed role credentials are refreshed once they are this close to expiry.
+	assumeRoleRefreshWindow = 5 * time.Minute
+)
+
+// ErrAssumeRole is returned (wrapped) when a role given in a SecretProviderClass can not be assumed.
+var ErrAssumeRole = errors.New("failed to assume role")
+
+type assumedRole struct {
+	cred       credentials.Credential
+	expiration time.Time
+}
+
+// Assumed role credentials keyed by the caller access key id and the role ARN.
+// The STS calls run outside of mu, a single one at a time per key.
+var assumedRoles = struct {
+	mu    sync.Mutex
+	creds map[string]*assumedRole
+	calls singleflight.Group
+}{creds: make(map[string]*assumedRole)}
+
+// Assume a role with STS, replaced in tests.
+var assumeRoleSTS = assumeRole
+
+// AssumeRole returns credentials for roleArn obtained through STS with the base
+// credential. Credentials are cached per base identity and role until they are
+// close to expiry, so repeated mounts do not call STS every time, and
+// concurrent mounts needing the same role share a single call. Expired
+// credentials are dropped from the cache.
+func AssumeRole(base credentials.Credential, roleArn, region string) (credentials.Credential, error) {
+	accessKeyId, err := base.GetAccessKeyId()
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrAssumeRole, roleArn, err)
+	}
+	key := *accessKeyId + "|" + roleArn
+
+	if cached := cachedAssumedRole(key); cached != nil {
+		return cached.cred, nil
+	}
+	result, err, _ := assumedRoles.calls.Do(key, func() (interface{}, error) {
+		if cached := cachedAssumedRole(key); cached != nil {
+			return cached, nil // Refreshed by a call that just finished
+		}
+		assumed, err := assumeRoleSTS(base, roleArn, region)
+		if err != nil {
+			return nil, err
+		}
+		assumedRoles.mu.Lock()
+		assumedRoles.creds[key] = assumed
+		assumedRoles.mu.Unlock()
+		klog.Info("Assumed role..", "roleArn", roleArn, "expiration", assumed.expiration)
+		return assumed, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrAssumeRole, roleArn, err)
+	}
+	return result.(*assumedRole).cred, nil
+}
+
+// Return the cached credentials of the key unless they are close to expiry,
+// dropping every expired entry of the cache.
+func cachedAssumedRole(key string) *assumedRole {
+	assumedRoles.mu.Lock()
+	defer assumedRoles.mu.Unlock()
+	now := time.Now()
+	for k, cached := range assumedRoles.creds {
+		if !now.Before(cached.expiration) {
+			delete(assumedRoles.creds, k)
+		}
+	}
+	if cached, ok := assumedRoles.creds[key]; ok && cached.expiration.Sub(now) > assumeRoleRefreshWindow {
+		return cached
+	}
+	return nil
+}
+
+func assumeRole(base credentials.Credential, roleArn, region string) (*assumedRole, error) {
+	model, err := base.GetCredential()
+	if err != nil {
+		return nil, err
+	}
+	var client *sts.Client
+	if model.SecurityToken != nil && len(*model.SecurityToken) > 0 {
+		client, err = sts.NewClientWithStsToken(region, *model.AccessKeyId, *model.AccessKeySecret, *model.SecurityToken)
+	} else {
+		client, err = sts.NewClientWithAccessKey(region, *model.AccessKeyId, *model.AccessKeySecret)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	request := sts.CreateAssumeRoleRequest()
+	request.Scheme = "https"
+	request.RoleArn = roleArn
+	request.RoleSessionName = roleSessionName
+	request.DurationSeconds = requests.NewInteger(assumeRoleDurationSeconds)
+	response, err := client.AssumeRole(request)
+	if err != nil {
+		return nil, err
+	}
+	expiration, err := time.Parse(time.RFC3339, response.Credentials.Expiration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiration %q in assume role response", response.Credentials.Expiration)
+	}
+
+	config := new(credentials.Config).
+		SetType(StsAuthType).
+		SetAccessKeyId(response.Credentials.AccessKeyId).
+		SetAccessKeySecret(response.Credentials.AccessKeySecret).
+		SetSecurityToken(response.Credentials.SecurityToken)
+	cred, err := credentials.NewCredential(config)
+	if err != nil {
+		return nil, err
+	}
+	return &assumedRole{cred: cred, expiration: expiration}, nil
+}
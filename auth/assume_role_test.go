@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/credentials-go/credentials"
+)
+
+// Replace STS with assume for the duration of a test, starting with an empty cache.
+func setupAssumeRoleTest(t *testing.T, assume func(roleArn string) (*assumedRole, error)) credentials.Credential {
+	oldAssume := assumeRoleSTS
+	t.Cleanup(func() {
+		assumeRoleSTS = oldAssume
+		assumedRoles.mu.Lock()
+		assumedRoles.creds = make(map[string]*assumedRole)
+		assumedRoles.mu.Unlock()
+	})
+	assumedRoles.mu.Lock()
+	assumedRoles.creds = make(map[string]*assumedRole)
+	assumedRoles.mu.Unlock()
+	assumeRoleSTS = func(_ credentials.Credential, roleArn, _ string) (*assumedRole, error) {
+		return assume(roleArn)
+	}
+	base, err := credentials.NewCredential(new(credentials.Config).SetType(AKAuthType).SetAccessKeyId("ak").SetAccessKeySecret("sk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base
+}
+
+// A stub STS session for roleArn expiring after ttl.
+func stubAssumedRole(t *testing.T, roleArn string, ttl time.Duration) *assumedRole {
+	cred, err := credentials.NewCredential(new(credentials.Config).SetType(StsAuthType).
+		SetAccessKeyId("sts-" + roleArn).SetAccessKeySecret("secret").SetSecurityToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &assumedRole{cred: cred, expiration: time.Now().Add(ttl)}
+}
+
+func TestAssumeRoleCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wantCalls int // STS calls made by 2 mounts of the same role
+	}{
+		{"cached", time.Hour, 1},
+		{"refreshed-near-expiry", 2 * time.Minute, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			base := setupAssumeRoleTest(t, func(roleArn string) (*assumedRole, error) {
+				calls++
+				return stubAssumedRole(t, roleArn, tt.ttl), nil
+			})
+			for i := 0; i < 2; i++ {
+				cred, err := AssumeRole(base, "acs:ram::123:role/app", "cn-hangzhou")
+				if err != nil {
+					t.Fatalf("AssumeRole() error = %v", err)
+				}
+				if id, _ := cred.GetAccessKeyId(); *id != "sts-acs:ram::123:role/app" {
+					t.Errorf("AssumeRole() returned access key id %s", *id)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("made %d STS calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestAssumeRoleFailure(t *testing.T) {
+	calls := 0
+	errDenied := errors.New("NoPermission")
+	base := setupAssumeRoleTest(t, func(string) (*assumedRole, error) {
+		calls++
+		return nil, errDenied
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := AssumeRole(base, "acs:ram::123:role/app", "cn-hangzhou"); !errors.Is(err, ErrAssumeRole) {
+			t.Fatalf("AssumeRole() error = %v, want ErrAssumeRole", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected failures not to be cached, made %d STS calls", calls)
+	}
+}
+
+func TestAssumeRoleEvictsExpired(t *testing.T) {
+	base := setupAssumeRoleTest(t, func(roleArn string) (*assumedRole, error) {
+		if roleArn == "acs:ram::123:role/expired" {
+			return stubAssumedRole(t, roleArn, -time.Second), nil
+		}
+		return stubAssumedRole(t, roleArn, time.Hour), nil
+	})
+	for _, roleArn := range []string{"acs:ram::123:role/expired", "acs:ram::123:role/app"} {
+		if _, err := AssumeRole(base, roleArn, "cn-hangzhou"); err != nil {
+			t.Fatalf("AssumeRole() error = %v", err)
+		}
+	}
+	assumedRoles.mu.Lock()
+	defer assumedRoles.mu.Unlock()
+	if _, ok := assumedRoles.creds["ak|acs:ram::123:role/expired"]; ok || len(assumedRoles.creds) != 1 {
+		t.Errorf("expected only the unexpired role to be cached, got %v", assumedRoles.creds)
+	}
+}
+
+func TestAssumeRoleDoesNotBlockOtherRoles(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var startOnce sync.Once
+	base := setupAssumeRoleTest(t, func(roleArn string) (*assumedRole, error) {
+		if roleArn == "acs:ram::123:role/slow" {
+			startOnce.Do(func() { close(started) })
+			<-release
+		}
+		return stubAssumedRole(t, roleArn, time.Hour), nil
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := AssumeRole(base, "acs:ram::123:role/slow", "cn-hangzhou")
+		done <- err
+	}()
+	<-started
+
+	// Another role is assumed while the STS call of the slow one is in flight.
+	if _, err := AssumeRole(base, "acs:ram::123:role/app", "cn-hangzhou"); err != nil {
+		t.Fatalf("AssumeRole() error = %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("AssumeRole() of the slow role error = %v", err)
+	}
+}
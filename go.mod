@@ -1,6 +1,6 @@
 module github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud
 
-go 1.18
+go 1.19
 
 require (
 	github.com/AliyunContainerService/ack-secret-manager v0.0.0-20220112125214-d31312f5d710
@@ -11,13 +11,16 @@ require (
 	github.com/alibabacloud-go/tea v1.2.2
 	github.com/aliyun/alibaba-cloud-sdk-go v1.61.1473
 	github.com/aliyun/credentials-go v1.3.1
+	github.com/itchyny/gojq v0.12.13
 	github.com/jmespath/go-jmespath v0.0.0-20180206201540-c2b33e8439af
 	github.com/pkg/errors v0.9.1
+	golang.org/x/sync v0.6.0
 	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
 	google.golang.org/grpc v1.29.1
 	k8s.io/klog/v2 v2.8.0
 	sigs.k8s.io/secrets-store-csi-driver v0.0.22
 	sigs.k8s.io/yaml v1.2.0
+	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
 
 require (
@@ -34,6 +37,7 @@ require (
 	github.com/gogo/protobuf v1.3.1 // indirect
 	github.com/golang/protobuf v1.4.3 // indirect
 	github.com/google/gofuzz v1.1.0 // indirect
+	github.com/itchyny/timefmt-go v0.1.5 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -34,6 +35,33 @@ var (
 
 	maxConcurrentKmsSecretPulls = flag.Int("max-concurrent-kms-secret-pulls", 10, "used to control how many kms secrets are pulled at the same time.")
 	maxConcurrentOosSecretPulls = flag.Int("max-concurrent-oos-secret-pulls", 10, "used to control how many oos secrets are pulled at the same time.")
+	maxInFlightSecretPulls      = flag.Int("max-in-flight-secret-pulls", 0, "used to cap how many kms and oos requests are in flight across all mounts, 0 means unlimited.")
+	checkCurrentVersion         = flag.Bool("check-current-version", false, "look up the current version of unpinned kms secrets on rotation and skip fetching unchanged values.")
+	kmsRegionEndpoints          = flag.String("kms-region-endpoints", "", "comma separated list of region=endpoint pairs overriding the kms endpoint of each region.")
+	verifyVersionStages         = flag.Bool("verify-version-stages", false, "check that the objectVersionLabel of kms secrets is one of their version stages before fetching them, reporting the available stages otherwise.")
+	prevalidateSecrets          = flag.Bool("prevalidate-secrets", false, "check that all kms secrets of a mount exist with DescribeSecret before fetching any value.")
+	retryableErrorCodes         = flag.String("retryable-error-codes", "", "comma separated list of additional kms and oos error codes to retry.")
+	maxFilesPerObject           = flag.Int("max-files-per-object", 1000, "maximum number of files a single object may produce, including jmesPath fanOut entries, 0 means unlimited.")
+	maxObjectsPerMount          = flag.Int("max-objects-per-mount", 500, "maximum number of objects a single mount may list, after objectName lists are expanded, 0 means unlimited.")
+	maxFilesPerMount            = flag.Int("max-files-per-mount", 5000, "maximum number of files the objects of a single mount may produce, not counting jmesPath fanOut entries, 0 means unlimited.")
+	localFileSourceDir          = flag.String("local-file-source-dir", "", "directory objects of type file are read from, for development and tests only, empty disables file objects.")
+	verifyMountedFiles          = flag.Bool("verify-mounted-files", false, "check reloaded files against the sha256 of their info file or the manifest file and fetch them again on a mismatch.")
+	disableJMESPath             = flag.Bool("disable-jmespath", false, "reject objects declaring jmesPath entries, so only whole secrets can be mounted.")
+	skipTmpfsCheck              = flag.Bool("skip-tmpfs-check", false, "accept mounts with requireTmpfs without checking the file system of the mount directory.")
+	limiterWaitTimeout          = flag.Duration("limiter-wait-timeout", 30*time.Second, "maximum time to wait for a secret pull rate token before failing the fetch.")
+	batchRetries                = flag.Int("batch-retries", 0, "times a whole mount is fetched again after a transient kms or oos failure, 0 disables batch retries and at most 3 are made.")
+	batchRetryInterval          = flag.Duration("batch-retry-interval", 2*time.Second, "base interval of the exponential backoff between batch retries.")
+	maxRetries                  = flag.Int("max-retries", provider.BACKOFF_DEFAULT_MAX_RETRIES, "times a throttled or unavailable kms or oos request is retried, from 0 to 10, unless the object sets maxRetries.")
+	retryInterval               = flag.Duration("retry-interval", provider.BACKOFF_DEFAULT_RETRY_INTERVAL, "interval doubled before each retry of a kms or oos request, unless the object sets retryInterval.")
+	fetchTimeout                = flag.Duration("fetch-timeout", provider.FETCH_DEFAULT_TIMEOUT, "time allowed for all the requests fetching an object, unless the object sets fetchTimeout.")
+
+	breakerThreshold     = flag.Int("circuit-breaker-threshold", 0, "consecutive kms or oos failures that open the circuit breaker of the backend, 0 disables the breaker.")
+	breakerWindow        = flag.Duration("circuit-breaker-window", time.Minute, "window in which the consecutive failures opening the circuit breaker are counted.")
+	breakerCooldown      = flag.Duration("circuit-breaker-cooldown", 30*time.Second, "time the circuit breaker fails fast before letting a probe request through.")
+	breakerStaleFallback = flag.Bool("circuit-breaker-stale-fallback", false, "keep the mounted value of objects instead of failing the mount while the circuit breaker is open.")
+
+	sharedCacheSize = flag.Int("shared-cache-size", 0, "maximum number of kms and oos values cached and shared by the mounts of the node, 0 disables the shared cache.")
+	sharedCacheTTL  = flag.Duration("shared-cache-ttl", 30*time.Second, "time a value is served from the shared cache before it is fetched again.")
 )
 
 // Main entry point for the Secret Store CSI driver Alibaba Cloud provider. This main
@@ -48,6 +76,41 @@ func main() {
 
 	provider.LimiterInstance.Kms.SecretPullLimiter = rate.NewLimiter(rate.Limit(*maxConcurrentKmsSecretPulls), 1)
 	provider.LimiterInstance.OOS.SecretPullLimiter = rate.NewLimiter(rate.Limit(*maxConcurrentOosSecretPulls), 1)
+	provider.LimiterInstance.InFlight = provider.NewConcurrencyLimiter(*maxInFlightSecretPulls)
+	provider.LimiterWaitTimeout = *limiterWaitTimeout
+	provider.CheckCurrentVersion = *checkCurrentVersion
+	provider.PrevalidateSecrets = *prevalidateSecrets
+	provider.VerifyVersionStages = *verifyVersionStages
+	provider.MaxFilesPerObject = *maxFilesPerObject
+	provider.MaxObjectsPerMount = *maxObjectsPerMount
+	provider.MaxFilesPerMount = *maxFilesPerMount
+	provider.LocalFileSourceDir = *localFileSourceDir
+	provider.VerifyMountedFiles = *verifyMountedFiles
+	provider.DisableJMESPath = *disableJMESPath
+	provider.BatchRetries = *batchRetries
+	provider.BatchRetryInterval = *batchRetryInterval
+	provider.BreakerInstance.Kms = provider.NewCircuitBreaker(provider.ObjectTypeKMS, *breakerThreshold, *breakerWindow, *breakerCooldown)
+	provider.BreakerInstance.OOS = provider.NewCircuitBreaker(provider.ObjectTypeOOS, *breakerThreshold, *breakerWindow, *breakerCooldown)
+	provider.CircuitBreakerStaleFallback = *breakerStaleFallback
+	provider.SharedCacheSize = *sharedCacheSize
+	provider.SharedCacheTTL = *sharedCacheTTL
+	regionEndpoints, err := provider.ParseRegionEndpointMap(*kmsRegionEndpoints)
+	if err != nil {
+		klog.Fatalf("Invalid kms-region-endpoints. error: %v", err)
+	}
+	server.RegionEndpointMap = regionEndpoints
+	if err := provider.ValidateRetryPolicy(*maxRetries, *retryInterval, *fetchTimeout); err != nil {
+		klog.Fatalf("Invalid retry settings. error: %v", err)
+	}
+	server.SkipTmpfsCheck = *skipTmpfsCheck
+	if !*skipTmpfsCheck {
+		if err := provider.CheckTmpfsSupport(); err != nil {
+			klog.Warningf("The tmpfs check is not available, mounts with requireTmpfs will fail. error: %v", err)
+		}
+	}
+	if len(*retryableErrorCodes) > 0 {
+		provider.RetryableErrorCodes = strings.Split(*retryableErrorCodes, ",")
+	}
 
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGTERM, syscall.SIGINT, os.Interrupt)
@@ -79,7 +142,7 @@ func main() {
 		os.Remove(endpoint)
 	}()
 
-	providerSrv, err := server.NewServer()
+	providerSrv, err := server.NewServer(server.WithRetryPolicy(*maxRetries, *retryInterval, *fetchTimeout))
 	if err != nil {
 		klog.Fatalf("Could not create server. error: %v", err)
 	}
@@ -113,4 +176,5 @@ func main() {
 	// gracefully stop the grpc server
 	klog.Infof("terminating the server")
 	providerSrv.GracefulStop()
+	provider.PurgeSharedCache()
 }
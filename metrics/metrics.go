@@ -0,0 +1,45 @@
+// Package metrics exposes counters and states of the provider with expvar.
+// They are served as JSON on /debug/vars of the health check server.
+package metrics
+
+import "expvar"
+
+var (
+	// Retries counts the retried KMS and OOS calls, per backend.
+	Retries = expvar.NewMap("secret_pull_retries")
+
+	// RetriesExhausted counts the calls that still failed with a retryable
+	// error once their retry budget was spent, per backend.
+	RetriesExhausted = expvar.NewMap("secret_pull_retries_exhausted")
+
+	// LimiterTimeouts counts the fetches that gave up waiting for a rate
+	// limiter token, per backend.
+	LimiterTimeouts = expvar.NewMap("limiter_wait_timeouts")
+
+	// StaleFallbacks counts the objects served from their mounted version
+	// while the circuit breaker of their backend was open, per backend.
+	StaleFallbacks = expvar.NewMap("stale_fallbacks")
+
+	// BreakerState reports the state of the circuit breaker of each backend:
+	// closed, open or half-open.
+	BreakerState = expvar.NewMap("circuit_breaker_state")
+
+	// BreakerTrips counts how many times the circuit breaker of each backend opened.
+	BreakerTrips = expvar.NewMap("circuit_breaker_trips")
+
+	// SharedCacheHits and SharedCacheMisses count the fetches served from the
+	// cache shared by the mounts and those sent to the backend, per backend.
+	SharedCacheHits   = expvar.NewMap("shared_cache_hits")
+	SharedCacheMisses = expvar.NewMap("shared_cache_misses")
+
+	// SharedCacheEvictions counts the values dropped from the shared cache
+	// before they expired to stay within its size, per backend.
+	SharedCacheEvictions = expvar.NewMap("shared_cache_evictions")
+)
+
+// SetBreakerState records the current state of the circuit breaker of a backend.
+func SetBreakerState(backend, state string) {
+	v := new(expvar.String)
+	v.Set(state)
+	BreakerState.Set(backend, v)
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrAccessDenied is matched, with errors.Is, by the errors of requests the
+// RAM policy of the credentials does not allow. They are never retried.
+var ErrAccessDenied = errors.New("access denied by the RAM policy")
+
+// Error codes returned by KMS and OOS when the credentials are not allowed to
+// call an action on a resource.
+var accessDeniedErrorCodes = []string{
+	"Forbidden.NoPermission",
+	"Forbidden.RAM",
+	"Forbidden.AccessDenied",
+	"NoPermission",
+	"AccessDenied",
+}
+
+// An access denied error naming the action and resource to grant. It wraps
+// the SDK error, so its error code stays available.
+type accessDeniedError struct {
+	action   string
+	resource string
+	err      error
+}
+
+func (e *accessDeniedError) Error() string {
+	return fmt.Sprintf("Access denied to %s on %s (%s), the RAM policy of the credentials must allow this action on this resource",
+		e.action, e.resource, getErrorCode(e.err))
+}
+
+func (e *accessDeniedError) Unwrap() error {
+	return e.err
+}
+
+func (e *accessDeniedError) Is(target error) bool {
+	return target == ErrAccessDenied
+}
+
+// Report whether the error means the credentials are not allowed to call the action.
+func isAccessDenied(err error) bool {
+	code := getErrorCode(err)
+	for _, denied := range accessDeniedErrorCodes {
+		if code == denied || strings.HasPrefix(code, denied+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Return an access denied error naming the action called for the object, or
+// err unchanged when it is not an access denied error.
+func (p *SecretsManagerProvider) accessDeniedError(err error, action string, secObj *SecretObject) error {
+	if err == nil || !isAccessDenied(err) {
+		return err
+	}
+	return &accessDeniedError{action: action, resource: p.resourceARN(secObj), err: err}
+}
+
+// Return the ARN of the resource of an object as RAM policies name it, with a
+// wildcard account when the objectName is not an ARN.
+func (p *SecretsManagerProvider) resourceARN(secObj *SecretObject) string {
+	if _, ok := secObj.GetARN(); ok {
+		return secObj.ObjectName
+	}
+	region := secObj.getRegion()
+	if len(region) == 0 {
+		region = p.Region
+	}
+	switch secObj.ObjectType {
+	case ObjectTypeOOS:
+		return fmt.Sprintf("acs:oos:%s:*:%s/%s", region, oosParameterResourceType, secObj.ObjectName)
+	case ObjectTypeDataKey:
+		return fmt.Sprintf("acs:kms:%s:*:key/%s", region, secObj.ObjectName)
+	default:
+		return fmt.Sprintf("acs:kms:%s:*:secret/%s", region, secObj.ObjectName)
+	}
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// Upper bound of BatchRetries.
+const maxBatchRetries = 3
+
+// BatchRetries is the number of times a whole mount is fetched again when it
+// fails with a transient error once the retries of the failing call are spent,
+// e.g. while a KMS endpoint is briefly unavailable (0 disables it, at most 3).
+var BatchRetries = 0
+
+// BatchRetryInterval is the base of the exponential backoff between batch retries.
+var BatchRetryInterval = 2 * time.Second
+
+// Fetch the values of a mount, retrying the whole batch up to BatchRetries
+// times on transient errors. A retry that would not finish waiting before the
+// deadline of ctx is not attempted. Each attempt works on a copy of curMap, so
+// a failed attempt does not leave versions of files that were never written,
+// and the map of the successful attempt replaces curMap, entries it dropped
+// included.
+func (p *SecretsManagerProvider) getSecretValues(
+	ctx context.Context,
+	secretObjs []*SecretObject,
+	curMap map[string]*v1alpha1.ObjectVersion,
+) (v []*SecretValue, updated []string, e error) {
+	retries := BatchRetries
+	if retries > maxBatchRetries {
+		retries = maxBatchRetries
+	}
+	if retries <= 0 {
+		return p.getSecretValuesOnce(ctx, secretObjs, curMap)
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptMap := make(map[string]*v1alpha1.ObjectVersion, len(curMap))
+		for id, version := range curMap {
+			attemptMap[id] = version
+		}
+		v, updated, e = p.getSecretValuesOnce(ctx, secretObjs, attemptMap)
+		if e == nil {
+			for id := range curMap {
+				delete(curMap, id)
+			}
+			for id, version := range attemptMap {
+				curMap[id] = version
+			}
+			return v, updated, nil
+		}
+		if attempt > retries || !p.judgeNeedRetry(e) {
+			return nil, nil, e
+		}
+		wait := exponentialWait(BatchRetryInterval, attempt-1)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, nil, e
+		}
+		klog.Warningf("retrying the whole mount after attempt %d: %s", attempt, e.Error())
+		if err := sleep(ctx, wait); err != nil {
+			return nil, nil, e
+		}
+	}
+}
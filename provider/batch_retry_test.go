@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestGetSecretValuesBatchRetry(t *testing.T) {
+	setupFetchTest(t)
+	oldRetries, oldInterval, oldSleep := BatchRetries, BatchRetryInterval, sleep
+	defer func() { BatchRetries, BatchRetryInterval, sleep = oldRetries, oldInterval, oldSleep }()
+	BatchRetryInterval = time.Second
+	var sleeps []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error { sleeps = append(sleeps, d); return nil }
+
+	unavailable := &tea.SDKError{Code: tea.String(SERVICE_UNAVAILABLE_TEMPORARY)}
+	tests := []struct {
+		name        string
+		retries     int
+		failures    int // Failed calls to "b" before it recovers
+		fail        error
+		deadline    time.Duration
+		wantErr     bool
+		wantCalls   int
+		wantBackoff []time.Duration
+	}{
+		{"disabled", 0, 1, unavailable, 0, true, 2, nil},
+		{"recovers", 2, 2, unavailable, 0, false, 6, []time.Duration{time.Second, 2 * time.Second}},
+		{"exhausted", 1, 5, unavailable, 0, true, 4, []time.Duration{time.Second}},
+		{"capped", 10, 10, unavailable, 0, true, 8, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}},
+		{"not-transient", 2, 1, &tea.SDKError{Code: tea.String("Forbidden.NoPermission")}, 0, true, 2, nil},
+		{"deadline", 2, 1, unavailable, 100 * time.Millisecond, true, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			BatchRetries = tt.retries
+			sleeps = nil
+			failures := tt.failures
+			client := &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				if tea.StringValue(request.SecretName) == "b" && failures > 0 {
+					failures--
+					return nil, tt.fail
+				}
+				return kmsSecretResponse("value", "v2"), nil
+			}}
+			p := &SecretsManagerProvider{KmsClient: client, FS: newMemFileSystem()}
+			objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "a", "maxRetries": 0}, {"objectName": "b", "maxRetries": 0}]`, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+			curMap := map[string]*v1alpha1.ObjectVersion{"a": {Id: "a", Version: "v1"}}
+			_, err = p.GetSecretValues(ctx, objects, curMap)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSecretValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("made %d calls, want %d", client.calls, tt.wantCalls)
+			}
+			if len(sleeps) != len(tt.wantBackoff) {
+				t.Fatalf("backoffs = %v, want %v", sleeps, tt.wantBackoff)
+			}
+			for i := range sleeps {
+				if sleeps[i] != tt.wantBackoff[i] {
+					t.Errorf("backoffs = %v, want %v", sleeps, tt.wantBackoff)
+				}
+			}
+			wantVersion := "v2"
+			if tt.wantErr && tt.retries > 0 {
+				wantVersion = "v1" // A failed batch leaves the versions alone
+			}
+			if tt.retries > 0 && curMap["a"].Version != wantVersion {
+				t.Errorf("version of a = %s, want %s", curMap["a"].Version, wantVersion)
+			}
+		})
+	}
+}
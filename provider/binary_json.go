@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+)
+
+// jmesBinary handling of jmesPath strings holding bytes that are not valid UTF-8.
+const (
+	jmesBinaryBase64 = "base64"
+	jmesBinaryFail   = "fail"
+)
+
+// Invalid bytes of a JSON document are mapped to the runes binaryRuneBase+b of
+// a private use plane before parsing, since encoding/json would replace them
+// with U+FFFD, and mapped back to bytes in the search results.
+const binaryRuneBase = 0x10FF00
+
+// Map every byte of b that is not part of a valid UTF-8 sequence to a private
+// use rune, so the bytes survive JSON parsing.
+func escapeInvalidUTF8(b []byte) []byte {
+	escaped := make([]byte, 0, len(b)+len(b)/4)
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			escaped = utf8.AppendRune(escaped, binaryRuneBase+rune(b[0]))
+		} else {
+			escaped = append(escaped, b[:size]...)
+		}
+		b = b[size:]
+	}
+	return escaped
+}
+
+// Return the original bytes of a string parsed from an escaped document, and
+// whether it held any invalid byte.
+func unescapeBinary(s string) ([]byte, bool) {
+	var raw []byte
+	for i, r := range s {
+		if r < binaryRuneBase+0x80 || r > binaryRuneBase+0xFF {
+			if raw != nil {
+				raw = utf8.AppendRune(raw, r)
+			}
+			continue
+		}
+		if raw == nil {
+			raw = append(make([]byte, 0, len(s)), s[:i]...)
+		}
+		raw = append(raw, byte(r-binaryRuneBase))
+	}
+	return raw, raw != nil
+}
+
+// Replace the strings of a search result that held invalid UTF-8 with their
+// base64 encoding, or fail with jmesBinary fail. Objects and arrays are copied,
+// the parsed document is shared by all the entries of the object.
+func (sv *SecretValue) restoreBinary(jmesPathEntry *JMESPathObject, result interface{}) (interface{}, error) {
+	switch v := result.(type) {
+	case string:
+		raw, ok := unescapeBinary(v)
+		if !ok {
+			return v, nil
+		}
+		if sv.SecretObj.JmesBinary == jmesBinaryFail {
+			return nil, fmt.Errorf("JMES Path - %s of object %s resolved to a value that is not valid UTF-8.", jmesPathEntry.Path, sv.SecretObj.ObjectName)
+		}
+		return base64.StdEncoding.EncodeToString(raw), nil
+	case map[string]interface{}:
+		restored := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			var err error
+			keyRaw, binaryKey := unescapeBinary(key)
+			if binaryKey {
+				if sv.SecretObj.JmesBinary == jmesBinaryFail {
+					return nil, fmt.Errorf("JMES Path - %s of object %s resolved to a key that is not valid UTF-8.", jmesPathEntry.Path, sv.SecretObj.ObjectName)
+				}
+				key = base64.StdEncoding.EncodeToString(keyRaw)
+			}
+			if restored[key], err = sv.restoreBinary(jmesPathEntry, element); err != nil {
+				return nil, err
+			}
+		}
+		return restored, nil
+	case []interface{}:
+		restored := make([]interface{}, len(v))
+		for i, element := range v {
+			var err error
+			if restored[i], err = sv.restoreBinary(jmesPathEntry, element); err != nil {
+				return nil, err
+			}
+		}
+		return restored, nil
+	default:
+		return result, nil
+	}
+}
+
+// Evaluate a jmesPath entry of the object against its parsed value.
+func (sv *SecretValue) searchJMES(jmesPathEntry *JMESPathObject) (interface{}, error) {
+	data, err := sv.jsonData()
+	if err != nil {
+		return nil, err
+	}
+	result, err := jmesPathEntry.search(data)
+	if err != nil || !sv.binary {
+		return result, err
+	}
+	if s, ok := result.(string); ok && jmesPathEntry.encodes() {
+		if raw, binary := unescapeBinary(s); binary {
+			return raw, nil // Encoded as requested rather than per jmesBinary
+		}
+	}
+	return sv.restoreBinary(jmesPathEntry, result)
+}
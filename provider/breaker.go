@@ -0,0 +1,205 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/metrics"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// ErrCircuitOpen is returned without calling the backend while its circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open, backend is failing")
+
+// CircuitBreakerStaleFallback keeps serving the mounted value of an object,
+// instead of failing the mount, while the breaker of its backend is open.
+var CircuitBreakerStaleFallback = false
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// CircuitBreaker fast-fails the calls to a backend after threshold consecutive
+// failures within window, for cooldown. It then lets a single probe call
+// through (half-open): a success closes the breaker, a failure opens it again.
+// A nil breaker never opens.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu           sync.Mutex
+	state        string
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	endpoints    map[string]*CircuitBreaker // Breakers of the other endpoints of the backend
+}
+
+// Breakers holds the circuit breaker of each backend.
+type Breakers struct {
+	Kms *CircuitBreaker
+	OOS *CircuitBreaker
+}
+
+var BreakerInstance Breakers
+
+// Return the breaker of a backend, kms or oos.
+func (b Breakers) forBackend(backend string) *CircuitBreaker {
+	if backend == ObjectTypeOOS {
+		return b.OOS
+	}
+	return b.Kms
+}
+
+// Return the breaker of a KMS object: the breaker of the kms backend, or one of
+// the same settings for the kmsEndpoint of the object, so an unreachable
+// endpoint does not fail fast the fetches from its fallback endpoints.
+func (b Breakers) forObject(backend string, secObj *SecretObject) *CircuitBreaker {
+	breaker := b.forBackend(backend)
+	if backend == ObjectTypeOOS || len(secObj.KmsEndpoint) == 0 {
+		return breaker
+	}
+	return breaker.forEndpoint(secObj.KmsEndpoint)
+}
+
+// Return the breaker of an endpoint of the backend, created on first use with
+// the settings of b. A nil breaker has no endpoints.
+func (b *CircuitBreaker) forEndpoint(endpoint string) *CircuitBreaker {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker := b.endpoints[endpoint]
+	if breaker == nil {
+		breaker = NewCircuitBreaker(b.name+":"+endpoint, b.threshold, b.window, b.cooldown)
+		breaker.now = b.now
+		if b.endpoints == nil {
+			b.endpoints = make(map[string]*CircuitBreaker)
+		}
+		b.endpoints[endpoint] = breaker
+	}
+	return breaker
+}
+
+// NewCircuitBreaker returns a breaker for the named backend, or nil (never
+// open) when threshold is not positive.
+func NewCircuitBreaker(name string, threshold int, window, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	metrics.SetBreakerState(name, breakerClosed)
+	return &CircuitBreaker{
+		name:      name,
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     breakerClosed,
+	}
+}
+
+// Allow reports ErrCircuitOpen when the call must not reach the backend.
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.setState(breakerHalfOpen) // Let this call probe the backend.
+		return nil
+	case breakerHalfOpen:
+		return ErrCircuitOpen // A probe is already in flight.
+	}
+	return nil
+}
+
+// Record the outcome of a call let through by Allow.
+func (b *CircuitBreaker) Record(failed bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		b.failures = 0
+		if b.state != breakerClosed {
+			klog.Infof("%s circuit breaker closed", b.name)
+			b.setState(breakerClosed)
+		}
+		return
+	}
+
+	now := b.now()
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.window {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		klog.Warningf("%s circuit breaker opened after %d consecutive failures, failing fast for %s", b.name, b.failures, b.cooldown)
+		b.openedAt = now
+		b.failures = 0
+		b.setState(breakerOpen)
+		metrics.BreakerTrips.Add(b.name, 1)
+	}
+}
+
+func (b *CircuitBreaker) setState(state string) {
+	b.state = state
+	metrics.SetBreakerState(b.name, state)
+}
+
+// Report whether a failed call counts against the breaker of its backend.
+// Only errors pointing at the backend itself do: retryable service codes, such
+// as throttling and unavailability, and network errors. A missing secret or a
+// denied permission means the backend is answering, and a cancelled or expired
+// context or a local rate limiter timeout says nothing about the backend.
+func (smp *SecretsManagerProvider) isBackendFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrLimiterTimeout) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || smp.judgeNeedRetry(err)
+}
+
+// Reload the mounted value of an object whose fetch failed because the breaker
+// of its backend is open, when CircuitBreakerStaleFallback is enabled. Returns
+// nil when there is nothing to fall back to.
+func (p *SecretsManagerProvider) staleSecret(secObj *SecretObject, curMap map[string]*v1alpha1.ObjectVersion, err error) (*SecretValue, string) {
+	if !CircuitBreakerStaleFallback || !errors.Is(err, ErrCircuitOpen) {
+		return nil, ""
+	}
+	curVer := curMap[secObj.GetFileName()]
+	if curVer == nil || secObj.missingSecretType(curMap) {
+		return nil, ""
+	}
+	staleObj := secObj.withVersion(curVer.Version)
+	secret, reloadErr := p.reloadSecret(&staleObj)
+	if reloadErr != nil {
+		return nil, ""
+	}
+	secret.SecretType, _ = secObj.recordedSecretType(curMap)
+	klog.Warningf("serving the mounted version %s of %s while its backend is failing", curVer.Version, secObj.ObjectName)
+	backend := ObjectTypeKMS
+	if secObj.ObjectType == ObjectTypeOOS {
+		backend = ObjectTypeOOS
+	}
+	metrics.StaleFallbacks.Add(backend, 1)
+	return secret, curVer.Version
+}
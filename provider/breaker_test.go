@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/metrics"
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// Install a breaker with a fake clock on the kms backend for the duration of a test.
+func setupBreakerTest(t *testing.T, threshold int) (*CircuitBreaker, *time.Time) {
+	oldBreakers := BreakerInstance
+	t.Cleanup(func() { BreakerInstance = oldBreakers })
+	now := time.Unix(1700000000, 0)
+	breaker := NewCircuitBreaker(ObjectTypeKMS, threshold, time.Minute, 30*time.Second)
+	breaker.now = func() time.Time { return now }
+	BreakerInstance.Kms = breaker
+	return breaker, &now
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker, now := setupBreakerTest(t, 3)
+
+	// Failures spread over more than the window do not open the breaker.
+	breaker.Record(true)
+	breaker.Record(true)
+	*now = now.Add(2 * time.Minute)
+	breaker.Record(true)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected the breaker to stay closed, got %v", err)
+	}
+
+	// A success resets the count.
+	breaker.Record(false)
+	breaker.Record(true)
+	breaker.Record(true)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected the breaker to stay closed after a success, got %v", err)
+	}
+
+	// Consecutive failures within the window open it.
+	breaker.Record(true)
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the breaker to open, got %v", err)
+	}
+
+	// After the cooldown a single probe goes through.
+	*now = now.Add(31 * time.Second)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected a probe after the cooldown, got %v", err)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected a single probe while half-open, got %v", err)
+	}
+
+	// A failed probe opens the breaker again, a successful one closes it.
+	breaker.Record(true)
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected a failed probe to reopen the breaker, got %v", err)
+	}
+	*now = now.Add(31 * time.Second)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected a probe after the cooldown, got %v", err)
+	}
+	breaker.Record(false)
+	if err := breaker.Allow(); err != nil || breaker.state != breakerClosed {
+		t.Fatalf("expected a successful probe to close the breaker, got %v in state %s", err, breaker.state)
+	}
+
+	// A nil breaker never opens.
+	var disabled *CircuitBreaker
+	disabled.Record(true)
+	if err := disabled.Allow(); err != nil {
+		t.Errorf("expected a nil breaker to allow calls, got %v", err)
+	}
+}
+
+func TestCircuitBreakerFetch(t *testing.T) {
+	setupFetchTest(t)
+
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int // calls made by the 3 fetches
+	}{
+		{"unavailable", &tea.SDKError{Code: tea.String(SERVICE_UNAVAILABLE_TEMPORARY)}, 4},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, 2},
+		{"unknown", errors.New("malformed response"), 3},
+		{"not-found", &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound")}, 3},
+		{"no-permission", &tea.SDKError{Code: tea.String("Forbidden.NoPermission")}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupBreakerTest(t, 2)
+			client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				return nil, tt.err
+			}}
+			p := &SecretsManagerProvider{KmsClient: client}
+			for i := 0; i < 3; i++ {
+				if _, _, err := p.fetchSecret(context.Background(), &SecretObject{ObjectName: "MySecret"}); err == nil {
+					t.Fatalf("fetchSecret() %d succeeded, want an error", i)
+				}
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("fetchSecret() made %d calls, want %d", client.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerIgnoresCancelledFetch(t *testing.T) {
+	setupFetchTest(t)
+	breaker, _ := setupBreakerTest(t, 1)
+	// The mount is cancelled while the throttled call is being retried.
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		cancel()
+		return nil, &tea.SDKError{Code: tea.String(REJECTED_THROTTLING)}
+	}}
+	p := &SecretsManagerProvider{KmsClient: client}
+
+	if _, _, err := p.fetchSecret(ctx, &SecretObject{ObjectName: "MySecret"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("fetchSecret() error = %v, want context.Canceled", err)
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expected a cancelled fetch to leave the breaker closed, got %v", err)
+	}
+}
+
+func TestCircuitBreakerStaleFallback(t *testing.T) {
+	setupFetchTest(t)
+	breaker, _ := setupBreakerTest(t, 1)
+	breaker.Record(true)
+	oldFallback := CircuitBreakerStaleFallback
+	defer func() { CircuitBreakerStaleFallback = oldFallback }()
+
+	fs := newMemFileSystem()
+	fs.WriteFile("/mnt/secrets/MySecret", []byte("mounted"), 0644)
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse("fetched", "v2"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	objects, err := NewSecretObjectList("/mnt/secrets", "", "", `[{"objectName": "MySecret"}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := func() map[string]*v1alpha1.ObjectVersion {
+		return map[string]*v1alpha1.ObjectVersion{"MySecret": {Id: "MySecret", Version: "v1"}}
+	}
+
+	if _, err = p.GetSecretValues(context.Background(), objects, curMap()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen without the fallback, got %v", err)
+	}
+
+	CircuitBreakerStaleFallback = true
+	fallbacks := counter(metrics.StaleFallbacks, ObjectTypeKMS)
+	values, err := p.GetSecretValues(context.Background(), objects, curMap())
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if client.calls != 0 || string(values[0].Value) != "mounted" {
+		t.Errorf("expected the mounted value without API calls, got %q after %d calls", values[0].Value, client.calls)
+	}
+	if got := counter(metrics.StaleFallbacks, ObjectTypeKMS) - fallbacks; got != 1 {
+		t.Errorf("counted %d stale fallbacks, want 1", got)
+	}
+
+	// Nothing to fall back to on the first mount.
+	if _, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion)); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen on the first mount, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// MaxRegionalClients bounds the number of per-region clients cached by a provider.
+var MaxRegionalClients = 8
+
+// Lazily built SDK clients for regions other than the mount region and for
+// assumed roles, keyed by clientKey.
+type clientRegistry struct {
+	mu  sync.Mutex
+	kms map[string]KmsAPI
+	oos map[string]OosAPI
+}
+
+// Key of the cached client for a region, an optional assumed role and an
+// optional endpoint.
+func clientKey(region, roleArn, endpoint string) string {
+	key := region
+	if len(roleArn) > 0 {
+		key = roleArn + "@" + region
+	}
+	if len(endpoint) > 0 {
+		key += "#" + endpoint
+	}
+	return key
+}
+
+// Return the KMS client to use for the object, building and caching a client
+// for the object's region when it differs from the mount region, for the
+// object's assumeRole, or for the object's kmsEndpoint.
+func (p *SecretsManagerProvider) kmsClientFor(secObj *SecretObject) (KmsAPI, error) {
+	region := secObj.getRegion()
+	roleArn := secObj.AssumeRole
+	if len(roleArn) == 0 && len(secObj.KmsEndpoint) == 0 && (len(region) == 0 || region == p.Region) {
+		if p.KmsClient == nil {
+			return nil, fmt.Errorf("kms client is empty")
+		}
+		return p.KmsClient, nil
+	}
+	if len(region) == 0 {
+		region = p.Region
+	}
+	endpoint := p.kmsEndpointFor(secObj, region)
+	if len(roleArn) > 0 && p.NewRoleKmsClient == nil {
+		return nil, fmt.Errorf("kms client for role %s is not available", roleArn)
+	}
+	if len(roleArn) == 0 && p.NewKmsClient == nil {
+		return nil, fmt.Errorf("kms client for region %s is not available", region)
+	}
+
+	p.clients.mu.Lock()
+	defer p.clients.mu.Unlock()
+	key := clientKey(region, roleArn, endpoint)
+	if c, ok := p.clients.kms[key]; ok {
+		return c, nil
+	}
+	var c KmsAPI
+	var err error
+	if len(roleArn) > 0 {
+		c, err = p.NewRoleKmsClient(region, roleArn, endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kms client for role %s in region %s: %w", roleArn, region, err)
+		}
+	} else {
+		c, err = p.NewKmsClient(region, endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kms client for region %s: %s", region, err.Error())
+		}
+	}
+	if p.clients.kms == nil {
+		p.clients.kms = make(map[string]KmsAPI)
+	}
+	if len(p.clients.kms) < MaxRegionalClients {
+		p.clients.kms[key] = c
+	} else {
+		klog.Warningf("regional kms client cache is full, not caching client for %s", key)
+	}
+	return c, nil
+}
+
+// Return the OOS client to use for the object, building and caching a client
+// for the object's region when it differs from the mount region, or for the
+// object's assumeRole.
+func (p *SecretsManagerProvider) oosClientFor(secObj *SecretObject) (OosAPI, error) {
+	region := secObj.getRegion()
+	roleArn := secObj.AssumeRole
+	if len(roleArn) == 0 && (len(region) == 0 || region == p.Region) {
+		if p.OosClient == nil {
+			return nil, fmt.Errorf("oos client is empty")
+		}
+		return p.OosClient, nil
+	}
+	if len(region) == 0 {
+		region = p.Region
+	}
+	if len(roleArn) > 0 && p.NewRoleOosClient == nil {
+		return nil, fmt.Errorf("oos client for role %s is not available", roleArn)
+	}
+	if len(roleArn) == 0 && p.NewOosClient == nil {
+		return nil, fmt.Errorf("oos client for region %s is not available", region)
+	}
+
+	p.clients.mu.Lock()
+	defer p.clients.mu.Unlock()
+	key := clientKey(region, roleArn, "")
+	if c, ok := p.clients.oos[key]; ok {
+		return c, nil
+	}
+	var c OosAPI
+	var err error
+	if len(roleArn) > 0 {
+		c, err = p.NewRoleOosClient(region, roleArn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create oos client for role %s in region %s: %w", roleArn, region, err)
+		}
+	} else {
+		c, err = p.NewOosClient(region)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create oos client for region %s: %s", region, err.Error())
+		}
+	}
+	if p.clients.oos == nil {
+		p.clients.oos = make(map[string]OosAPI)
+	}
+	if len(p.clients.oos) < MaxRegionalClients {
+		p.clients.oos[key] = c
+	} else {
+		klog.Warningf("regional oos client cache is full, not caching client for %s", key)
+	}
+	return c, nil
+}
+
+// Drop all cached regional clients.
+func (r *clientRegistry) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kms = nil
+	r.oos = nil
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Fetches in flight, keyed by coalesceKey. The provider is rebuilt for every
+// mount request, so overlapping syncs of a mount only meet in the process.
+var inFlightFetches singleflight.Group
+
+// Result of a fetch shared by the callers of inFlightFetches, never modified.
+type fetchResult struct {
+	version    string
+	value      []byte
+	secretType string
+}
+
+// Key under which fetches of the object are coalesced, and whether they may
+// be. Only fetches of the same mount are coalesced, like overlapping syncs
+// after a slow fetch, so a value is never shared with other credentials.
+// Objects fetched outside of a mount, e.g. by GetSecret, are never coalesced.
+func (p *SecretsManagerProvider) coalesceKey(secObj *SecretObject) (string, bool) {
+	if len(secObj.mountDir) == 0 {
+		return "", false
+	}
+	requestKey, ok := p.fetchRequestKey(secObj)
+	if !ok {
+		return "", false
+	}
+	return secObj.mountDir + "\x00" + requestKey, true
+}
+
+// Called once a caller of coalesceFetch started a fetch or joined the one in
+// flight, replaced by tests waiting for overlapping callers.
+var coalesceJoined = func() {}
+
+// Fetch the value of the key with load, unless the same fetch is already in
+// flight, in which case its version and value, or its error, are returned to
+// every caller. The fetch runs with the context of the caller that started it,
+// so when it fails with a context error, such as a cancelled mount, the other
+// callers fetch it again with their own. A caller whose own context ends stops
+// waiting.
+func coalesceFetch(ctx context.Context, key string, secObj *SecretObject,
+	load func() (string, *SecretValue, error)) (string, *SecretValue, error) {
+	for {
+		started := false
+		ch := inFlightFetches.DoChan(key, func() (interface{}, error) {
+			started = true
+			version, secret, err := load()
+			if err != nil {
+				return nil, err
+			}
+			return &fetchResult{version: version, value: secret.Value, secretType: secret.SecretType}, nil
+		})
+		coalesceJoined()
+		select {
+		case res := <-ch:
+			if res.Err != nil {
+				if !started && ctx.Err() == nil && isContextError(res.Err) {
+					continue
+				}
+				return "", nil, res.Err
+			}
+			result := res.Val.(*fetchResult)
+			return result.version, &SecretValue{Value: append([]byte(nil), result.value...), SecretObj: *secObj, SecretType: result.secretType}, nil
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		}
+	}
+}
+
+// Report whether the error ends a fetch because its context was cancelled or expired.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestCoalesceFetch(t *testing.T) {
+	setupFetchTest(t)
+	errThrottled := errors.New("throttled")
+	tests := []struct {
+		name    string
+		err     error // Error of the fetch, if any
+		wantErr bool
+	}{
+		{"value", nil, false},
+		{"error", errThrottled, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			joined := waitForCoalescedCallers(t)
+			var startOnce sync.Once
+			started, release := make(chan struct{}), make(chan struct{})
+			client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				startOnce.Do(func() { close(started) })
+				<-release
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return kmsSecretResponse("value", "v1"), nil
+			}}
+			objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db", "maxRetries": 0}]`, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			type result struct {
+				values []*SecretValue
+				err    error
+			}
+			results := make(chan result, 2)
+			syncMount := func() {
+				p := &SecretsManagerProvider{KmsClient: client}
+				values, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+				results <- result{values, err}
+			}
+
+			// A sync overlapping a slow fetch of the same mount waits for it.
+			go syncMount()
+			<-started
+			go syncMount()
+			joined(2)
+			close(release)
+			for i := 0; i < 2; i++ {
+				res := <-results
+				if tt.wantErr {
+					if !errors.Is(res.err, errThrottled) {
+						t.Errorf("expected the error of the fetch, got %v", res.err)
+					}
+					continue
+				}
+				if res.err != nil || len(res.values) != 1 || string(res.values[0].Value) != "value" {
+					t.Errorf("expected the fetched value, got %v, %v", res.values, res.err)
+				}
+			}
+			if client.calls != 1 {
+				t.Errorf("expected a single call, got %d", client.calls)
+			}
+		})
+	}
+}
+
+// Count the callers of coalesceFetch for the duration of a test, returning a
+// function waiting until n of them started or joined a fetch.
+func waitForCoalescedCallers(t *testing.T) func(n int) {
+	oldJoined := coalesceJoined
+	t.Cleanup(func() { coalesceJoined = oldJoined })
+	joined := make(chan struct{}, 16)
+	coalesceJoined = func() { joined <- struct{}{} }
+	return func(n int) {
+		for i := 0; i < n; i++ {
+			select {
+			case <-joined:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for %d callers of coalesceFetch", n)
+			}
+		}
+	}
+}
+
+func TestCoalesceFetchCancelledLeader(t *testing.T) {
+	joined := waitForCoalescedCallers(t)
+	secObj := &SecretObject{ObjectName: "db"}
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	started, release := make(chan struct{}), make(chan struct{})
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, _, err := coalesceFetch(leaderCtx, "db", secObj, func() (string, *SecretValue, error) {
+			close(started)
+			<-release
+			cancel()
+			return "", nil, fmt.Errorf("failed to get secret value: %w", context.Canceled)
+		})
+		leaderErr <- err
+	}()
+	<-started
+	waiter := make(chan *SecretValue, 1)
+	go func() {
+		_, secret, err := coalesceFetch(context.Background(), "db", secObj, func() (string, *SecretValue, error) {
+			return "v1", &SecretValue{Value: []byte("value")}, nil
+		})
+		if err != nil {
+			t.Errorf("expected the waiter to fetch the value itself, got %v", err)
+		}
+		waiter <- secret
+	}()
+	joined(2)
+	close(release)
+
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancelled fetch to fail its own caller, got %v", err)
+	}
+	if secret := <-waiter; secret == nil || string(secret.Value) != "value" {
+		t.Errorf("expected the value fetched by the waiter, got %v", secret)
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	p := &SecretsManagerProvider{Region: "cn-hangzhou"}
+	objects, err := NewSecretObjectList("/mnt/a", "", "", `[{"objectName": "db"}, {"objectName": "db", "objectAlias": "other"}, {"objectName": "db", "objectVersion": "v2", "objectAlias": "v2"}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	otherMount, err := NewSecretObjectList("/mnt/b", "", "", `[{"objectName": "db"}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	key := func(secObj *SecretObject) string {
+		key, ok := p.coalesceKey(secObj)
+		if !ok {
+			t.Fatalf("expected %s to be coalesced", secObj.ObjectName)
+		}
+		return key
+	}
+	if key(objects[0]) != key(objects[1]) {
+		t.Errorf("expected aliases of the same secret to be coalesced")
+	}
+	if key(objects[0]) == key(objects[2]) || key(objects[0]) == key(otherMount[0]) {
+		t.Errorf("expected other versions and other mounts not to be coalesced")
+	}
+	if _, ok := p.coalesceKey(&SecretObject{ObjectName: "db"}); ok {
+		t.Errorf("expected objects outside of a mount not to be coalesced")
+	}
+}
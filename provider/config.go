@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/time/rate"
+)
+
+// ProviderConfig is the effective configuration of a provider, for diagnostics
+// such as bug reports. It never holds credentials, the identity of the mount
+// nor secret values. Durations are formatted like the flags setting them.
+type ProviderConfig struct {
+	Region               string            `json:"region"`
+	RegionEndpoints      map[string]string `json:"regionEndpoints,omitempty"`
+	KmsFallbackEndpoints []string          `json:"kmsFallbackEndpoints,omitempty"`
+	MaxRegionalClients   int               `json:"maxRegionalClients"`
+
+	// Rate of each backend in requests per second, or unlimited.
+	KmsRateLimit       string `json:"kmsRateLimit"`
+	OosRateLimit       string `json:"oosRateLimit"`
+	MaxInFlightPulls   int    `json:"maxInFlightPulls"` // 0 is unlimited
+	LimiterWaitTimeout string `json:"limiterWaitTimeout"`
+
+	// Retry policy of objects without their own retry settings.
+	MaxRetries           int      `json:"maxRetries"`
+	RetryInterval        string   `json:"retryInterval"`
+	FetchTimeout         string   `json:"fetchTimeout"`
+	RetryableErrorCodes  []string `json:"retryableErrorCodes,omitempty"`
+	CustomRetryPredicate bool     `json:"customRetryPredicate"`
+	BatchRetries         int      `json:"batchRetries"`
+	BatchRetryInterval   string   `json:"batchRetryInterval"`
+
+	// Circuit breaker of each backend, nil when it is disabled.
+	KmsCircuitBreaker           *BreakerConfig `json:"kmsCircuitBreaker,omitempty"`
+	OosCircuitBreaker           *BreakerConfig `json:"oosCircuitBreaker,omitempty"`
+	CircuitBreakerStaleFallback bool           `json:"circuitBreakerStaleFallback"`
+
+	// Shared cache settings, and whether this mount may use it.
+	SharedCacheSize    int    `json:"sharedCacheSize"` // 0 is disabled
+	SharedCacheTTL     string `json:"sharedCacheTTL"`
+	SharedCacheEnabled bool   `json:"sharedCacheEnabled"`
+
+	MaxFilesPerObject  int `json:"maxFilesPerObject"`
+	MaxObjectsPerMount int `json:"maxObjectsPerMount"`
+	MaxFilesPerMount   int `json:"maxFilesPerMount"`
+
+	// Optional features.
+	CheckCurrentVersion bool   `json:"checkCurrentVersion"`
+	PrevalidateSecrets  bool   `json:"prevalidateSecrets"`
+	VerifyVersionStages bool   `json:"verifyVersionStages"`
+	VerifyMountedFiles  bool   `json:"verifyMountedFiles"`
+	DisableJMESPath     bool   `json:"disableJMESPath"`
+	EnableRotation      bool   `json:"enableRotation"`
+	LocalFileSourceDir  string `json:"localFileSourceDir,omitempty"`
+	Processors          int    `json:"processors"`
+
+	// Files of the mount.
+	DataMapFile     string `json:"dataMapFile,omitempty"`
+	ManifestFile    string `json:"manifestFile,omitempty"`
+	DefaultFileMode string `json:"defaultFileMode,omitempty"`
+	FileUmask       string `json:"fileUmask,omitempty"`
+}
+
+// BreakerConfig is the configuration of the circuit breaker of a backend.
+type BreakerConfig struct {
+	Threshold int    `json:"threshold"`
+	Window    string `json:"window"`
+	Cooldown  string `json:"cooldown"`
+}
+
+// Config returns the effective configuration of the provider, from its fields
+// and the provider wide settings of the flags. Like DescribeSpec it pairs with
+// the logs of a mount for diagnostics, and never includes credentials or values.
+func (p *SecretsManagerProvider) Config() ProviderConfig {
+	policy := p.retryPolicyFor(nil)
+	config := ProviderConfig{
+		Region:                      p.Region,
+		RegionEndpoints:             p.RegionEndpointMap,
+		KmsFallbackEndpoints:        p.KmsFallbackEndpoints,
+		MaxRegionalClients:          MaxRegionalClients,
+		KmsRateLimit:                rateLimitString(LimiterInstance.Kms.SecretPullLimiter),
+		OosRateLimit:                rateLimitString(LimiterInstance.OOS.SecretPullLimiter),
+		LimiterWaitTimeout:          LimiterWaitTimeout.String(),
+		MaxRetries:                  policy.maxRetries,
+		RetryInterval:               policy.retryInterval.String(),
+		FetchTimeout:                policy.fetchTimeout.String(),
+		RetryableErrorCodes:         RetryableErrorCodes,
+		CustomRetryPredicate:        p.RetryPredicate != nil,
+		BatchRetries:                BatchRetries,
+		BatchRetryInterval:          BatchRetryInterval.String(),
+		KmsCircuitBreaker:           BreakerInstance.Kms.config(),
+		OosCircuitBreaker:           BreakerInstance.OOS.config(),
+		CircuitBreakerStaleFallback: CircuitBreakerStaleFallback,
+		SharedCacheSize:             SharedCacheSize,
+		SharedCacheTTL:              SharedCacheTTL.String(),
+		SharedCacheEnabled:          SharedCacheSize > 0 && SharedCacheTTL > 0 && len(p.CacheScope) > 0,
+		MaxFilesPerObject:           MaxFilesPerObject,
+		MaxObjectsPerMount:          MaxObjectsPerMount,
+		MaxFilesPerMount:            MaxFilesPerMount,
+		CheckCurrentVersion:         CheckCurrentVersion,
+		PrevalidateSecrets:          PrevalidateSecrets,
+		VerifyVersionStages:         VerifyVersionStages,
+		VerifyMountedFiles:          VerifyMountedFiles,
+		DisableJMESPath:             DisableJMESPath,
+		EnableRotation:              p.EnableRotation,
+		LocalFileSourceDir:          LocalFileSourceDir,
+		Processors:                  len(p.Processors),
+		DataMapFile:                 p.DataMapFile,
+		ManifestFile:                p.ManifestFile,
+	}
+	if LimiterInstance.InFlight != nil {
+		config.MaxInFlightPulls = cap(LimiterInstance.InFlight.slots)
+	}
+	if p.DefaultFileMode != nil {
+		config.DefaultFileMode = fmt.Sprintf("%04o", uint32(*p.DefaultFileMode))
+	}
+	if p.FileUmask != 0 {
+		config.FileUmask = fmt.Sprintf("%04o", uint32(p.FileUmask))
+	}
+	return config
+}
+
+// Report the rate of a secret pull limiter.
+func rateLimitString(l *rate.Limiter) string {
+	if l == nil || l.Limit() == rate.Inf {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(float64(l.Limit()), 'g', -1, 64) + "/s"
+}
+
+// Return the configuration of the breaker, nil when it is disabled.
+func (b *CircuitBreaker) config() *BreakerConfig {
+	if b == nil {
+		return nil
+	}
+	return &BreakerConfig{Threshold: b.threshold, Window: b.window.String(), Cooldown: b.cooldown.String()}
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestConfig(t *testing.T) {
+	oldLimiter, oldBreakers, oldCodes := LimiterInstance, BreakerInstance, RetryableErrorCodes
+	t.Cleanup(func() { LimiterInstance, BreakerInstance, RetryableErrorCodes = oldLimiter, oldBreakers, oldCodes })
+	LimiterInstance = Limiter{Kms: KmsLimiter{SecretPullLimiter: rate.NewLimiter(10, 1)}, InFlight: NewConcurrencyLimiter(4)}
+	BreakerInstance = Breakers{Kms: NewCircuitBreaker(ObjectTypeKMS, 5, time.Minute, 30*time.Second)}
+	RetryableErrorCodes = []string{"Custom.Transient"}
+
+	mode := os.FileMode(0640)
+	retries := 3
+	p := &SecretsManagerProvider{
+		Region:          "cn-hangzhou",
+		KmsClient:       &mockKmsClient{},
+		MaxRetries:      &retries,
+		CacheScope:      "LTAI-access-key-id",
+		DefaultFileMode: &mode,
+		FileUmask:       0022,
+		DataMapFile:     "data.json",
+	}
+	config := p.Config()
+	if config.Region != "cn-hangzhou" || config.KmsRateLimit != "10/s" || config.OosRateLimit != "unlimited" || config.MaxInFlightPulls != 4 {
+		t.Errorf("unexpected limits %+v", config)
+	}
+	if config.MaxRetries != 3 || config.RetryInterval != BACKOFF_DEFAULT_RETRY_INTERVAL.String() || len(config.RetryableErrorCodes) != 1 {
+		t.Errorf("unexpected retry policy %+v", config)
+	}
+	if config.KmsCircuitBreaker == nil || config.KmsCircuitBreaker.Threshold != 5 || config.KmsCircuitBreaker.Window != "1m0s" || config.OosCircuitBreaker != nil {
+		t.Errorf("unexpected circuit breakers %+v, %+v", config.KmsCircuitBreaker, config.OosCircuitBreaker)
+	}
+	if config.DefaultFileMode != "0640" || config.FileUmask != "0022" || config.DataMapFile != "data.json" {
+		t.Errorf("unexpected files %+v", config)
+	}
+
+	// The identity of the mount is never reported.
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if strings.Contains(string(encoded), "LTAI") {
+		t.Errorf("configuration leaks the cache scope: %s", encoded)
+	}
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"sort"
+)
+
+// CurrentVersionKeys returns the sorted keys GetSecretValues records in the
+// current version map for a list of objects, without fetching anything: the
+// file name of every object, of its jmesPath entries, envFile, infoFile,
+// tagsFile, pkcs12 bundle and data key ciphertext, of the mergeInto files, the
+// fetchedAt:<file name> entry of an object with a maxAge and the
+// secretType:<file name> entry of an object with extractManagedFields. Keys
+// only known from the fetched values are left out: fanOut entries, the
+// elements of a split StringList, the managed fields of extractManagedFields,
+// the .prev file of includePreviousVersion, and the keys of an optional object
+// that does not exist. Keys recorded per previous version have the prefixes
+// returned by CurrentVersionKeyPrefixes.
+func CurrentVersionKeys(objects []*SecretObject) []string {
+	keys := make(map[string]bool)
+	merged := make(map[string]bool) // mergeInto names, named after their first entry
+	for _, secObj := range objects {
+		if secObj.emitsRaw() {
+			keys[secObj.GetFileName()] = true
+		}
+		for i := range secObj.JMESPath {
+			entry := &secObj.JMESPath[i]
+			switch {
+			case len(entry.MergeInto) > 0:
+				if !merged[entry.MergeInto] {
+					merged[entry.MergeInto] = true
+					mergedObj := SecretObject{
+						ObjectAlias:  entry.MergeInto,
+						LeadingSlash: secObj.LeadingSlash,
+						translate:    secObj.translate,
+						mountDir:     secObj.mountDir,
+					}
+					keys[mergedObj.GetFileName()] = true
+				}
+			case !entry.FanOut:
+				jmesObj := secObj.getJmesEntrySecretObject(entry)
+				keys[jmesObj.GetFileName()] = true
+			}
+		}
+		if len(secObj.EnvFile) > 0 {
+			envObj := secObj.getEnvFileSecretObject()
+			keys[envObj.GetFileName()] = true
+		}
+		if secObj.InfoFile {
+			infoObj := secObj.getInfoFileSecretObject()
+			keys[infoObj.GetFileName()] = true
+		}
+		if len(secObj.TagsFile) > 0 {
+			tagsObj := secObj.getTagsFileSecretObject()
+			keys[tagsObj.GetFileName()] = true
+		}
+		if secObj.isDataKey() {
+			ciphertextObj := secObj.getCiphertextSecretObject()
+			keys[ciphertextObj.GetFileName()] = true
+		}
+		if secObj.maxAge > 0 {
+			keys[secObj.fetchedAtKey()] = true
+		}
+		if secObj.ExtractManagedFields && secObj.emitsRaw() {
+			keys[secObj.secretTypeKey()] = true
+		}
+		if secObj.PKCS12 != nil {
+			bundleObj := secObj.getPKCS12SecretObject()
+			keys[bundleObj.GetFileName()] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// CurrentVersionKeyPrefixes returns the sorted prefixes of the keys
+// GetSecretValues records in the current version map once per previous
+// version of an object, which are not known without the mount history: the
+// retained:<file name>@ entries of the previous files kept by
+// previousFileGracePeriod. Every key of the map of a mount of the objects is
+// either one of CurrentVersionKeys, has one of these prefixes, or is only known
+// from the fetched values.
+func CurrentVersionKeyPrefixes(objects []*SecretObject) []string {
+	var prefixes []string
+	for _, secObj := range objects {
+		if secObj.previousFileGracePeriod > 0 {
+			prefixes = append(prefixes, secObj.retainedKeyPrefix())
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
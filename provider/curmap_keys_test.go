@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestCurrentVersionKeys(t *testing.T) {
+	setupFetchTest(t)
+	kmsClient := &mockKmsClient{
+		getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+			return kmsSecretResponse(`{"user": "admin", "password": "secret", "host": "db"}`, "v1"), nil
+		},
+		describeSecret: func(*kms.DescribeSecretRequest) (*kms.DescribeSecretResponse, error) {
+			return &kms.DescribeSecretResponse{Body: &kms.DescribeSecretResponseBody{}}, nil
+		},
+		generateDataKey: func(*kms.GenerateDataKeyRequest) (*kms.GenerateDataKeyResponse, error) {
+			return &kms.GenerateDataKeyResponse{Body: &kms.GenerateDataKeyResponseBody{
+				Plaintext:      tea.String(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))),
+				CiphertextBlob: tea.String("Y2lwaGVydGV4dA=="),
+				KeyVersionId:   tea.String("kv1"),
+			}}, nil
+		},
+	}
+	oosClient := newVersionedOosClient(`{"port": "5432"}`)
+	p := &SecretsManagerProvider{KmsClient: kmsClient, OosClient: oosClient, FS: newMemFileSystem()}
+	spec := `
+- objectName: "/app/db"
+  objectAlias: "db"
+  infoFile: true
+  tagsFile: "db.tags"
+  envFile: "db.env"
+  jmesPath:
+    - path: "user"
+      objectAlias: "DB_USER"
+    - path: "password"
+      objectAlias: "DB_PASSWORD"
+- objectName: "cache"
+  maxAge: "24h"
+  jmesPath:
+    - path: "host"
+      objectAlias: "host"
+      mergeInto: "config.json"
+- objectName: "port"
+  objectType: "oos"
+  jmesPath:
+    - path: "port"
+      objectAlias: "port"
+      mergeInto: "config.json"
+    - path: "port"
+      objectAlias: "settings"
+      extension: "txt"
+- objectName: "key"
+  objectType: "datakey"
+  objectAlias: "key.bin"
+  ciphertextAlias: "key.enc"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := make(map[string]*v1alpha1.ObjectVersion)
+	if _, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	fetched := sortedKeys(curMap)
+	got := CurrentVersionKeys(objects)
+	if strings.Join(got, ",") != strings.Join(fetched, ",") {
+		t.Errorf("CurrentVersionKeys() = %v, fetch recorded %v", got, fetched)
+	}
+	if len(got) != 13 {
+		t.Errorf("expected 13 keys, got %v", got)
+	}
+}
+
+func TestCurrentVersionKeysPKCS12(t *testing.T) {
+	setupFetchTest(t)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	certPEM, keyPEM := testCertificate(t, ecKey, "EC PRIVATE KEY", ecDER)
+	client := &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		if tea.StringValue(request.SecretName) == "key" {
+			return kmsSecretResponse(keyPEM, "v1"), nil
+		}
+		return kmsSecretResponse(certPEM, "v1"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client, FS: newMemFileSystem()}
+	spec := `[{"objectName": "cert", "pkcs12": {"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key", "password": "changeit"}}, {"objectName": "key"}]`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := make(map[string]*v1alpha1.ObjectVersion)
+	if _, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if got, fetched := strings.Join(CurrentVersionKeys(objects), ","), strings.Join(sortedKeys(curMap), ","); got != fetched || got != "cert,key,ks.p12" {
+		t.Errorf("CurrentVersionKeys() = %v, fetch recorded %v", got, fetched)
+	}
+}
+
+func TestCurrentVersionKeysPreviousFileGracePeriod(t *testing.T) {
+	setupFetchTest(t)
+	version := "v1"
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse("value-"+version, version), nil
+	}}
+	fs := newMemFileSystem()
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db", "objectAlias": "db-{{.Version}}", "previousFileGracePeriod": "10m"}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := make(map[string]*v1alpha1.ObjectVersion)
+	if _, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	fs.WriteFile("/mnt/db-v1", []byte("value-v1"), 0644)
+
+	// The rotation to v2 retains the file of v1 under a key per version.
+	version = "v2"
+	if _, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	keys, prefixes := CurrentVersionKeys(objects), CurrentVersionKeyPrefixes(objects)
+	if strings.Join(keys, ",") != "db-{{.Version}}" || strings.Join(prefixes, ",") != "retained:db-{{.Version}}@" {
+		t.Fatalf("CurrentVersionKeys() = %v and CurrentVersionKeyPrefixes() = %v", keys, prefixes)
+	}
+	var perVersion []string
+	for _, key := range sortedKeys(curMap) {
+		if key == keys[0] {
+			continue
+		}
+		if !strings.HasPrefix(key, prefixes[0]) {
+			t.Errorf("fetch recorded %s, which matches no key or prefix", key)
+		}
+		perVersion = append(perVersion, key)
+	}
+	if strings.Join(perVersion, ",") != "retained:db-{{.Version}}@v1" {
+		t.Errorf("expected the retained entry of v1, got %v", perVersion)
+	}
+}
+
+// The sorted keys of a current version map.
+func sortedKeys(curMap map[string]*v1alpha1.ObjectVersion) []string {
+	keys := make([]string, 0, len(curMap))
+	for key := range curMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestCurrentVersionKeysOmitsFetchedNames(t *testing.T) {
+	spec := `[{"objectName": "s", "extractManagedFields": true, "includePreviousVersion": true, "jmesPath": [{"path": "hosts", "objectAlias": "host-", "fanOut": true}]}]`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	if got := CurrentVersionKeys(objects); strings.Join(got, ",") != "s,secretType:s" {
+		t.Errorf("CurrentVersionKeys() = %v, want [s secretType:s]", got)
+	}
+}
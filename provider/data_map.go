@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Check the name of a data map file. It is a plain file name in the mount
+// directory, written in YAML for .yaml and .yml names and in JSON otherwise.
+func ValidateDataMapFile(name string) error {
+	if len(name) == 0 || strings.ContainsRune(name, os.PathSeparator) || badPathRE.MatchString(name) {
+		return fmt.Errorf("Invalid dataMapFile %q, it must be a file name without a path", name)
+	}
+	return nil
+}
+
+// Report whether the data map file is written in YAML rather than JSON.
+func isYAMLDataMap(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Replace the files of a mount with a single data map file, keyed by file
+// name with base64 encoded values like the data of a Kubernetes Secret.
+func (p *SecretsManagerProvider) dataMapValue(values []*SecretValue) (*SecretValue, error) {
+	data := make(map[string]string, len(values))
+	changed := false
+	for _, sv := range values {
+		changed = changed || sv.changed
+		name := sv.SecretObj.GetFileName()
+		if _, ok := data[name]; ok {
+			return nil, fmt.Errorf("File name %s is used twice in dataMapFile %s", name, p.DataMapFile)
+		}
+		data[name] = base64.StdEncoding.EncodeToString(sv.Value)
+	}
+
+	var encoded []byte
+	var err error
+	if isYAMLDataMap(p.DataMapFile) {
+		encoded, err = yaml.Marshal(data)
+	} else {
+		encoded, err = json.Marshal(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SecretValue{
+		Value:     encoded,
+		SecretObj: SecretObject{ObjectAlias: p.DataMapFile, mountDir: values[0].SecretObj.mountDir},
+		changed:   changed,
+	}, nil
+}
+
+// Read back a mounted file, from the mounted data map file when one is used.
+func (p *SecretsManagerProvider) readMounted(secObj *SecretObject) ([]byte, error) {
+	if len(p.DataMapFile) == 0 {
+		return p.fs().ReadFile(secObj.GetMountPath())
+	}
+
+	path := filepath.Join(secObj.GetMountDir(), p.DataMapFile)
+	if p.mountedDataMap == nil {
+		raw, err := p.fs().ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data := make(map[string]string)
+		if err = yaml.Unmarshal(raw, &data); err != nil { // JSON is valid YAML
+			return nil, fmt.Errorf("Failed to load dataMapFile %s: %+v", path, err)
+		}
+		p.mountedDataMap = data
+	}
+	encoded, ok := p.mountedDataMap[secObj.GetFileName()]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: path + ":" + secObj.GetFileName(), Err: os.ErrNotExist}
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
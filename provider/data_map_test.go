@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestValidateDataMapFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"secrets.json", false},
+		{"secrets.yaml", false},
+		{"", true},
+		{"dir/secrets.json", true},
+		{"..", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateDataMapFile(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateDataMapFile(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGetSecretValuesDataMap(t *testing.T) {
+	setupFetchTest(t)
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(`{"user": "admin"}`, "v1"), nil
+	}}
+	spec := `
+- objectName: "app/db"
+  objectVersion: "v1"
+  jmesPath:
+    - {path: "user", objectAlias: "user"}
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	want := map[string]string{"app_db": "eyJ1c2VyIjogImFkbWluIn0=", "user": "YWRtaW4="}
+
+	for _, name := range []string{"secrets.json", "secrets.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			fs := newMemFileSystem()
+			p := &SecretsManagerProvider{KmsClient: client, FS: fs, DataMapFile: name}
+			curMap := make(map[string]*v1alpha1.ObjectVersion)
+			values, err := p.GetSecretValues(context.Background(), objects, curMap)
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if len(values) != 1 || values[0].SecretObj.GetFileName() != name {
+				t.Fatalf("expected a single %s file, got %d values", name, len(values))
+			}
+			got := make(map[string]string)
+			if name == "secrets.json" {
+				err = json.Unmarshal(values[0].Value, &got)
+			} else {
+				err = yaml.Unmarshal(values[0].Value, &got)
+			}
+			if err != nil {
+				t.Fatalf("failed to decode %s: %v", values[0].Value, err)
+			}
+			if len(got) != len(want) || got["app_db"] != want["app_db"] || got["user"] != want["user"] {
+				t.Errorf("data map = %v, want %v", got, want)
+			}
+
+			// A current object is reloaded from the mounted data map.
+			fs.WriteFile(values[0].SecretObj.GetMountPath(), values[0].Value, 0644)
+			client.calls = 0
+			reloaded, err := p.GetSecretValues(context.Background(), objects, curMap)
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if client.calls != 0 || string(reloaded[0].Value) != string(values[0].Value) {
+				t.Errorf("expected the data map to be reloaded without API calls, got %s after %d calls", reloaded[0].Value, client.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"k8s.io/klog/v2"
+)
+
+// Data key specs accepted by KMS GenerateDataKey.
+const (
+	DataKeySpecAES256 = "AES_256"
+	DataKeySpecAES128 = "AES_128"
+)
+
+// isDataKey reports whether the object is a data key generated under a CMK.
+func (s *SecretObject) isDataKey() bool {
+	return s.ObjectType == ObjectTypeDataKey
+}
+
+// Check the fields of a datakey object. Both files need an explicit name and
+// none of the options working on fetched secret values apply to a data key.
+func (s *SecretObject) validateDataKey() error {
+	if len(s.ObjectAlias) == 0 || len(s.CiphertextAlias) == 0 {
+		return fmt.Errorf("objectAlias and ciphertextAlias must be specified for datakey object: %s", s.ObjectName)
+	}
+	if s.ObjectAlias == s.CiphertextAlias {
+		return fmt.Errorf("objectAlias and ciphertextAlias must differ for datakey object: %s", s.ObjectName)
+	}
+	ciphertextObj := s.getCiphertextSecretObject()
+	if badPathRE.MatchString(ciphertextObj.GetFileName()) {
+		return fmt.Errorf("path can not contain ../: %s", s.CiphertextAlias)
+	}
+	for k := range s.EncryptionContext {
+		if len(k) == 0 {
+			return fmt.Errorf("encryptionContext keys can not be empty for datakey object: %s", s.ObjectName)
+		}
+	}
+	switch s.KeySpec {
+	case "", DataKeySpecAES256, DataKeySpecAES128:
+	default:
+		return fmt.Errorf("Invalid keySpec %q for datakey object %s, supported specs are %q and %q", s.KeySpec, s.ObjectName, DataKeySpecAES256, DataKeySpecAES128)
+	}
+	if len(s.ObjectVersion) > 0 || len(s.ObjectVersionLabel) > 0 || s.AlwaysLatest || len(s.JMESPath) > 0 || len(s.EnvFile) > 0 ||
+		s.ExtractManagedFields || s.TrimSpace || s.EnsureTrailingNewline || len(s.ValuePattern) > 0 || len(s.ExpectedSha256) > 0 || s.IncludePreviousVersion {
+		return fmt.Errorf("objectVersion, objectVersionLabel, alwaysLatest, jmesPath, envFile, extractManagedFields, trimSpace, ensureTrailingNewline, valuePattern, expectedSha256 and includePreviousVersion are not supported for datakey object: %s", s.ObjectName)
+	}
+	return nil
+}
+
+func (p *SecretObject) getCiphertextSecretObject() (d SecretObject) {
+	return SecretObject{
+		ObjectAlias:  p.CiphertextAlias,
+		LeadingSlash: p.LeadingSlash,
+		translate:    p.translate,
+		mountDir:     p.mountDir,
+	}
+}
+
+// Generate a data key under the CMK named by the object. The plaintext key is
+// returned decoded, ready for use, and the base64 ciphertext blob is kept for
+// the ciphertextAlias file.
+func (smp *SecretsManagerProvider) getDataKey(ctx context.Context, c KmsAPI, secObj *SecretObject) (string, *SecretValue, error) {
+	keySpec := secObj.KeySpec
+	if len(keySpec) == 0 {
+		keySpec = DataKeySpecAES256
+	}
+	request := &kms.GenerateDataKeyRequest{
+		KeyId:   tea.String(secObj.ObjectName),
+		KeySpec: tea.String(keySpec),
+	}
+	if len(secObj.EncryptionContext) > 0 {
+		request.EncryptionContext = make(map[string]interface{}, len(secObj.EncryptionContext))
+		for k, v := range secObj.EncryptionContext {
+			request.EncryptionContext[k] = v
+		}
+	}
+	var response *kms.GenerateDataKeyResponse
+	err := smp.withRetry(ctx, ObjectTypeKMS, secObj, func() (err error) {
+		response, err = c.GenerateDataKey(request)
+		return smp.accessDeniedError(err, "kms:GenerateDataKey", secObj)
+	})
+	if err != nil {
+		klog.Error(err, "failed to generate data key from kms", "key", secObj.ObjectName)
+		return "", nil, fmt.Errorf("Failed generating data key with %s: %w", secObj.ObjectName, err)
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(tea.StringValue(response.Body.Plaintext))
+	if err != nil {
+		return "", nil, fmt.Errorf("Invalid plaintext returned generating data key with %s", secObj.ObjectName)
+	}
+
+	return tea.StringValue(response.Body.KeyVersionId), &SecretValue{
+		Value:      plaintext,
+		SecretObj:  *secObj,
+		ciphertext: []byte(tea.StringValue(response.Body.CiphertextBlob)),
+	}, nil
+}
+
+// Build the ciphertext file of a data key. A reloaded data key keeps the
+// ciphertext already mounted, as it is never regenerated while mounted.
+func (p *SecretsManagerProvider) ciphertextSecretFor(secret *SecretValue, reloaded bool) (*SecretValue, error) {
+	ciphertextObj := secret.SecretObj.getCiphertextSecretObject()
+	if !reloaded {
+		return &SecretValue{Value: secret.ciphertext, SecretObj: ciphertextObj}, nil
+	}
+	ciphertext, err := p.readMounted(&ciphertextObj)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretValue{Value: ciphertext, SecretObj: ciphertextObj}, nil
+}
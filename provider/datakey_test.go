@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestValidateDataKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		obj     SecretObject
+		wantErr bool
+	}{
+		{"valid", SecretObject{ObjectName: "alias/app", ObjectAlias: "key", CiphertextAlias: "key.enc"}, false},
+		{"aes-128", SecretObject{ObjectName: "alias/app", ObjectAlias: "key", CiphertextAlias: "key.enc", KeySpec: DataKeySpecAES128}, false},
+		{"missing-ciphertext-alias", SecretObject{ObjectName: "alias/app", ObjectAlias: "key"}, true},
+		{"missing-alias", SecretObject{ObjectName: "alias/app", CiphertextAlias: "key.enc"}, true},
+		{"same-aliases", SecretObject{ObjectName: "alias/app", ObjectAlias: "key", CiphertextAlias: "key"}, true},
+		{"bad-key-spec", SecretObject{ObjectName: "alias/app", ObjectAlias: "key", CiphertextAlias: "key.enc", KeySpec: "RSA_2048"}, true},
+		{"encryption-context", SecretObject{ObjectName: "alias/app", ObjectAlias: "key", CiphertextAlias: "key.enc", EncryptionContext: map[string]string{"app": "web"}}, false},
+		{"empty-context-key", SecretObject{ObjectName: "alias/app", ObjectAlias: "key", CiphertextAlias: "key.enc", EncryptionContext: map[string]string{"": "web"}}, true},
+		{"jmes-path", SecretObject{ObjectName: "alias/app", ObjectAlias: "key", CiphertextAlias: "key.enc", JMESPath: []JMESPathObject{{Path: "a", ObjectAlias: "a"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.obj.ObjectType = ObjectTypeDataKey
+			if err := tt.obj.validateSecretObject(); (err != nil) != tt.wantErr {
+				t.Errorf("validateSecretObject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetSecretValuesDataKey(t *testing.T) {
+	setupFetchTest(t)
+	plaintext := []byte{0x00, 0x01, 0xfe, 0xff}
+	var keySpec string
+	var encryptionContext map[string]interface{}
+	client := &mockKmsClient{generateDataKey: func(request *kms.GenerateDataKeyRequest) (*kms.GenerateDataKeyResponse, error) {
+		keySpec = tea.StringValue(request.KeySpec)
+		encryptionContext = request.EncryptionContext
+		return &kms.GenerateDataKeyResponse{Body: &kms.GenerateDataKeyResponseBody{
+			Plaintext:      tea.String(base64.StdEncoding.EncodeToString(plaintext)),
+			CiphertextBlob: tea.String("Y2lwaGVydGV4dA=="),
+			KeyVersionId:   tea.String("kv1"),
+		}}, nil
+	}}
+	fs := newMemFileSystem()
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	objects, err := NewSecretObjectList("/mnt", "", "",
+		`[{"objectName": "alias/app", "objectType": "datakey", "objectAlias": "key", "ciphertextAlias": "key.enc", "encryptionContext": {"app": "web"}}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+
+	curMap := make(map[string]*v1alpha1.ObjectVersion)
+	values, err := p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if keySpec != DataKeySpecAES256 {
+		t.Errorf("GenerateDataKey() keySpec = %s, want %s", keySpec, DataKeySpecAES256)
+	}
+	if encryptionContext["app"] != "web" {
+		t.Errorf("GenerateDataKey() encryptionContext = %v", encryptionContext)
+	}
+	if len(values) != 2 || string(values[0].Value) != string(plaintext) || string(values[1].Value) != "Y2lwaGVydGV4dA==" {
+		t.Fatalf("GetSecretValues() returned unexpected values")
+	}
+	if curMap["key"].Version != "kv1" || curMap["key.enc"].Version != "kv1" {
+		t.Errorf("GetSecretValues() did not record the key version")
+	}
+
+	// A mounted data key is reloaded instead of being generated again.
+	for _, v := range values {
+		fs.WriteFile(v.SecretObj.GetMountPath(), v.Value, 0644)
+	}
+	values, err = p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if client.calls != 1 {
+		t.Errorf("GenerateDataKey() called %d times, want 1", client.calls)
+	}
+	if len(values) != 2 || string(values[0].Value) != string(plaintext) || string(values[1].Value) != "Y2lwaGVydGV4dA==" {
+		t.Errorf("GetSecretValues() did not reload the mounted data key")
+	}
+}
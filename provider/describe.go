@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DescribeSpec returns a readable summary of the parsed objects for logs and
+// diagnostics. It only reports the spec as resolved by NewSecretObjectList,
+// never makes API calls and never includes secret values.
+func (p *SecretsManagerProvider) DescribeSpec(objects []*SecretObject) string {
+	var b strings.Builder
+	for i, obj := range objects {
+		objectType := obj.ObjectType
+		if len(objectType) == 0 {
+			objectType = ObjectTypeKMS
+		}
+		region := obj.getRegion()
+		if len(region) == 0 {
+			region = p.Region
+		}
+
+		fmt.Fprintf(&b, "object[%d]: name=%q type=%s region=%s", i, obj.ObjectName, objectType, region)
+		if obj.isDataKey() {
+			keySpec := obj.KeySpec
+			if len(keySpec) == 0 {
+				keySpec = DataKeySpecAES256
+			}
+			ciphertextObj := obj.getCiphertextSecretObject()
+			fmt.Fprintf(&b, " keySpec=%s ciphertext=%q", keySpec, ciphertextObj.GetMountPath())
+		}
+		if len(obj.AssumeRole) > 0 {
+			fmt.Fprintf(&b, " assumeRole=%s", obj.AssumeRole)
+		}
+		if len(obj.KmsEndpoint) > 0 {
+			fmt.Fprintf(&b, " kmsEndpoint=%s", obj.KmsEndpoint)
+		}
+		if fallbacks := p.kmsFallbackEndpointsFor(obj); len(fallbacks) > 0 {
+			fmt.Fprintf(&b, " kmsFallbackEndpoints=%v", fallbacks)
+		}
+		if len(obj.ObjectAlias) > 0 {
+			fmt.Fprintf(&b, " alias=%q", obj.ObjectAlias)
+		}
+		fmt.Fprintf(&b, " path=%q", obj.GetMountPath())
+		if len(obj.ObjectVersion) > 0 {
+			fmt.Fprintf(&b, " version=%q", obj.ObjectVersion)
+		}
+		if len(obj.ObjectVersionLabel) > 0 {
+			fmt.Fprintf(&b, " versionLabel=%q", obj.ObjectVersionLabel)
+		}
+		if len(obj.JMESPath) > 0 && obj.EmitRawWhenJmes != nil && !*obj.EmitRawWhenJmes {
+			b.WriteString(" emitRawWhenJmes=false")
+		}
+		if obj.formatsStringList() {
+			fmt.Fprintf(&b, " stringListFormat=%s", obj.StringListFormat)
+		}
+		if obj.AlwaysLatest {
+			b.WriteString(" alwaysLatest=true")
+		}
+		if obj.FollowIndirection {
+			b.WriteString(" followIndirection=true")
+		}
+		if len(obj.RefreshToken) > 0 {
+			fmt.Fprintf(&b, " refreshToken=%q", obj.RefreshToken)
+		}
+		if len(obj.MaxAge) > 0 {
+			fmt.Fprintf(&b, " maxAge=%s", obj.MaxAge)
+		}
+		if len(obj.JQFilter) > 0 {
+			fmt.Fprintf(&b, " jqFilter=%q", obj.JQFilter)
+		}
+		if len(obj.PreviousFileGracePeriod) > 0 {
+			fmt.Fprintf(&b, " previousFileGracePeriod=%s", obj.PreviousFileGracePeriod)
+		}
+		if obj.PKCS12 != nil {
+			fmt.Fprintf(&b, " pkcs12=%s(%s,%s)", obj.PKCS12.ObjectAlias, obj.PKCS12.CertFile, obj.PKCS12.KeyFile)
+		}
+		if obj.FailDuringRotation {
+			b.WriteString(" failDuringRotation=true")
+		}
+		fmt.Fprintf(&b, " required=%t trimSpace=%t", obj.isRequired(), obj.TrimSpace)
+		if obj.FailOnEmpty {
+			b.WriteString(" failOnEmpty=true")
+		}
+		if len(obj.TagsFile) > 0 {
+			tagsObj := obj.getTagsFileSecretObject()
+			fmt.Fprintf(&b, " tagsFile=%q", tagsObj.GetMountPath())
+			if len(obj.TagsFormat) > 0 {
+				fmt.Fprintf(&b, " tagsFormat=%s", obj.TagsFormat)
+			}
+		}
+		if obj.InfoFile {
+			infoObj := obj.getInfoFileSecretObject()
+			fmt.Fprintf(&b, " infoFile=%q", infoObj.GetMountPath())
+		}
+		if len(obj.Labels) > 0 {
+			fmt.Fprintf(&b, " labels=%v", obj.Labels)
+		}
+		if obj.ExtractManagedFields {
+			b.WriteString(" extractManagedFields=true")
+		}
+		if len(obj.ValuePattern) > 0 {
+			fmt.Fprintf(&b, " valuePattern=%q", obj.ValuePattern)
+		}
+		if len(obj.ExpectedSha256) > 0 {
+			fmt.Fprintf(&b, " expectedSha256=%s", obj.ExpectedSha256)
+		}
+		if len(obj.NewlineStyle) > 0 {
+			fmt.Fprintf(&b, " newlineStyle=%s", obj.NewlineStyle)
+		}
+		if obj.EnsureTrailingNewline {
+			b.WriteString(" ensureTrailingNewline=true")
+		}
+		if len(obj.RequiredKeys) > 0 {
+			fmt.Fprintf(&b, " requiredKeys=%v", obj.RequiredKeys)
+		}
+		if len(obj.JmesBinary) > 0 {
+			fmt.Fprintf(&b, " jmesBinary=%s", obj.JmesBinary)
+		}
+		if len(obj.EnvFile) > 0 {
+			envObj := obj.getEnvFileSecretObject()
+			fmt.Fprintf(&b, " envFile=%q envStrictKeys=%t", envObj.GetMountPath(), obj.EnvStrictKeys)
+		}
+		b.WriteByte('\n')
+
+		for j, jmesPathEntry := range obj.JMESPath {
+			jmesObj := obj.getJmesEntrySecretObject(&jmesPathEntry)
+			fmt.Fprintf(&b, "  jmesPath[%d]: query=%q alias=%q path=%q prettyJSON=%t",
+				j, jmesPathEntry.Path, jmesPathEntry.ObjectAlias, jmesObj.GetMountPath(), jmesPathEntry.PrettyJSON)
+			if jmesPathEntry.FanOut {
+				b.WriteString(" fanOut=true")
+			}
+			if len(jmesPathEntry.MergeInto) > 0 {
+				mergedObj := obj.getMergeFileSecretObject(jmesPathEntry.MergeInto)
+				fmt.Fprintf(&b, " mergeInto=%q", mergedObj.GetMountPath())
+			}
+			if len(jmesPathEntry.Extension) > 0 {
+				fmt.Fprintf(&b, " extension=%s", jmesPathEntry.Extension)
+			}
+			if len(jmesPathEntry.Encoding) > 0 {
+				fmt.Fprintf(&b, " encoding=%s", jmesPathEntry.Encoding)
+			}
+			if jmesPathEntry.TrimSpace {
+				b.WriteString(" trimSpace=true")
+			}
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
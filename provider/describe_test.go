@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescribeSpec(t *testing.T) {
+	spec := `
+- objectName: "acs:kms:cn-beijing:12345678:secret/db"
+  objectAlias: "db"
+  jmesPath:
+    - path: "password"
+      objectAlias: "db-password"
+- objectName: "app/config"
+  objectType: "oos"
+  objectVersionLabel: "ACSCurrent"
+- objectName: "tls"
+  kmsEndpoint: "kst-1.cryptoservice.kms.aliyuncs.com"
+  kmsFallbackEndpoints: ["kms.cn-hangzhou.aliyuncs.com"]
+  jmesPath:
+    - path: "hosts"
+      objectAlias: "host-"
+      fanOut: true
+    - path: "user"
+      objectAlias: "user"
+      mergeInto: "config.json"
+    - path: "config"
+      objectAlias: "config"
+      extension: "yaml"
+      encoding: "base64"
+      trimSpace: true
+`
+	objects, err := NewSecretObjectList("/mnt/secrets", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	p := &SecretsManagerProvider{Region: "cn-hangzhou"}
+	got := p.DescribeSpec(objects)
+
+	for _, want := range []string{
+		`object[0]: name="acs:kms:cn-beijing:12345678:secret/db" type=kms region=cn-beijing alias="db" path="/mnt/secrets/db"`,
+		`jmesPath[0]: query="password" alias="db-password" path="/mnt/secrets/db-password"`,
+		`object[1]: name="app/config" type=oos region=cn-hangzhou path="/mnt/secrets/app_config" versionLabel="ACSCurrent"`,
+		`object[2]: name="tls" type=kms region=cn-hangzhou kmsEndpoint=kst-1.cryptoservice.kms.aliyuncs.com kmsFallbackEndpoints=[kms.cn-hangzhou.aliyuncs.com] path="/mnt/secrets/tls"`,
+		`jmesPath[0]: query="hosts" alias="host-" path="/mnt/secrets/host-" prettyJSON=false fanOut=true`,
+		`jmesPath[1]: query="user" alias="user" path="/mnt/secrets/user" prettyJSON=false mergeInto="/mnt/secrets/config.json"`,
+		`jmesPath[2]: query="config" alias="config" path="/mnt/secrets/config.yaml" prettyJSON=false extension=yaml encoding=base64 trimSpace=true`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("DescribeSpec() = %s, missing %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// An RE pattern for the host name of an endpoint, e.g. kms-vpc.cn-hangzhou.aliyuncs.com.
+var endpointHostRE = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// An RE pattern for the port of an endpoint.
+var portRE = regexp.MustCompile(`^[0-9]{1,5}$`)
+
+// ValidateEndpoint checks that an endpoint is a host name with an optional
+// port, without a scheme or path, as expected by the SDK clients.
+func ValidateEndpoint(endpoint string) error {
+	host := endpoint
+	if strings.Contains(endpoint, ":") {
+		var port string
+		var err error
+		host, port, err = net.SplitHostPort(endpoint)
+		if err != nil || !portRE.MatchString(port) {
+			return fmt.Errorf("Invalid endpoint %q, expected a host name with an optional port", endpoint)
+		}
+	}
+	if !endpointHostRE.MatchString(host) {
+		return fmt.Errorf("Invalid endpoint %q, expected a host name with an optional port", endpoint)
+	}
+	return nil
+}
+
+// ParseRegionEndpointMap parses a comma separated list of region=endpoint
+// pairs, e.g. cn-hangzhou=kms-vpc.cn-hangzhou.aliyuncs.com, validating every
+// endpoint.
+func ParseRegionEndpointMap(s string) (map[string]string, error) {
+	endpoints := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		region, endpoint, ok := strings.Cut(pair, "=")
+		region, endpoint = strings.TrimSpace(region), strings.TrimSpace(endpoint)
+		if !ok || len(region) == 0 {
+			return nil, fmt.Errorf("Invalid region endpoint %q, expected <region>=<endpoint>", pair)
+		}
+		if _, dup := endpoints[region]; dup {
+			return nil, fmt.Errorf("Duplicate endpoint for region %s", region)
+		}
+		if err := ValidateEndpoint(endpoint); err != nil {
+			return nil, err
+		}
+		endpoints[region] = endpoint
+	}
+	return endpoints, nil
+}
+
+// ParseEndpointList parses a comma separated list of endpoints, validating
+// every endpoint.
+func ParseEndpointList(s string) ([]string, error) {
+	var endpoints []string
+	for _, endpoint := range strings.Split(s, ",") {
+		endpoint = strings.TrimSpace(endpoint)
+		if len(endpoint) == 0 {
+			continue
+		}
+		if err := ValidateEndpoint(endpoint); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// Return the KMS endpoint of an object in region: its kmsEndpoint, else the
+// RegionEndpointMap entry of the region, else an empty string for the default.
+func (p *SecretsManagerProvider) kmsEndpointFor(secObj *SecretObject, region string) string {
+	if len(secObj.KmsEndpoint) > 0 {
+		return secObj.KmsEndpoint
+	}
+	return p.RegionEndpointMap[region]
+}
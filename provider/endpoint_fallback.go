@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"k8s.io/klog/v2"
+)
+
+// Check the kmsFallbackEndpoints of the object spec.
+func (s *SecretObject) validateFallbackEndpoints() error {
+	if len(s.KmsFallbackEndpoints) == 0 {
+		return nil
+	}
+	if !s.isKMS() {
+		return fmt.Errorf("kmsFallbackEndpoints is only supported for kms secrets: %s", s.ObjectName)
+	}
+	for _, endpoint := range s.KmsFallbackEndpoints {
+		if err := ValidateEndpoint(endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return the KMS endpoints tried after the endpoint of a KMS secret: its
+// kmsFallbackEndpoints, else the KmsFallbackEndpoints of the provider for
+// secrets in the region of the provider without a kmsEndpoint.
+func (p *SecretsManagerProvider) kmsFallbackEndpointsFor(secObj *SecretObject) []string {
+	if !secObj.isKMS() {
+		return nil
+	}
+	if len(secObj.KmsFallbackEndpoints) > 0 {
+		return secObj.KmsFallbackEndpoints
+	}
+	if region := secObj.getRegion(); len(secObj.KmsEndpoint) > 0 || len(region) > 0 && region != p.Region {
+		return nil
+	}
+	return p.KmsFallbackEndpoints
+}
+
+// Report whether a request failed to reach its endpoint, as opposed to an
+// error answered by the service, such as a denied or missing secret.
+func isConnectivityError(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Fetch a KMS secret from its endpoint, then from each of its fallback
+// endpoints in order while the previous one could not be reached or its
+// circuit breaker is open. Every endpoint gets its own fetch timeout, rate
+// limiter token, retries and circuit breaker.
+func (p *SecretsManagerProvider) fetchKMSSecretWithFallback(ctx context.Context, secObj *SecretObject, fallbacks []string) (ver string, val *SecretValue, err error) {
+	for i := 0; i <= len(fallbacks); i++ {
+		endpointObj := secObj
+		if i > 0 {
+			if ctx.Err() != nil {
+				return "", nil, err
+			}
+			klog.Warningf("kms endpoint of secret %s is unreachable, falling back to %s: %v", secObj.ObjectName, fallbacks[i-1], err)
+			fallbackObj := *secObj
+			fallbackObj.KmsEndpoint = fallbacks[i-1]
+			endpointObj = &fallbackObj
+		}
+		ver, val, err = p.fetchKMSSecretOnce(ctx, endpointObj)
+		if err == nil {
+			val.SecretObj = *secObj
+			return ver, val, nil
+		}
+		if !isConnectivityError(err) && !errors.Is(err, ErrCircuitOpen) {
+			return "", nil, err
+		}
+	}
+	return "", nil, err
+}
+
+// Fetch a KMS secret from the endpoint of secObj within its fetch timeout.
+func (p *SecretsManagerProvider) fetchKMSSecretOnce(ctx context.Context, secObj *SecretObject) (string, *SecretValue, error) {
+	fetchTimeoutCtx, cancel := p.fetchContext(ctx, secObj)
+	defer cancel()
+	if err := waitForToken(fetchTimeoutCtx, LimiterInstance.Kms); err != nil {
+		return "", nil, err
+	}
+	client, err := p.kmsClientFor(secObj)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.getKMSSecret(fetchTimeoutCtx, client, secObj)
+}
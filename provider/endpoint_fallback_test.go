@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestGetSecretValuesKmsFallbackEndpoints(t *testing.T) {
+	setupFetchTest(t)
+	unreachable := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	notFound := &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound")}
+	newClient := func(err error) *mockKmsClient {
+		return &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+			if err != nil {
+				return nil, err
+			}
+			return kmsSecretResponse("value", "v1"), nil
+		}}
+	}
+	tests := []struct {
+		name       string
+		primary    error
+		fallbacks  map[string]error
+		spec       string
+		provider   []string
+		wantErr    bool
+		wantCalled []string
+	}{
+		{"primary-up", nil, map[string]error{"a.internal": nil},
+			`[{"objectName": "s", "kmsFallbackEndpoints": ["a.internal"]}]`, nil, false, nil},
+		{"second-fallback", unreachable, map[string]error{"a.internal": unreachable, "b.internal": nil},
+			`[{"objectName": "s", "kmsFallbackEndpoints": ["a.internal", "b.internal"]}]`, nil, false, []string{"a.internal", "b.internal"}},
+		{"all-down", unreachable, map[string]error{"a.internal": unreachable},
+			`[{"objectName": "s", "kmsFallbackEndpoints": ["a.internal"]}]`, nil, true, []string{"a.internal"}},
+		{"service-error", notFound, map[string]error{"a.internal": nil},
+			`[{"objectName": "s", "kmsFallbackEndpoints": ["a.internal"]}]`, nil, true, nil},
+		{"provider-list", unreachable, map[string]error{"a.internal": nil},
+			`[{"objectName": "s"}]`, []string{"a.internal"}, false, []string{"a.internal"}},
+		{"object-list-wins", unreachable, map[string]error{"a.internal": nil, "b.internal": nil},
+			`[{"objectName": "s", "kmsFallbackEndpoints": ["b.internal"]}]`, []string{"a.internal"}, false, []string{"b.internal"}},
+		{"provider-list-other-region", unreachable, map[string]error{"a.internal": nil},
+			`[{"objectName": "s", "region": "cn-beijing"}]`, []string{"a.internal"}, true, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called []string
+			p := &SecretsManagerProvider{
+				KmsClient:            newClient(tt.primary),
+				Region:               "cn-hangzhou",
+				KmsFallbackEndpoints: tt.provider,
+				FS:                   newMemFileSystem(),
+				NewKmsClient: func(region, endpoint string) (KmsAPI, error) {
+					called = append(called, endpoint)
+					if len(endpoint) == 0 {
+						return newClient(tt.primary), nil
+					}
+					return newClient(tt.fallbacks[endpoint]), nil
+				},
+			}
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			values, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSecretValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(called) != len(tt.wantCalled) {
+				t.Fatalf("tried endpoints %q, want %q", called, tt.wantCalled)
+			}
+			for i := range called {
+				if called[i] != tt.wantCalled[i] {
+					t.Errorf("tried endpoints %q, want %q", called, tt.wantCalled)
+				}
+			}
+			if err == nil && (string(values[0].Value) != "value" || len(values[0].SecretObj.KmsEndpoint) > 0) {
+				t.Errorf("expected the value of the primary object, got %q from %q", values[0].Value, values[0].SecretObj.KmsEndpoint)
+			}
+		})
+	}
+}
+
+func TestKmsFallbackEndpointsCircuitBreaker(t *testing.T) {
+	setupFetchTest(t)
+	breaker, _ := setupBreakerTest(t, 1)
+	primary := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}}
+	fallback := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse("value", "v1"), nil
+	}}
+	p := &SecretsManagerProvider{
+		KmsClient: primary,
+		Region:    "cn-hangzhou",
+		FS:        newMemFileSystem(),
+		NewKmsClient: func(region, endpoint string) (KmsAPI, error) {
+			return fallback, nil
+		},
+	}
+	objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "s", "kmsFallbackEndpoints": ["a.internal"], "maxRetries": 0}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+
+	// The unreachable primary opens its breaker, the fallback still serves the
+	// secret, then keeps serving it without calling the primary again.
+	for i := 0; i < 2; i++ {
+		values, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+		if err != nil || string(values[0].Value) != "value" {
+			t.Fatalf("GetSecretValues() %d = %v, %v, want the value of the fallback", i, values, err)
+		}
+	}
+	if primary.calls != 1 || fallback.calls != 2 {
+		t.Errorf("made %d calls to the primary and %d to the fallback, want 1 and 2", primary.calls, fallback.calls)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected the breaker of the primary to be open, got %v", err)
+	}
+	if err := breaker.forEndpoint("a.internal").Allow(); err != nil {
+		t.Errorf("expected the breaker of the fallback to be closed, got %v", err)
+	}
+}
+
+func TestNewSecretObjectListKmsFallbackEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"valid", `[{"objectName": "s", "kmsFallbackEndpoints": ["kms.a.internal", "kms.b.internal:8443"]}]`, false},
+		{"invalid", `[{"objectName": "s", "kmsFallbackEndpoints": ["https://kms.a.internal"]}]`, true},
+		{"oos", `[{"objectName": "p", "objectType": "oos", "kmsFallbackEndpoints": ["kms.a.internal"]}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+)
+
+func TestParseRegionEndpointMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"pairs", "cn-hangzhou=kms-vpc.cn-hangzhou.aliyuncs.com, cn-beijing=kms.internal:8443", map[string]string{
+			"cn-hangzhou": "kms-vpc.cn-hangzhou.aliyuncs.com",
+			"cn-beijing":  "kms.internal:8443",
+		}, false},
+		{"missing-endpoint", "cn-hangzhou", nil, true},
+		{"missing-region", "=kms.internal", nil, true},
+		{"duplicate-region", "cn-hangzhou=a.internal,cn-hangzhou=b.internal", nil, true},
+		{"scheme", "cn-hangzhou=https://kms.internal", nil, true},
+		{"path", "cn-hangzhou=kms.internal/api", nil, true},
+		{"empty-port", "cn-hangzhou=kms.internal:", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRegionEndpointMap(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRegionEndpointMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseRegionEndpointMap() = %v, want %v", got, tt.want)
+			}
+			for region, endpoint := range tt.want {
+				if got[region] != endpoint {
+					t.Errorf("endpoint of %s = %s, want %s", region, got[region], endpoint)
+				}
+			}
+		})
+	}
+}
+
+func TestParseEndpointList(t *testing.T) {
+	got, err := ParseEndpointList(" kms.a.internal, ,kms.b.internal:8443")
+	if err != nil || len(got) != 2 || got[0] != "kms.a.internal" || got[1] != "kms.b.internal:8443" {
+		t.Errorf("ParseEndpointList() = %q, %v", got, err)
+	}
+	if _, err = ParseEndpointList("kms.a.internal,https://kms.b.internal"); err == nil {
+		t.Errorf("expected an endpoint with a scheme to be rejected")
+	}
+}
+
+func TestKmsClientForEndpoint(t *testing.T) {
+	defaultClient := &kms.Client{}
+	created := make(map[string]string) // region -> endpoint
+	p := &SecretsManagerProvider{
+		KmsClient:         defaultClient,
+		Region:            "cn-hangzhou",
+		RegionEndpointMap: map[string]string{"cn-beijing": "kms.cn-beijing.internal"},
+		NewKmsClient: func(region, endpoint string) (KmsAPI, error) {
+			created[region] = endpoint
+			return &kms.Client{}, nil
+		},
+	}
+
+	tests := []struct {
+		obj          SecretObject
+		wantDefault  bool
+		wantEndpoint string
+	}{
+		{SecretObject{ObjectName: "MySecret"}, true, ""},
+		{SecretObject{ObjectName: "MySecret", Region: "cn-beijing"}, false, "kms.cn-beijing.internal"},
+		{SecretObject{ObjectName: "MySecret", Region: "cn-shanghai"}, false, ""},
+		{SecretObject{ObjectName: "MySecret", Region: "cn-shenzhen", KmsEndpoint: "kms.custom.internal"}, false, "kms.custom.internal"},
+	}
+	for _, tt := range tests {
+		c, err := p.kmsClientFor(&tt.obj)
+		if err != nil {
+			t.Fatalf("kmsClientFor() error = %v", err)
+		}
+		if (c == defaultClient) != tt.wantDefault {
+			t.Errorf("kmsClientFor(%+v) returned the default client: %v, want %v", tt.obj, c == defaultClient, tt.wantDefault)
+		}
+		if !tt.wantDefault && created[tt.obj.getRegion()] != tt.wantEndpoint {
+			t.Errorf("client for %s built with endpoint %q, want %q", tt.obj.getRegion(), created[tt.obj.getRegion()], tt.wantEndpoint)
+		}
+	}
+
+	// The object endpoint wins over the map, even in the mount region.
+	c, err := p.kmsClientFor(&SecretObject{ObjectName: "MySecret", KmsEndpoint: "kms.custom.internal"})
+	if err != nil || c == defaultClient || created["cn-hangzhou"] != "kms.custom.internal" {
+		t.Errorf("expected a client for the object endpoint in the mount region, got %v (%v)", created, err)
+	}
+}
+
+func TestNewSecretObjectListKmsEndpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"kms", `[{"objectName": "a", "kmsEndpoint": "kms.internal:443"}]`, false},
+		{"datakey", `[{"objectName": "key", "objectType": "datakey", "objectAlias": "k", "ciphertextAlias": "k.enc", "kmsEndpoint": "kms.internal"}]`, false},
+		{"oos", `[{"objectName": "a", "objectType": "oos", "kmsEndpoint": "kms.internal"}]`, true},
+		{"invalid", `[{"objectName": "a", "kmsEndpoint": "https://kms.internal"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
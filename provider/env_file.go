@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Keys that are already valid shell identifiers.
+	envKeyRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// Characters that must be replaced when sanitizing a key.
+	envKeyInvalidCharRE = regexp.MustCompile(`[^A-Z0-9_]`)
+	// Values made only of these characters are written without quotes.
+	envBareValueRE = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+)
+
+// Convert an object alias into an environment variable name. Keys are upper
+// cased and any character that is not alphanumeric is replaced by an
+// underscore. When strict is set, aliases that are not already valid
+// identifiers are rejected instead of being rewritten.
+func envKey(alias string, strict bool) (string, error) {
+	if strict {
+		if !envKeyRE.MatchString(alias) {
+			return "", fmt.Errorf("objectAlias %s is not a valid environment variable name", alias)
+		}
+		return strings.ToUpper(alias), nil
+	}
+
+	key := envKeyInvalidCharRE.ReplaceAllString(strings.ToUpper(alias), "_")
+	if len(key) == 0 || strings.Trim(key, "_") == "" {
+		return "", fmt.Errorf("objectAlias %s can not be converted to an environment variable name", alias)
+	}
+	if key[0] >= '0' && key[0] <= '9' {
+		key = "_" + key
+	}
+	return key, nil
+}
+
+// Quote a value for an env file. Values containing anything beyond a safe
+// character set are double quoted with quotes, backslashes, dollar signs,
+// backticks and line breaks escaped.
+func envValue(value string) string {
+	if envBareValueRE.MatchString(value) {
+		return value
+	}
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		`$`, `\$`,
+		"`", "\\`",
+		"\n", `\n`,
+		"\r", `\r`,
+	)
+	return `"` + r.Replace(value) + `"`
+}
+
+// Compute the env keys for every jmesPath entry of the object, failing on any
+// alias that can not be converted or two aliases that map to the same key.
+func (s *SecretObject) envKeys() ([]string, error) {
+	keys := make([]string, 0, len(s.JMESPath))
+	seen := make(map[string]string)
+	for _, jmesPathEntry := range s.JMESPath {
+		key, err := envKey(jmesPathEntry.ObjectAlias, s.EnvStrictKeys)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := seen[key]; ok {
+			return nil, fmt.Errorf("objectAlias %s and %s both map to environment variable %s", prev, jmesPathEntry.ObjectAlias, key)
+		}
+		seen[key] = jmesPathEntry.ObjectAlias
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Combine the values extracted by jmesPath into a single env file.
+func (sv *SecretValue) getEnvFileSecret(jsonSecrets []*SecretValue) (*SecretValue, error) {
+	keys, err := sv.SecretObj.envKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != len(jsonSecrets) {
+		return nil, fmt.Errorf("env file %s does not match the jmesPath entries of %s", sv.SecretObj.EnvFile, sv.SecretObj.ObjectName)
+	}
+
+	var b strings.Builder
+	for i, jsonSecret := range jsonSecrets {
+		b.WriteString(keys[i])
+		b.WriteByte('=')
+		b.WriteString(envValue(string(jsonSecret.Value)))
+		b.WriteByte('\n')
+	}
+
+	return &SecretValue{
+		Value:     []byte(b.String()),
+		SecretObj: sv.SecretObj.getEnvFileSecretObject(),
+	}, nil
+}
@@ -0,0 +1,75 @@
+package provider
+
+import "testing"
+
+func TestEnvKey(t *testing.T) {
+	tests := []struct {
+		alias   string
+		strict  bool
+		want    string
+		wantErr bool
+	}{
+		{"db-host", false, "DB_HOST", false},
+		{"db.password", false, "DB_PASSWORD", false},
+		{"1st", false, "_1ST", false},
+		{"---", false, "", true},
+		{"db_host", true, "DB_HOST", false},
+		{"db-host", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			got, err := envKey(tt.alias, tt.strict)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("envKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("envKey() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEnvFileSecret(t *testing.T) {
+	secretValue := SecretValue{
+		Value: []byte(`{"host": "db.example.com", "password": "p@ss \"word\"\n$HOME"}`),
+		SecretObj: SecretObject{
+			ObjectName: TEST_OBJECT_NAME,
+			EnvFile:    "db.env",
+			JMESPath: []JMESPathObject{
+				{Path: "host", ObjectAlias: "db-host"},
+				{Path: "password", ObjectAlias: "db-password"},
+			},
+		},
+	}
+
+	jsonSecrets, err := secretValue.getJsonSecrets()
+	if err != nil {
+		t.Fatalf("getJsonSecrets() error = %v", err)
+	}
+	envSecret, err := secretValue.getEnvFileSecret(jsonSecrets)
+	if err != nil {
+		t.Fatalf("getEnvFileSecret() error = %v", err)
+	}
+
+	want := "DB_HOST=db.example.com\nDB_PASSWORD=\"p@ss \\\"word\\\"\\n\\$HOME\"\n"
+	if string(envSecret.Value) != want {
+		t.Errorf("getEnvFileSecret() got = %q, want %q", envSecret.Value, want)
+	}
+	if envSecret.SecretObj.GetFileName() != "db.env" {
+		t.Errorf("getEnvFileSecret() file name = %s, want db.env", envSecret.SecretObj.GetFileName())
+	}
+}
+
+func TestEnvFileKeyCollision(t *testing.T) {
+	s := &SecretObject{
+		ObjectName: TEST_OBJECT_NAME,
+		EnvFile:    "db.env",
+		JMESPath: []JMESPathObject{
+			{Path: "a", ObjectAlias: "db-host"},
+			{Path: "b", ObjectAlias: "db.host"},
+		},
+	}
+	if err := s.validateSecretObject(); err == nil {
+		t.Fatalf("expected error for colliding env keys")
+	}
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+	sdkErr "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+)
+
+// Error codes returned by KMS and OOS when the secret or parameter does not exist.
+var notFoundErrorCodes = []string{
+	"Forbidden.ResourceNotFound",
+	"EntityNotExists",
+}
+
+// Extract the service error code from an SDK error, or an empty string if the
+// error did not come from the service.
+func getErrorCode(err error) string {
+	var teaErr *tea.SDKError
+	if errors.As(err, &teaErr) {
+		return tea.StringValue(teaErr.Code)
+	}
+	var clientErr *sdkErr.ClientError
+	if errors.As(err, &clientErr) {
+		return clientErr.ErrorCode()
+	}
+	var serverErr *sdkErr.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.ErrorCode()
+	}
+	return ""
+}
+
+// Report whether the error means the secret, parameter or local file does not exist.
+// Throttling, permission and other transient errors are never treated as not found.
+func isNotFound(err error) bool {
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	code := getErrorCode(err)
+	for _, notFound := range notFoundErrorCodes {
+		if code == notFound || strings.HasPrefix(code, notFound+".") {
+			return true
+		}
+	}
+	var teaErr *tea.SDKError
+	if len(code) == 0 && errors.As(err, &teaErr) {
+		return tea.IntValue(teaErr.StatusCode) == http.StatusNotFound
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"kms-not-found", &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound")}, true},
+		{"oos-not-found", &tea.SDKError{Code: tea.String("EntityNotExists.Parameter")}, true},
+		{"wrapped-not-found", fmt.Errorf("Failed fetching secret s: %w", &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound")}), true},
+		{"http-not-found", &tea.SDKError{StatusCode: tea.Int(404)}, true},
+		{"throttling", &tea.SDKError{Code: tea.String(REJECTED_THROTTLING), StatusCode: tea.Int(404)}, false},
+		{"access-denied", &tea.SDKError{Code: tea.String("Forbidden.NoPermission")}, false},
+		{"plain-error", fmt.Errorf("connection reset"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotFound(tt.err); got != tt.want {
+				t.Errorf("isNotFound() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessDenied(t *testing.T) {
+	setupFetchTest(t)
+	denied := &tea.SDKError{Code: tea.String("Forbidden.NoPermission"), Message: tea.String("not authorized")}
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return nil, denied
+	}}
+	p := &SecretsManagerProvider{Region: "cn-hangzhou", KmsClient: client}
+	_, _, err := p.getKMSSecret(context.TODO(), client, &SecretObject{ObjectName: "db-password"})
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+	if client.calls != 1 {
+		t.Errorf("expected no retry of an access denied error, got %d calls", client.calls)
+	}
+	for _, want := range []string{"kms:GetSecretValue", "acs:kms:cn-hangzhou:*:secret/db-password", "Forbidden.NoPermission"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in the error, got %v", want, err)
+		}
+	}
+	if getErrorCode(err) != "Forbidden.NoPermission" {
+		t.Errorf("expected the SDK error code to stay available, got %q", getErrorCode(err))
+	}
+}
+
+func TestResourceARN(t *testing.T) {
+	p := &SecretsManagerProvider{Region: "cn-hangzhou"}
+	tests := []struct {
+		name   string
+		secObj SecretObject
+		want   string
+	}{
+		{"kms", SecretObject{ObjectName: "s"}, "acs:kms:cn-hangzhou:*:secret/s"},
+		{"kms-region", SecretObject{ObjectName: "s", Region: "cn-beijing"}, "acs:kms:cn-beijing:*:secret/s"},
+		{"kms-arn", SecretObject{ObjectName: "acs:kms:cn-beijing:123:secret/s"}, "acs:kms:cn-beijing:123:secret/s"},
+		{"oos", SecretObject{ObjectName: "p", ObjectType: ObjectTypeOOS}, "acs:oos:cn-hangzhou:*:secretparameter/p"},
+		{"datakey", SecretObject{ObjectName: "k", ObjectType: ObjectTypeDataKey}, "acs:kms:cn-hangzhou:*:key/k"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.resourceARN(&tt.secObj); got != tt.want {
+				t.Errorf("resourceARN() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// Reasons of a FetchError, a stable classification of the error independent
+// of the service error codes.
+const (
+	FetchReasonNotFound     = "NotFound"
+	FetchReasonAccessDenied = "AccessDenied"
+	FetchReasonCircuitOpen  = "CircuitOpen"
+	FetchReasonTimeout      = "Timeout"
+	FetchReasonUnreachable  = "Unreachable"
+	FetchReasonFailed       = "Failed"
+)
+
+// FetchError is the error of a mount or GetSecret call failed by an object. It
+// wraps the error of the object, whose message it keeps, and marshals to JSON
+// for programs acting on failures without matching messages:
+//
+//	{"objectName": "db", "objectType": "kms", "reason": "AccessDenied",
+//	 "code": "Forbidden.NoPermission", "retryable": false, "message": "..."}
+//
+// A slice of FetchError marshals to an array, e.g. for the failures of several
+// mounts. Neither the error nor its JSON ever holds secret values.
+type FetchError struct {
+	ObjectName string
+	ObjectType string
+
+	// One of the FetchReason values.
+	Reason string
+
+	// Error code returned by KMS or OOS, empty for errors of the provider.
+	Code string
+
+	// The same fetch may succeed later, e.g. after throttling or when the
+	// backend is reachable again, as opposed to a missing secret or a denied
+	// permission.
+	Retryable bool
+
+	err error
+}
+
+func (e *FetchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.err
+}
+
+// MarshalJSON renders the error with a stable set of fields.
+func (e *FetchError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ObjectName string `json:"objectName"`
+		ObjectType string `json:"objectType"`
+		Reason     string `json:"reason"`
+		Code       string `json:"code,omitempty"`
+		Retryable  bool   `json:"retryable"`
+		Message    string `json:"message"`
+	}{e.ObjectName, e.ObjectType, e.Reason, e.Code, e.Retryable, e.Error()})
+}
+
+// Wrap the error failing the object in a FetchError, unless it is one already.
+func (p *SecretsManagerProvider) fetchError(objectName, objectType string, err error) error {
+	var fetchErr *FetchError
+	if err == nil || errors.As(err, &fetchErr) {
+		return err
+	}
+	if len(objectType) == 0 {
+		objectType = ObjectTypeKMS
+	}
+	fetchErr = &FetchError{ObjectName: objectName, ObjectType: objectType, Reason: FetchReasonFailed, Code: getErrorCode(err), err: err}
+	switch {
+	case errors.Is(err, ErrAccessDenied) || isAccessDenied(err):
+		fetchErr.Reason = FetchReasonAccessDenied
+	case isNotFound(err):
+		fetchErr.Reason = FetchReasonNotFound
+	case errors.Is(err, ErrCircuitOpen):
+		fetchErr.Reason, fetchErr.Retryable = FetchReasonCircuitOpen, true
+	case errors.Is(err, context.DeadlineExceeded):
+		fetchErr.Reason, fetchErr.Retryable = FetchReasonTimeout, true
+	case isConnectivityError(err):
+		fetchErr.Reason, fetchErr.Retryable = FetchReasonUnreachable, true
+	default:
+		fetchErr.Retryable = p.judgeNeedRetry(err)
+	}
+	return fetchErr
+}
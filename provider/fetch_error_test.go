@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestFetchError(t *testing.T) {
+	setupFetchTest(t)
+	tests := []struct {
+		name          string
+		err           error
+		wantReason    string
+		wantCode      string
+		wantRetryable bool
+	}{
+		{"access-denied", &tea.SDKError{Code: tea.String("Forbidden.NoPermission")}, FetchReasonAccessDenied, "Forbidden.NoPermission", false},
+		{"not-found", &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound")}, FetchReasonNotFound, "Forbidden.ResourceNotFound", false},
+		{"throttled", &tea.SDKError{Code: tea.String(REJECTED_THROTTLING)}, FetchReasonFailed, REJECTED_THROTTLING, true},
+		{"deadline", context.DeadlineExceeded, FetchReasonTimeout, "", true},
+		{"other", errors.New("boom"), FetchReasonFailed, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				return nil, tt.err
+			}}
+			p := &SecretsManagerProvider{KmsClient: client, MaxRetries: new(int)}
+			objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "ok", "objectType": "oos"}, {"objectName": "db"}]`, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			p.OosClient = newVersionedOosClient("value")
+			_, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+			var fetchErr *FetchError
+			if !errors.As(err, &fetchErr) {
+				t.Fatalf("expected a FetchError, got %v", err)
+			}
+			if fetchErr.ObjectName != "db" || fetchErr.ObjectType != ObjectTypeKMS || fetchErr.Reason != tt.wantReason ||
+				fetchErr.Code != tt.wantCode || fetchErr.Retryable != tt.wantRetryable {
+				t.Errorf("unexpected FetchError %+v", fetchErr)
+			}
+			if !errors.Is(err, tt.err) && !strings.Contains(err.Error(), tt.err.Error()) {
+				t.Errorf("expected the error of the object to be wrapped, got %v", err)
+			}
+
+			encoded, err := json.Marshal(fetchErr)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			var decoded map[string]interface{}
+			if err = json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if decoded["objectName"] != "db" || decoded["reason"] != tt.wantReason || decoded["retryable"] != tt.wantRetryable ||
+				decoded["message"] != fetchErr.Error() {
+				t.Errorf("unexpected JSON %s", encoded)
+			}
+		})
+	}
+}
+
+func TestGetSecretFetchError(t *testing.T) {
+	setupFetchTest(t)
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return nil, &tea.SDKError{Code: tea.String("Forbidden.NoPermission")}
+	}}
+	p := &SecretsManagerProvider{KmsClient: client}
+	_, _, err := p.GetSecret(context.Background(), &SecretObject{ObjectName: "db"})
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.Reason != FetchReasonAccessDenied || !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("expected an access denied FetchError, got %v", err)
+	}
+}
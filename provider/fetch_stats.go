@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// Sources of the value of an object in an ObjectFetchStat.
+const (
+	FetchSourceFetched = "fetched" // Pulled from the backend
+	FetchSourceMounted = "mounted" // Reloaded from the mount, its version is current
+	FetchSourceStale   = "stale"   // Reloaded from the mount while the circuit breaker is open
+	FetchSourceSkipped = "skipped" // An optional object that does not exist
+)
+
+// ObjectFetchStat records how the value of an object was obtained, for
+// structured logs and SLO tracking. It never holds secret material.
+type ObjectFetchStat struct {
+	ObjectName string
+	ObjectType string
+
+	// Time spent on the object, from the version check to its derived files.
+	Duration time.Duration
+
+	// Requests made for the object, including retries and version lookups.
+	Attempts int
+
+	// One of the FetchSource values, empty when the object failed before its
+	// value was obtained.
+	Source string
+
+	// Error that failed the mount while handling the object, empty on success.
+	Error string
+
+	start time.Time
+}
+
+type fetchStatKey struct{}
+
+// FetchStats returns a record per object handled by the last GetSecretValues
+// or GetChangedSecretValues call, in spec order, for successful and failed
+// calls alike. A failed call has no record for the objects after the failing
+// one. With batch retries the records are those of the last attempt.
+func (p *SecretsManagerProvider) FetchStats() []ObjectFetchStat {
+	stats := make([]ObjectFetchStat, 0, len(p.fetchStats))
+	for _, stat := range p.fetchStats {
+		stats = append(stats, *stat)
+	}
+	return stats
+}
+
+// Start the record of an object, returning it with a context counting the
+// requests made for the object.
+func (p *SecretsManagerProvider) startFetchStat(ctx context.Context, secObj *SecretObject) (context.Context, *ObjectFetchStat) {
+	objectType := secObj.ObjectType
+	if len(objectType) == 0 {
+		objectType = ObjectTypeKMS
+	}
+	stat := &ObjectFetchStat{ObjectName: secObj.ObjectName, ObjectType: objectType, start: time.Now()}
+	p.fetchStats = append(p.fetchStats, stat)
+	return context.WithValue(ctx, fetchStatKey{}, stat), stat
+}
+
+// Complete the record of an object, with the error that failed it if any.
+func (stat *ObjectFetchStat) finish(err error) {
+	stat.Duration = time.Since(stat.start)
+	if err != nil {
+		stat.Error = err.Error()
+	}
+}
+
+// Count a request made for the object whose record is in ctx, if any.
+func countAttempt(ctx context.Context) {
+	if stat, ok := ctx.Value(fetchStatKey{}).(*ObjectFetchStat); ok {
+		stat.Attempts++
+	}
+}
@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestFetchStats(t *testing.T) {
+	setupFetchTest(t)
+	throttled := false
+	kmsClient := &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		switch tea.StringValue(request.SecretName) {
+		case "retried":
+			if !throttled {
+				throttled = true
+				return nil, &tea.SDKError{Code: tea.String(REJECTED_THROTTLING)}
+			}
+		case "missing", "required":
+			return nil, &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound")}
+		}
+		return kmsSecretResponse("value-"+tea.StringValue(request.SecretName), "v1"), nil
+	}}
+	fs := newMemFileSystem()
+	fs.WriteFile("/mnt/mounted", []byte("value-mounted"), 0644)
+	p := &SecretsManagerProvider{KmsClient: kmsClient, FS: fs}
+	spec := `
+- objectName: "retried"
+- objectName: "missing"
+  required: false
+- objectName: "mounted"
+  objectVersion: "v1"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := map[string]*v1alpha1.ObjectVersion{"mounted": {Id: "mounted", Version: "v1"}}
+	if _, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	want := []ObjectFetchStat{
+		{ObjectName: "retried", ObjectType: ObjectTypeKMS, Attempts: 2, Source: FetchSourceFetched},
+		{ObjectName: "missing", ObjectType: ObjectTypeKMS, Attempts: 1, Source: FetchSourceSkipped},
+		{ObjectName: "mounted", ObjectType: ObjectTypeKMS, Attempts: 0, Source: FetchSourceMounted},
+	}
+	stats := p.FetchStats()
+	if len(stats) != len(want) {
+		t.Fatalf("FetchStats() = %+v, want %d records", stats, len(want))
+	}
+	for i, w := range want {
+		got := stats[i]
+		if got.ObjectName != w.ObjectName || got.ObjectType != w.ObjectType || got.Attempts != w.Attempts || got.Source != w.Source || len(got.Error) > 0 {
+			t.Errorf("FetchStats()[%d] = %+v, want %+v", i, got, w)
+		}
+		if got.Duration <= 0 {
+			t.Errorf("FetchStats()[%d] has no duration", i)
+		}
+	}
+
+	// A failed call records the failing object and none after it.
+	objects, _ = NewSecretObjectList("/mnt", "", "", `[{"objectName": "retried"}, {"objectName": "required"}, {"objectName": "mounted"}]`, PodMetadata{})
+	if _, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion)); err == nil {
+		t.Fatalf("expected the missing required object to fail the mount")
+	}
+	stats = p.FetchStats()
+	if len(stats) != 2 || len(stats[0].Error) > 0 || stats[1].ObjectName != "required" || len(stats[1].Error) == 0 || len(stats[1].Source) > 0 {
+		t.Fatalf("FetchStats() = %+v, want the failed object last", stats)
+	}
+	for _, stat := range stats {
+		if strings.Contains(stat.Error, "value-") {
+			t.Errorf("FetchStats() holds a secret value: %+v", stat)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ParseFileMode parses an octal file mode such as 0440, as used by the
+// fileMode of objects and by the fileMode and umask of mounts.
+func ParseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("Invalid file mode %q, expected an octal mode such as 0440", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// SecretFiles converts the values returned by GetSecretValues into the files
+// of the mount. A file gets the fileMode of its object, else DefaultFileMode,
+// else perm (the permission requested by the driver), with the FileUmask
+// bits cleared.
+func (p *SecretsManagerProvider) SecretFiles(values []*SecretValue, perm os.FileMode) []*SecretFile {
+	if p.DefaultFileMode != nil {
+		perm = *p.DefaultFileMode
+	}
+	files := make([]*SecretFile, 0, len(values))
+	for _, sv := range values {
+		mode := perm
+		if sv.SecretObj.fileMode != nil {
+			mode = *sv.SecretObj.fileMode
+		}
+		files = append(files, &SecretFile{
+			Value:    sv.Value,
+			Path:     sv.SecretObj.GetFileName(),
+			FileMode: int32(mode &^ p.FileUmask),
+		})
+	}
+	return files
+}
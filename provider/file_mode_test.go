@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"0440", 0440, false},
+		{"640", 0640, false},
+		{"0", 0, false},
+		{"0777", 0777, false},
+		{"01777", 0, true},
+		{"0448", 0, true},
+		{"rw-r-----", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseFileMode(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFileMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFileMode() = %o, want %o", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretFilesMode(t *testing.T) {
+	setupFetchTest(t)
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(`{"user": "admin"}`, "v1"), nil
+	}}
+	spec := `
+- objectName: "private"
+  fileMode: "0400"
+  jmesPath:
+    - path: "user"
+      objectAlias: "private-user"
+- objectName: "shared"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	mode := os.FileMode(0440)
+	tests := []struct {
+		name        string
+		defaultMode *os.FileMode
+		umask       os.FileMode
+		want        map[string]int32
+	}{
+		{"driver-permission", nil, 0, map[string]int32{"private": 0400, "private-user": 0400, "shared": 0644}},
+		{"mount-default", &mode, 0, map[string]int32{"private": 0400, "private-user": 0400, "shared": 0440}},
+		{"umask", &mode, 0066, map[string]int32{"private": 0400, "private-user": 0400, "shared": 0400}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &SecretsManagerProvider{KmsClient: client, DefaultFileMode: tt.defaultMode, FileUmask: tt.umask}
+			values, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			files := p.SecretFiles(values, 0644)
+			if len(files) != len(tt.want) {
+				t.Fatalf("SecretFiles() returned %d files, want %d", len(files), len(tt.want))
+			}
+			for _, file := range files {
+				if file.FileMode != tt.want[file.Path] {
+					t.Errorf("mode of %s = %o, want %o", file.Path, file.FileMode, tt.want[file.Path])
+				}
+			}
+		})
+	}
+
+	if _, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "s", "fileMode": "0999"}]`, PodMetadata{}); err == nil {
+		t.Errorf("expected an error for an invalid fileMode")
+	}
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// LocalFileSourceDir enables objects of type file, read from files under
+// this directory instead of KMS or OOS, for local development and tests
+// without Alibaba Cloud access. Empty, the default, rejects file objects.
+var LocalFileSourceDir = ""
+
+// isLocalFile reports whether the object is read from LocalFileSourceDir.
+func (s *SecretObject) isLocalFile() bool {
+	return s.ObjectType == ObjectTypeFile
+}
+
+// Check the fields of a file object. The objectName is a path relative to
+// LocalFileSourceDir, and none of the options working on cloud versions or
+// clients apply to a local file.
+func (s *SecretObject) validateLocalFile() error {
+	if len(LocalFileSourceDir) == 0 {
+		return fmt.Errorf("objectType %s is disabled, start the provider with --local-file-source-dir to read object %s from a local file", ObjectTypeFile, s.ObjectName)
+	}
+	if _, err := localFilePath(LocalFileSourceDir, s.ObjectName); err != nil {
+		return err
+	}
+	if len(s.ObjectVersion) > 0 || len(s.ObjectVersionLabel) > 0 || s.AlwaysLatest || s.FailDuringRotation || s.IncludePreviousVersion ||
+		len(s.Region) > 0 || len(s.AssumeRole) > 0 || len(s.EncryptionContext) > 0 {
+		return fmt.Errorf("objectVersion, objectVersionLabel, alwaysLatest, failDuringRotation, includePreviousVersion, region, assumeRole and encryptionContext are not supported for file object: %s", s.ObjectName)
+	}
+	return nil
+}
+
+// Join a relative object name to the directory, failing when the result would
+// be outside of it.
+func localFilePath(dir, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("File object %s must be relative to the local file source directory", name)
+	}
+	path := filepath.Join(dir, name)
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("File object %s is outside of the local file source directory", name)
+	}
+	return path, nil
+}
+
+// Read the value of a file object. Symbolic links are resolved first, so a
+// link can not point outside of LocalFileSourceDir either. The version is
+// derived from the content, so a changed file is a new version.
+func (p *SecretsManagerProvider) getLocalFileSecret(ctx context.Context, secObj *SecretObject) (string, *SecretValue, error) {
+	if len(LocalFileSourceDir) == 0 {
+		return "", nil, fmt.Errorf("objectType %s is disabled: %s", ObjectTypeFile, secObj.ObjectName)
+	}
+	countAttempt(ctx)
+	dir, err := filepath.EvalSymlinks(LocalFileSourceDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed reading the local file source directory: %w", err)
+	}
+	path, err := localFilePath(dir, secObj.ObjectName)
+	if err != nil {
+		return "", nil, err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed reading file object %s: %w", secObj.ObjectName, err)
+	}
+	if rel, err := filepath.Rel(dir, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", nil, fmt.Errorf("File object %s links outside of the local file source directory", secObj.ObjectName)
+	}
+	value, err := ioutil.ReadFile(resolved)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed reading file object %s: %w", secObj.ObjectName, err)
+	}
+	digest := sha256.Sum256(value)
+	return hex.EncodeToString(digest[:8]), &SecretValue{Value: value, SecretObj: *secObj}, nil
+}
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// Enable file objects under a temporary directory holding the given files.
+func setupLocalFileSource(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, value := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldDir := LocalFileSourceDir
+	t.Cleanup(func() { LocalFileSourceDir = oldDir })
+	LocalFileSourceDir = dir
+	return dir
+}
+
+func TestLocalFileSource(t *testing.T) {
+	setupLocalFileSource(t, map[string]string{"db/credentials": `{"user": "admin", "password": "secret"}`})
+	spec := `
+- objectName: "db/credentials"
+  objectType: "file"
+  jmesPath:
+    - path: "password"
+      objectAlias: "db-password"
+- objectName: "db/missing"
+  objectType: "file"
+  required: false
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	p := &SecretsManagerProvider{FS: newMemFileSystem()}
+	secrets, err := p.GetSecretValues(context.Background(), objects, map[string]*v1alpha1.ObjectVersion{})
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	got := make(map[string]string)
+	for _, secret := range secrets {
+		got[secret.SecretObj.GetFileName()] = string(secret.Value)
+	}
+	if got["db_credentials"] != `{"user": "admin", "password": "secret"}` || got["db-password"] != "secret" || len(got) != 2 {
+		t.Errorf("GetSecretValues() = %v", got)
+	}
+}
+
+func TestLocalFileSourceVersion(t *testing.T) {
+	dir := setupLocalFileSource(t, map[string]string{"a": "one"})
+	p := &SecretsManagerProvider{}
+	secObj := &SecretObject{ObjectName: "a", ObjectType: ObjectTypeFile}
+	v1, _, err := p.getLocalFileSecret(context.Background(), secObj)
+	if err != nil {
+		t.Fatalf("getLocalFileSecret() error = %v", err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "a"), []byte("two"), 0644)
+	v2, secret, err := p.getLocalFileSecret(context.Background(), secObj)
+	if err != nil {
+		t.Fatalf("getLocalFileSecret() error = %v", err)
+	}
+	if v1 == v2 || string(secret.Value) != "two" {
+		t.Errorf("expected a new version for changed content, got %s and %s with %q", v1, v2, secret.Value)
+	}
+}
+
+func TestLocalFileSourceDisabled(t *testing.T) {
+	oldDir := LocalFileSourceDir
+	t.Cleanup(func() { LocalFileSourceDir = oldDir })
+	LocalFileSourceDir = ""
+	_, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "a", "objectType": "file"}]`, PodMetadata{})
+	if err == nil || !strings.Contains(err.Error(), "--local-file-source-dir") {
+		t.Errorf("expected file objects to be rejected, got %v", err)
+	}
+}
+
+func TestLocalFileSourceValidation(t *testing.T) {
+	setupLocalFileSource(t, nil)
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"relative", `[{"objectName": "app/db", "objectType": "file"}]`, false},
+		{"at-sign", `[{"objectName": "user@host", "objectType": "file"}]`, false},
+		{"absolute", `[{"objectName": "/etc/passwd", "objectType": "file", "objectAlias": "p"}]`, true},
+		{"parent", `[{"objectName": "../secret", "objectType": "file", "objectAlias": "p"}]`, true},
+		{"inner-parent", `[{"objectName": "a/../../secret", "objectType": "file", "objectAlias": "p"}]`, true},
+		{"version", `[{"objectName": "a", "objectType": "file", "objectVersion": "v1"}]`, true},
+		{"assume-role", `[{"objectName": "a", "objectType": "file", "assumeRole": "acs:ram::123:role/r"}]`, true},
+		{"kms-endpoint", `[{"objectName": "a", "objectType": "file", "kmsEndpoint": "kms.example.com"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalFileSourceSymlink(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "outside")
+	ioutil.WriteFile(outside, []byte("secret"), 0644)
+	dir := setupLocalFileSource(t, nil)
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	p := &SecretsManagerProvider{}
+	_, _, err := p.getLocalFileSecret(context.Background(), &SecretObject{ObjectName: "link", ObjectType: ObjectTypeFile})
+	if err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("expected a link outside of the directory to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// FileSystem abstracts the file system access of the provider so it can be
+// replaced, e.g. by an in-memory implementation in tests.
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	MkdirAll(path string, perm os.FileMode) error
+	Chmod(name string, mode os.FileMode) error
+	Chown(name string, uid, gid int) error
+}
+
+// The FileSystem backed by the operating system.
+type osFileSystem struct{}
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) { return ioutil.ReadFile(name) }
+
+func (osFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(name, data, perm)
+}
+
+func (osFileSystem) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+
+func (osFileSystem) Chmod(name string, mode os.FileMode) error { return os.Chmod(name, mode) }
+
+func (osFileSystem) Chown(name string, uid, gid int) error { return os.Chown(name, uid, gid) }
+
+// Return the file system of the provider, defaulting to the OS.
+func (p *SecretsManagerProvider) fs() FileSystem {
+	if p.FS == nil {
+		return osFileSystem{}
+	}
+	return p.FS
+}
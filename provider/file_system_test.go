@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// An in-memory FileSystem for tests.
+type memFileSystem struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	modes map[string]os.FileMode
+}
+
+func newMemFileSystem() *memFileSystem {
+	return &memFileSystem{files: make(map[string][]byte), modes: make(map[string]os.FileMode)}
+}
+
+func (m *memFileSystem) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (m *memFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[filepath.Clean(name)] = append([]byte(nil), data...)
+	m.modes[filepath.Clean(name)] = perm
+	return nil
+}
+
+func (m *memFileSystem) MkdirAll(path string, perm os.FileMode) error { return nil }
+
+func (m *memFileSystem) Chmod(name string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[filepath.Clean(name)]; !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+	m.modes[filepath.Clean(name)] = mode
+	return nil
+}
+
+func (m *memFileSystem) Chown(name string, uid, gid int) error { return nil }
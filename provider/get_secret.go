@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetSecret fetches the value of a single KMS secret or OOS parameter for
+// library consumers that only read it, such as admission webhooks. It never
+// reads or writes the file system, needs no mount directory, and records no
+// version: there is no current version map nor reload of a mounted value.
+// trimSpace, failOnEmpty, valuePattern, expectedSha256, failDuringRotation and
+// the Processors of the provider apply like on a mount, while file name
+// settings and jmesPath entries are ignored. Data keys are not supported, since every call generates a new key.
+// Errors are returned as a FetchError.
+func (p *SecretsManagerProvider) GetSecret(ctx context.Context, secObj *SecretObject) (value []byte, version string, e error) {
+	obj := *secObj // Validation fills in parsed fields, leave the caller's copy alone
+	defer func() { e = p.fetchError(obj.ObjectName, obj.ObjectType, e) }()
+	if err := obj.validateSecretObject(); err != nil {
+		return nil, "", err
+	}
+	if obj.isDataKey() {
+		return nil, "", fmt.Errorf("GetSecret does not support datakey object: %s", obj.ObjectName)
+	}
+	if obj.FailDuringRotation {
+		if err := p.checkRotation(ctx, &obj); err != nil {
+			return nil, "", err
+		}
+	}
+	version, secret, err := p.fetchSecret(ctx, &obj)
+	if err != nil {
+		return nil, "", err
+	}
+	secret.transform()
+	if err = secret.validateValue(); err != nil {
+		return nil, "", err
+	}
+	if err = p.process(ctx, &obj, secret); err != nil {
+		return nil, "", err
+	}
+	if err = secret.checkRequiredKeys(); err != nil {
+		return nil, "", err
+	}
+	if err = secret.applyJQFilter(ctx); err != nil {
+		return nil, "", err
+	}
+	return secret.Value, version, nil
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+)
+
+// A FileSystem failing the test on any access.
+type forbiddenFileSystem struct{ t *testing.T }
+
+var errForbiddenFileSystem = errors.New("file system access")
+
+func (f forbiddenFileSystem) fail(name string) error {
+	f.t.Errorf("unexpected file system access to %s", name)
+	return errForbiddenFileSystem
+}
+
+func (f forbiddenFileSystem) ReadFile(name string) ([]byte, error) { return nil, f.fail(name) }
+func (f forbiddenFileSystem) WriteFile(name string, _ []byte, _ os.FileMode) error {
+	return f.fail(name)
+}
+func (f forbiddenFileSystem) MkdirAll(path string, _ os.FileMode) error { return f.fail(path) }
+func (f forbiddenFileSystem) Chmod(name string, _ os.FileMode) error    { return f.fail(name) }
+func (f forbiddenFileSystem) Chown(name string, _, _ int) error         { return f.fail(name) }
+
+func TestGetSecret(t *testing.T) {
+	setupFetchTest(t)
+	kmsClient := &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(" value ", "v3"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: kmsClient, OosClient: newVersionedOosClient("one", "two"), FS: forbiddenFileSystem{t}}
+	tests := []struct {
+		name        string
+		secObj      *SecretObject
+		wantValue   string
+		wantVersion string
+		wantErr     bool
+	}{
+		{"kms", &SecretObject{ObjectName: "s"}, " value ", "v3", false},
+		{"trim", &SecretObject{ObjectName: "s", TrimSpace: true}, "value", "v3", false},
+		{"oos", &SecretObject{ObjectName: "p", ObjectType: ObjectTypeOOS}, "two", "2", false},
+		{"value-pattern", &SecretObject{ObjectName: "s", ValuePattern: "^[0-9]+$"}, "", "", true},
+		{"datakey", &SecretObject{ObjectName: "key", ObjectType: ObjectTypeDataKey, ObjectAlias: "k", CiphertextAlias: "k.enc"}, "", "", true},
+		{"invalid", &SecretObject{}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, version, err := p.GetSecret(context.Background(), tt.secObj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(value) != tt.wantValue || version != tt.wantVersion {
+				t.Errorf("GetSecret() = %q, %s, want %q, %s", value, version, tt.wantValue, tt.wantVersion)
+			}
+		})
+	}
+}
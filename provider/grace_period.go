@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// Prefix of the current version map entries recording the previous versions
+// of version-stamped objects whose files are retained, as
+// retained:<file name>@<version>, with the end of the grace period as version.
+const retainedKeyPrefix = "retained:"
+
+// Check the previousFileGracePeriod of the object spec and parse the duration.
+// Only a version-stamped file changes its name with a new version, the file of
+// any other object is replaced in place.
+func (s *SecretObject) validatePreviousFileGracePeriod() (err error) {
+	if len(s.PreviousFileGracePeriod) == 0 {
+		return nil
+	}
+	s.previousFileGracePeriod, err = time.ParseDuration(s.PreviousFileGracePeriod)
+	if err != nil || s.previousFileGracePeriod <= 0 {
+		return fmt.Errorf("Invalid previousFileGracePeriod %q for object %s, expected a positive duration such as 10m", s.PreviousFileGracePeriod, s.ObjectName)
+	}
+	if !s.hasVersionPlaceholder() {
+		return fmt.Errorf("previousFileGracePeriod of object %s requires an objectAlias containing %s", s.ObjectName, versionPlaceholder)
+	}
+	return nil
+}
+
+// Prefix of the retained entries of the object in the current version map.
+func (s *SecretObject) retainedKeyPrefix() string {
+	return retainedKeyPrefix + s.GetFileName() + "@"
+}
+
+// Return the files of the previous versions of a version-stamped object which
+// are still in their grace period, read back from the mount. A new version
+// starts the grace period of the one it replaces; expired versions, and those
+// whose file can no longer be read, are dropped from the current version map
+// so the driver removes their files.
+func (p *SecretsManagerProvider) retainedPreviousFiles(secObj *SecretObject, prior *v1alpha1.ObjectVersion, version string,
+	curMap map[string]*v1alpha1.ObjectVersion, fileNames map[string]string, now time.Time) []*SecretValue {
+	if secObj.previousFileGracePeriod == 0 {
+		return nil
+	}
+	prefix := secObj.retainedKeyPrefix()
+	if prior != nil && prior.Version != version {
+		key := prefix + prior.Version
+		curMap[key] = &v1alpha1.ObjectVersion{
+			Id:      key,
+			Version: now.Add(secObj.previousFileGracePeriod).UTC().Format(time.RFC3339),
+		}
+	}
+
+	var keys []string
+	for key := range curMap {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys) // Stable order of the files
+	var retained []*SecretValue
+	for _, key := range keys {
+		oldVersion := strings.TrimPrefix(key, prefix)
+		expiry, err := time.Parse(time.RFC3339, curMap[key].Version)
+		if oldVersion == version || err != nil || !now.Before(expiry) {
+			delete(curMap, key)
+			continue
+		}
+		oldObj := secObj.withVersion(oldVersion)
+		if _, ok := fileNames[oldObj.GetFileName()]; ok {
+			delete(curMap, key)
+			continue
+		}
+		data, err := p.readMounted(&oldObj)
+		if err != nil {
+			klog.Warningf("dropping the previous file %s of %s: %v", oldObj.GetFileName(), secObj.ObjectName, err)
+			delete(curMap, key)
+			continue
+		}
+		value := &SecretValue{Value: data, SecretObj: oldObj}
+		value.setSource(secObj, oldVersion)
+		retained = append(retained, value)
+		fileNames[oldObj.GetFileName()] = secObj.ObjectName
+	}
+	return retained
+}
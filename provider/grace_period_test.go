@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestPreviousFileGracePeriod(t *testing.T) {
+	for _, batchRetries := range []int{0, 1} {
+		t.Run(fmt.Sprintf("batchRetries=%d", batchRetries), func(t *testing.T) {
+			setupFetchTest(t)
+			oldRetries := BatchRetries
+			defer func() { BatchRetries = oldRetries }()
+			BatchRetries = batchRetries
+			client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				return kmsSecretResponse("new", "v2"), nil
+			}}
+			fs := newMemFileSystem()
+			fs.WriteFile("/mnt/db-v1", []byte("old"), 0644)
+			p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+			objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db", "objectAlias": "db-{{.Version}}", "previousFileGracePeriod": "10m"}]`, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+
+			// A new version keeps the file of the one it replaces.
+			curMap := map[string]*v1alpha1.ObjectVersion{"db-{{.Version}}": {Id: "db-{{.Version}}", Version: "v1"}}
+			values, err := p.GetSecretValues(context.Background(), objects, curMap)
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if len(values) != 2 || values[0].SecretObj.GetFileName() != "db-v2" || values[1].SecretObj.GetFileName() != "db-v1" || string(values[1].Value) != "old" {
+				t.Fatalf("expected db-v2 and the retained db-v1, got %v", values)
+			}
+			retained := curMap["retained:db-{{.Version}}@v1"]
+			if retained == nil {
+				t.Fatalf("expected the retained version to be recorded, got %v", curMap)
+			}
+
+			// The previous file stays while the grace period runs.
+			fs.WriteFile("/mnt/db-v2", []byte("new"), 0644)
+			if values, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil || len(values) != 2 {
+				t.Fatalf("expected db-v1 to be kept, got %v, %v", values, err)
+			}
+
+			// And it is dropped once the grace period is over.
+			retained.Version = time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+			if values, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil || len(values) != 1 {
+				t.Fatalf("expected only db-v2, got %v, %v", values, err)
+			}
+			if _, ok := curMap["retained:db-{{.Version}}@v1"]; ok {
+				t.Errorf("expected the expired version to be forgotten, got %v", curMap)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListPreviousFileGracePeriod(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"valid", `[{"objectName": "a", "objectAlias": "a-{{.Version}}", "previousFileGracePeriod": "10m"}]`, false},
+		{"zero", `[{"objectName": "a", "objectAlias": "a-{{.Version}}", "previousFileGracePeriod": "0s"}]`, true},
+		{"invalid", `[{"objectName": "a", "objectAlias": "a-{{.Version}}", "previousFileGracePeriod": "soon"}]`, true},
+		{"unversioned-name", `[{"objectName": "a", "previousFileGracePeriod": "10m"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
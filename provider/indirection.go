@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Check the followIndirection of the object spec. The value of a pointer is
+// the name of the secret it points to, so options working on the versions or
+// metadata of the fetched secret would apply to the pointer instead and are
+// rejected.
+func (s *SecretObject) validateIndirection() error {
+	if !s.FollowIndirection {
+		return nil
+	}
+	if !s.isKMS() && s.ObjectType != ObjectTypeOOS {
+		return fmt.Errorf("followIndirection is only supported for kms secrets and oos parameters: %s", s.ObjectName)
+	}
+	if s.IncludePreviousVersion || len(s.TagsFile) > 0 {
+		return fmt.Errorf("includePreviousVersion and tagsFile are not supported with followIndirection: %s", s.ObjectName)
+	}
+	return nil
+}
+
+// Fetch the secret a pointer secret names. Only a single level is followed:
+// the value of the target is never treated as a name, so pointers can not
+// loop, and a pointer naming itself fails. The value is mounted under the
+// file of the pointer at the version of the target.
+func (p *SecretsManagerProvider) followIndirection(ctx context.Context, secObj *SecretObject, pointer *SecretValue) (string, *SecretValue, error) {
+	target := strings.TrimSpace(string(pointer.Value))
+	if len(target) == 0 || strings.ContainsAny(target, "\r\n\t") {
+		return "", nil, fmt.Errorf("Pointer secret %s does not hold a secret name", secObj.ObjectName)
+	}
+	if target == secObj.ObjectName {
+		return "", nil, fmt.Errorf("Pointer secret %s points to itself", secObj.ObjectName)
+	}
+	targetObj := *secObj
+	targetObj.ObjectName = target
+	targetObj.ObjectVersion, targetObj.ObjectVersionLabel = "", ""
+	targetObj.FollowIndirection = false
+	targetObj.arn = nil
+	version, secret, err := p.fetchSecret(ctx, &targetObj)
+	if isNotFound(err) {
+		// Not wrapped, a dangling pointer fails even an optional object.
+		return "", nil, fmt.Errorf("Secret %s pointed to by %s does not exist: %v", target, secObj.ObjectName, err)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed fetching secret %s pointed to by %s: %w", target, secObj.ObjectName, err)
+	}
+	secret.SecretObj = *secObj
+	return version, secret, nil
+}
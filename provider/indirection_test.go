@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestFollowIndirection(t *testing.T) {
+	setupFetchTest(t)
+	secrets := map[string][2]string{ // name -> value, version
+		"db-active": {"db-blue\n", "p3"},
+		"db-blue":   {"blue-password", "b7"},
+		"self":      {"self", "s1"},
+		"dangling":  {"db-green", "d1"},
+		"empty":     {" ", "e1"},
+	}
+	tests := []struct {
+		name        string
+		spec        string
+		want        string
+		wantVersion string
+		wantErr     string
+	}{
+		{"followed", `[{"objectName": "db-active", "objectAlias": "db", "followIndirection": true}]`, "blue-password", "b7", ""},
+		{"not-followed", `[{"objectName": "db-active", "objectAlias": "db"}]`, "db-blue\n", "p3", ""},
+		{"self", `[{"objectName": "self", "objectAlias": "db", "followIndirection": true}]`, "", "", "points to itself"},
+		{"dangling", `[{"objectName": "dangling", "objectAlias": "db", "followIndirection": true}]`, "", "", "db-green pointed to by dangling does not exist"},
+		{"dangling-optional", `[{"objectName": "dangling", "objectAlias": "db", "followIndirection": true, "required": false}]`, "", "", "does not exist"},
+		{"empty", `[{"objectName": "empty", "objectAlias": "db", "followIndirection": true}]`, "", "", "does not hold a secret name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			client := &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				name := tea.StringValue(request.SecretName)
+				requested = append(requested, name)
+				secret, ok := secrets[name]
+				if !ok {
+					return nil, &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound"), Message: tea.String("not found")}
+				}
+				return kmsSecretResponse(secret[0], secret[1]), nil
+			}}
+			p := &SecretsManagerProvider{KmsClient: client, FS: newMemFileSystem()}
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			curMap := map[string]*v1alpha1.ObjectVersion{}
+			values, err := p.GetSecretValues(context.Background(), objects, curMap)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetSecretValues() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(requested) > 2 {
+					t.Errorf("expected a single level of indirection, requested %v", requested)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if len(values) != 1 || values[0].SecretObj.GetFileName() != "db" || string(values[0].Value) != tt.want || curMap["db"].Version != tt.wantVersion {
+				t.Errorf("got %d values, want %q at version %s in db, recorded %v", len(values), tt.want, tt.wantVersion, curMap["db"])
+			}
+		})
+	}
+}
+
+func TestFollowIndirectionAlwaysFetched(t *testing.T) {
+	setupFetchTest(t)
+	oldCheck := CheckCurrentVersion
+	t.Cleanup(func() { CheckCurrentVersion = oldCheck })
+	CheckCurrentVersion = true
+	client := &mockKmsClient{
+		listSecretVersionIds: func(*kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error) {
+			return nil, errors.New("the version of a pointer must not be looked up")
+		},
+		getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+			if tea.StringValue(request.SecretName) == "db-active" {
+				return kmsSecretResponse("db-green", "p4"), nil
+			}
+			return kmsSecretResponse("green-password", "g1"), nil
+		},
+	}
+	fs := newMemFileSystem()
+	fs.WriteFile("/mnt/db", []byte("blue-password"), 0644)
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db-active", "objectAlias": "db", "followIndirection": true}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := map[string]*v1alpha1.ObjectVersion{"db": {Id: "db", Version: "b7"}}
+	values, err := p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if string(values[0].Value) != "green-password" || curMap["db"].Version != "g1" || client.calls != 2 {
+		t.Errorf("expected the new target to be fetched, got version %s after %d calls", curMap["db"].Version, client.calls)
+	}
+}
+
+func TestNewSecretObjectListFollowIndirection(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"kms", `[{"objectName": "a", "followIndirection": true}]`, false},
+		{"oos", `[{"objectName": "a", "objectType": "oos", "followIndirection": true}]`, false},
+		{"datakey", `[{"objectName": "a", "objectType": "datakey", "objectAlias": "k", "ciphertextAlias": "c", "followIndirection": true}]`, true},
+		{"previous-version", `[{"objectName": "a", "followIndirection": true, "includePreviousVersion": true}]`, true},
+		{"tags-file", `[{"objectName": "a", "followIndirection": true, "tagsFile": "a.tags"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
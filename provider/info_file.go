@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Suffix appended to the file name of an object to name its info file.
+const infoFileSuffix = ".info"
+
+// Build the <file name>.info file of an object. The file holds one key=value
+// line per field, always in the same order, and never contains the value
+// itself, only its sha256 digest.
+func (sv *SecretValue) getInfoSecret(version, region string, fetchedAt time.Time) *SecretValue {
+	objectType := sv.SecretObj.ObjectType
+	if len(objectType) == 0 {
+		objectType = ObjectTypeKMS
+	}
+	digest := sha256.Sum256(sv.Value)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "version=%s\n", version)
+	fmt.Fprintf(&b, "type=%s\n", objectType)
+	fmt.Fprintf(&b, "fetchedAt=%s\n", fetchedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "region=%s\n", region)
+	fmt.Fprintf(&b, "sha256=%s\n", hex.EncodeToString(digest[:]))
+	writeLabelLines(&b, sv.SecretObj.Labels)
+
+	return &SecretValue{
+		Value:     []byte(b.String()),
+		SecretObj: sv.SecretObj.getInfoFileSecretObject(),
+	}
+}
+
+// Build the info file of a secret. A reloaded secret keeps the info file that
+// is already mounted, so fetchedAt keeps reporting when the value was pulled,
+// with the current labels.
+func (p *SecretsManagerProvider) infoSecretFor(secret *SecretValue, version string, reloaded bool) *SecretValue {
+	if reloaded {
+		infoObj := secret.SecretObj.getInfoFileSecretObject()
+		if data, err := p.readMounted(&infoObj); err == nil {
+			return &SecretValue{Value: relabelInfo(data, secret.SecretObj.Labels), SecretObj: infoObj}
+		}
+	}
+	region := secret.SecretObj.getRegion()
+	if len(region) == 0 {
+		region = p.Region
+	}
+	return secret.getInfoSecret(version, region, time.Now())
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestGetInfoSecret(t *testing.T) {
+	sv := &SecretValue{
+		Value:     []byte("value"),
+		SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, ObjectAlias: "db", ObjectType: ObjectTypeOOS, translate: "_", mountDir: "/mnt"},
+	}
+	info := sv.getInfoSecret("v1", "cn-hangzhou", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	want := "version=v1\n" +
+		"type=oos\n" +
+		"fetchedAt=2024-01-02T03:04:05Z\n" +
+		"region=cn-hangzhou\n" +
+		"sha256=cd42404d52ad55ccfa9aca4adc828aa5800ad9d385a0671fbcbf724118320619\n"
+	if string(info.Value) != want {
+		t.Errorf("getInfoSecret() = %q, want %q", info.Value, want)
+	}
+	if info.SecretObj.GetMountPath() != "/mnt/db.info" {
+		t.Errorf("getInfoSecret() path = %s, want /mnt/db.info", info.SecretObj.GetMountPath())
+	}
+}
+
+func TestInfoFileKeptOnReload(t *testing.T) {
+	fs := newMemFileSystem()
+	p := &SecretsManagerProvider{FS: fs, Region: "cn-hangzhou"}
+	secObj := &SecretObject{ObjectName: TEST_OBJECT_NAME, ObjectVersion: "v1", InfoFile: true, translate: "_", mountDir: "/mnt"}
+	fs.WriteFile(secObj.GetMountPath(), []byte("value"), 0644)
+	fs.WriteFile("/mnt/"+TEST_OBJECT_NAME+".info", []byte("version=v1\n"), 0644)
+
+	curMap := map[string]*v1alpha1.ObjectVersion{
+		TEST_OBJECT_NAME: {Id: TEST_OBJECT_NAME, Version: "v1"},
+	}
+	values, err := p.GetSecretValues(context.Background(), []*SecretObject{secObj}, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if len(values) != 2 || string(values[1].Value) != "version=v1\n" {
+		t.Fatalf("GetSecretValues() did not keep the mounted info file")
+	}
+	if curMap[TEST_OBJECT_NAME+".info"] == nil {
+		t.Errorf("GetSecretValues() did not record the info file version")
+	}
+
+	// Without a mounted info file a new one is generated.
+	fs.files = map[string][]byte{secObj.GetMountPath(): []byte("value")}
+	values, err = p.GetSecretValues(context.Background(), []*SecretObject{secObj}, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if len(values) != 2 || !strings.Contains(string(values[1].Value), "region=cn-hangzhou\n") {
+		t.Errorf("GetSecretValues() info file = %q", values[1].Value)
+	}
+}
+
+func TestNewSecretObjectListInfoFileName(t *testing.T) {
+	spec := `[{"objectName": "a", "infoFile": true}, {"objectName": "b", "objectAlias": "a.info"}]`
+	if _, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{}); err == nil {
+		t.Errorf("expected an error for an objectAlias colliding with an info file")
+	}
+}
+
+func TestInfoFileLabels(t *testing.T) {
+	labels := map[string]string{"team": "payments", "env": "prod"}
+	sv := &SecretValue{
+		Value:     []byte("value"),
+		SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, Labels: labels, translate: "_", mountDir: "/mnt"},
+	}
+	info := sv.getInfoSecret("v1", "cn-hangzhou", time.Now())
+	if !strings.HasSuffix(string(info.Value), "\nlabel.env=prod\nlabel.team=payments\n") {
+		t.Errorf("getInfoSecret() = %q, want sorted label lines last", info.Value)
+	}
+
+	// A reloaded info file keeps fetchedAt and takes the current labels.
+	mounted := "version=v1\nfetchedAt=2024-01-02T03:04:05Z\nlabel.team=billing\n"
+	got := string(relabelInfo([]byte(mounted), map[string]string{"team": "payments"}))
+	if want := "version=v1\nfetchedAt=2024-01-02T03:04:05Z\nlabel.team=payments\n"; got != want {
+		t.Errorf("relabelInfo() = %q, want %q", got, want)
+	}
+}
+
+func TestNewSecretObjectListLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"valid", `[{"objectName": "s", "labels": {"team": "payments", "tier": ""}}]`, false},
+		{"empty-key", `[{"objectName": "s", "labels": {" ": "payments"}}]`, true},
+		{"equals-in-key", `[{"objectName": "s", "labels": {"a=b": "c"}}]`, true},
+		{"line-break", `[{"objectName": "s", "labels": {"team": "pay\nments"}}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// VerifyMountedFiles makes reloaded values be checked against the sha256
+// recorded in the infoFile of their object or in the manifestFile of the
+// mount, fetching them again when the mounted file was truncated or modified.
+// Values without a recorded digest are reloaded as is. It costs a digest and
+// a read of the info or manifest file per reloaded object.
+var VerifyMountedFiles = false
+
+// Returned by reloadSecret when a mounted file no longer matches its recorded digest.
+var errMountedMismatch = errors.New("mounted file does not match its recorded sha256")
+
+// Check a reloaded value against its recorded digest, when VerifyMountedFiles is set.
+func (p *SecretsManagerProvider) verifyMounted(secret *SecretValue) error {
+	if !VerifyMountedFiles {
+		return nil
+	}
+	expected, ok := p.recordedDigest(&secret.SecretObj)
+	if !ok {
+		return nil
+	}
+	sum := sha256.Sum256(secret.Value)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), expected) {
+		return fmt.Errorf("%w: %s", errMountedMismatch, secret.SecretObj.GetFileName())
+	}
+	return nil
+}
+
+// Return the digest recorded for the mounted file of an object, from its info
+// file first, then from the manifest file of the mount.
+func (p *SecretsManagerProvider) recordedDigest(secObj *SecretObject) (string, bool) {
+	if secObj.InfoFile {
+		infoObj := secObj.getInfoFileSecretObject()
+		if data, err := p.readMounted(&infoObj); err == nil {
+			scanner := bufio.NewScanner(bytes.NewReader(data))
+			for scanner.Scan() {
+				if digest := strings.TrimPrefix(scanner.Text(), "sha256="); digest != scanner.Text() {
+					return digest, true
+				}
+			}
+		}
+	}
+	if len(p.ManifestFile) == 0 {
+		return "", false
+	}
+	if p.mountedManifest == nil {
+		p.mountedManifest = make(map[string]string)
+		data, err := p.fs().ReadFile(filepath.Join(secObj.GetMountDir(), p.ManifestFile))
+		if err != nil {
+			return "", false
+		}
+		var entries []manifestEntry
+		if err = json.Unmarshal(data, &entries); err != nil {
+			return "", false
+		}
+		for _, entry := range entries {
+			p.mountedManifest[entry.Name] = entry.Sha256
+		}
+	}
+	digest, ok := p.mountedManifest[secObj.GetFileName()]
+	return digest, ok
+}
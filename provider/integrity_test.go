@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestVerifyMountedFiles(t *testing.T) {
+	setupFetchTest(t)
+	oldVerify := VerifyMountedFiles
+	t.Cleanup(func() { VerifyMountedFiles = oldVerify })
+	sum := sha256.Sum256([]byte("value"))
+	digest := hex.EncodeToString(sum[:])
+	tests := []struct {
+		name     string
+		spec     string
+		manifest string
+		verify   bool
+		mounted  string
+		want     string
+		calls    int
+	}{
+		{"info-intact", `[{"objectName": "db", "objectVersion": "v1", "infoFile": true}]`, "", true, "value", "value", 0},
+		{"info-truncated", `[{"objectName": "db", "objectVersion": "v1", "infoFile": true}]`, "", true, "val", "value", 1},
+		{"manifest-modified", `[{"objectName": "db", "objectVersion": "v1"}]`, ".manifest.json", true, "tampered", "value", 1},
+		{"not-verified", `[{"objectName": "db", "objectVersion": "v1", "infoFile": true}]`, "", false, "val", "val", 0},
+		{"no-digest", `[{"objectName": "db", "objectVersion": "v1"}]`, "", true, "val", "val", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			VerifyMountedFiles = tt.verify
+			client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				return kmsSecretResponse("value", "v1"), nil
+			}}
+			fs := newMemFileSystem()
+			fs.WriteFile("/mnt/db", []byte(tt.mounted), 0644)
+			fs.WriteFile("/mnt/db.info", []byte("version=v1\nsha256="+digest+"\n"), 0644)
+			fs.WriteFile("/mnt/.manifest.json", []byte(fmt.Sprintf(`[{"name": "db", "sha256": %q}]`, digest)), 0644)
+			p := &SecretsManagerProvider{KmsClient: client, FS: fs, ManifestFile: tt.manifest}
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			curMap := map[string]*v1alpha1.ObjectVersion{"db": {Id: "db", Version: "v1"}}
+			values, err := p.GetSecretValues(context.Background(), objects, curMap)
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if string(values[0].Value) != tt.want || client.calls != tt.calls {
+				t.Errorf("GetSecretValues() = %q after %d calls, want %q after %d", values[0].Value, client.calls, tt.want, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/itchyny/gojq"
+)
+
+// JQFilterTimeout bounds the evaluation of a jqFilter, so a filter that never
+// ends, e.g. one using repeat, fails its mount instead of blocking it.
+var JQFilterTimeout = 5 * time.Second
+
+// Check and compile the jqFilter of the object spec. A filter replaces the
+// value of the object, so it can not be combined with jmesPath entries nor
+// with options reading the fields of the fetched value.
+func (s *SecretObject) validateJQFilter() error {
+	if len(s.JQFilter) == 0 {
+		return nil
+	}
+	if DisableJMESPath {
+		return fmt.Errorf("jqFilter is disabled by the provider policy, only whole secrets can be mounted: %s", s.ObjectName)
+	}
+	if len(s.JMESPath) > 0 {
+		return fmt.Errorf("Object %s can specify at most one of jmesPath and jqFilter", s.ObjectName)
+	}
+	if s.isDataKey() || s.ExtractManagedFields || s.PKCS12 != nil {
+		return fmt.Errorf("jqFilter is not supported for datakey objects, nor with extractManagedFields or pkcs12: %s", s.ObjectName)
+	}
+	query, err := gojq.Parse(s.JQFilter)
+	if err != nil {
+		return fmt.Errorf("Invalid jqFilter for object %s: %v", s.ObjectName, err)
+	}
+	if s.jqCode, err = gojq.Compile(query); err != nil {
+		return fmt.Errorf("Invalid jqFilter for object %s: %v", s.ObjectName, err)
+	}
+	return nil
+}
+
+// Replace the value with the result of the jqFilter of its object. The value
+// must be a JSON document and the filter must produce exactly one result:
+// a string is written as is, like a jmesPath result, other results as JSON,
+// with objects and arrays indented like prettyJSON. Errors name the object
+// only, as the messages of jq may quote parts of the value.
+func (sv *SecretValue) applyJQFilter(ctx context.Context) error {
+	code := sv.SecretObj.jqCode
+	if code == nil {
+		return nil
+	}
+	name := sv.SecretObj.ObjectName
+	if !utf8.Valid(sv.Value) {
+		return fmt.Errorf("jqFilter of object %s requires a UTF-8 JSON secret", name)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(sv.Value))
+	decoder.UseNumber() // Keep large integers exact
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil || decoder.More() {
+		return fmt.Errorf("Invalid JSON used with jqFilter in secret: %s.", name)
+	}
+
+	filterCtx, cancel := context.WithTimeout(ctx, JQFilterTimeout)
+	defer cancel()
+	iter := code.RunWithContext(filterCtx, document)
+	result, ok := iter.Next()
+	if !ok {
+		return fmt.Errorf("jqFilter of object %s produced no result", name)
+	}
+	if err, isErr := result.(error); isErr {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("jqFilter of object %s did not finish within %s", name, JQFilterTimeout)
+		}
+		return fmt.Errorf("jqFilter of object %s failed on the value of the secret", name)
+	}
+	if _, more := iter.Next(); more {
+		return fmt.Errorf("jqFilter of object %s produced more than one result, collect them in an array with [...]", name)
+	}
+
+	var value []byte
+	switch v := result.(type) {
+	case string:
+		value = []byte(v)
+	case nil:
+		return fmt.Errorf("jqFilter of object %s produced null", name)
+	default:
+		encoded, err := gojq.Marshal(v) // Object keys are sorted
+		if err != nil {
+			return fmt.Errorf("Failed to format the jqFilter result of object %s", name)
+		}
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			var indented bytes.Buffer
+			if err = json.Indent(&indented, encoded, "", "  "); err != nil {
+				return fmt.Errorf("Failed to format the jqFilter result of object %s", name)
+			}
+			encoded = indented.Bytes()
+		}
+		value = encoded
+	}
+	sv.Value = value
+	sv.parsed = nil
+	return nil
+}
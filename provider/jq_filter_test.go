@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestJQFilter(t *testing.T) {
+	setupFetchTest(t)
+	secret := `{"user": "admin", "password": "p@ss", "port": 5432, "id": 12345678901234567890, "hosts": [{"name": "a"}, {"name": "b"}]}`
+	tests := []struct {
+		name    string
+		filter  string
+		want    string
+		wantErr string
+	}{
+		{"string", `.password`, "p@ss", ""},
+		{"number", `.port`, "5432", ""},
+		{"large-integer", `.id`, "12345678901234567890", ""},
+		{"interpolation", `"postgres://\(.user):\(.password)@db:\(.port)"`, "postgres://admin:p@ss@db:5432", ""},
+		{"object", `{password, user}`, "{\n  \"password\": \"p@ss\",\n  \"user\": \"admin\"\n}", ""},
+		{"array", `[.hosts[].name]`, "[\n  \"a\",\n  \"b\"\n]", ""},
+		{"no-result", `empty`, "", "produced no result"},
+		{"several-results", `.hosts[].name`, "", "more than one result"},
+		{"null", `.missing`, "", "produced null"},
+		{"runtime-error", `.password | keys`, "", "failed on the value of the secret"},
+		{"timeout", `def f: f; f`, "", "did not finish"},
+	}
+	oldTimeout := JQFilterTimeout
+	t.Cleanup(func() { JQFilterTimeout = oldTimeout })
+	JQFilterTimeout = 50 * time.Millisecond
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				return kmsSecretResponse(secret, "v1"), nil
+			}}
+			p := &SecretsManagerProvider{KmsClient: client}
+			objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db", "jqFilter": "`+strings.ReplaceAll(strings.ReplaceAll(tt.filter, `\`, `\\`), `"`, `\"`)+`"}]`, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			values, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetSecretValues() error = %v, want %q", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "p@ss") {
+					t.Errorf("error leaks the secret: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if len(values) != 1 || string(values[0].Value) != tt.want {
+				t.Errorf("GetSecretValues() = %q, want %q", values[0].Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListJQFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"valid", `[{"objectName": "a", "jqFilter": ".password"}]`, false},
+		{"syntax-error", `[{"objectName": "a", "jqFilter": ".password |"}]`, true},
+		{"undefined-function", `[{"objectName": "a", "jqFilter": "nosuchfunction"}]`, true},
+		{"with-jmesPath", `[{"objectName": "a", "jqFilter": ".password", "jmesPath": [{"path": "user", "objectAlias": "user"}]}]`, true},
+		{"datakey", `[{"objectName": "a", "objectType": "datakey", "jqFilter": "."}]`, true},
+		{"extractManagedFields", `[{"objectName": "a", "extractManagedFields": true, "jqFilter": "."}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
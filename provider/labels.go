@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Prefix of the info file lines holding the labels of an object.
+const infoLabelPrefix = "label."
+
+// Check the labels of the object spec. Keys must be non-empty and neither keys
+// nor values may break the key=value lines of an info file.
+func (s *SecretObject) validateLabels() error {
+	for k, v := range s.Labels {
+		if len(strings.TrimSpace(k)) == 0 {
+			return fmt.Errorf("Label keys of object %s must not be empty", s.ObjectName)
+		}
+		if strings.ContainsAny(k, "=\r\n") || strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("Invalid label %q of object %s, keys can not contain '=' and labels can not contain line breaks", k, s.ObjectName)
+		}
+	}
+	return nil
+}
+
+// Write the labels of an object as label.<key>=<value> lines, sorted by key.
+func writeLabelLines(b *strings.Builder, labels map[string]string) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s%s=%s\n", infoLabelPrefix, k, labels[k])
+	}
+}
+
+// Replace the label lines of a mounted info file with the current labels, so a
+// relabeled object does not wait for a new version to update its info file.
+func relabelInfo(data []byte, labels map[string]string) []byte {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if len(line) > 0 && !strings.HasPrefix(line, infoLabelPrefix) {
+			b.WriteString(line)
+		}
+	}
+	writeLabelLines(&b, labels)
+	return []byte(b.String())
+}
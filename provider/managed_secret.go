@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// KMS secret types with a well known JSON structure.
+const (
+	SecretTypeGeneric = "Generic"
+	SecretTypeRds     = "Rds"
+	SecretTypeRAM     = "RAMCredentials"
+	SecretTypeECS     = "ECS"
+)
+
+// A standard field of a managed secret and the JSON key holding it.
+type managedField struct {
+	name     string
+	key      string
+	required bool
+}
+
+var managedSecretFields = map[string][]managedField{
+	SecretTypeRds: {
+		{name: "username", key: "AccountName", required: true},
+		{name: "password", key: "AccountPassword", required: true},
+	},
+	SecretTypeRAM: {
+		{name: "accessKeyId", key: "AccessKeyId", required: true},
+		{name: "accessKeySecret", key: "AccessKeySecret", required: true},
+	},
+	SecretTypeECS: {
+		{name: "username", key: "UserName", required: true},
+		{name: "password", key: "Password"},
+		{name: "privateKey", key: "PrivateKey"},
+	},
+}
+
+// Prefix of the current version map entries recording the KMS secret type of
+// the mounted value of an object with extractManagedFields, so a reloaded value
+// is extracted by the type KMS reported when it was fetched.
+const secretTypeKeyPrefix = "secretType:"
+
+// Key of the current version map entry recording the secret type of the object.
+func (s *SecretObject) secretTypeKey() string {
+	return secretTypeKeyPrefix + s.GetFileName()
+}
+
+// Return the secret type recorded for the mounted value of the object, and
+// whether one was recorded.
+func (s *SecretObject) recordedSecretType(curMap map[string]*v1alpha1.ObjectVersion) (string, bool) {
+	recorded := curMap[s.secretTypeKey()]
+	if recorded == nil {
+		return "", false
+	}
+	return recorded.Version, true
+}
+
+// Report whether the object extracts managed fields from a mounted value whose
+// secret type was not recorded, so it must be fetched again.
+func (s *SecretObject) missingSecretType(curMap map[string]*v1alpha1.ObjectVersion) bool {
+	_, ok := s.recordedSecretType(curMap)
+	return s.ExtractManagedFields && !ok
+}
+
+// Record in the current version map the secret type of the value of an object
+// with extractManagedFields.
+func (s *SecretObject) recordSecretType(curMap map[string]*v1alpha1.ObjectVersion, secretType string) {
+	if !s.ExtractManagedFields {
+		return
+	}
+	curMap[s.secretTypeKey()] = &v1alpha1.ObjectVersion{
+		Id:      s.secretTypeKey(),
+		Version: secretType,
+	}
+}
+
+// Extract the standard fields of a managed KMS secret (RDS, RAM or ECS) into
+// files named <file name>-<field>, e.g. db-username and db-password.
+func (sv *SecretValue) getManagedSecrets() ([]*SecretValue, error) {
+	if !sv.SecretObj.ExtractManagedFields {
+		return nil, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(sv.Value, &data); err != nil {
+		return nil, fmt.Errorf("Invalid JSON in managed secret: %s.", sv.SecretObj.ObjectName)
+	}
+	secretType := sv.SecretType
+	fields, ok := managedSecretFields[secretType]
+	if !ok {
+		return nil, fmt.Errorf("extractManagedFields is not supported for secret %s of type %q, only %s, %s and %s secrets are supported",
+			sv.SecretObj.ObjectName, secretType, SecretTypeRds, SecretTypeRAM, SecretTypeECS)
+	}
+
+	values := make([]*SecretValue, 0, len(fields))
+	for _, field := range fields {
+		raw, ok := data[field.key]
+		if !ok {
+			if field.required {
+				return nil, fmt.Errorf("Managed secret %s is missing the %s field.", sv.SecretObj.ObjectName, field.key)
+			}
+			continue
+		}
+		value, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("Managed secret %s field %s is not a string.", sv.SecretObj.ObjectName, field.key)
+		}
+		values = append(values, &SecretValue{
+			Value:     []byte(value),
+			SecretObj: sv.SecretObj.getManagedFieldSecretObject(field.name),
+		})
+	}
+	return values, nil
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestGetManagedSecrets(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		secretType string
+		want       map[string]string
+		wantErr    bool
+	}{
+		{"rds", `{"AccountName": "admin", "AccountPassword": "pwd"}`, SecretTypeRds, map[string]string{"db-username": "admin", "db-password": "pwd"}, false},
+		{"ram", `{"AccessKeyId": "id", "AccessKeySecret": "key", "GenerateTimestamp": "2023-01-01"}`, SecretTypeRAM, map[string]string{"db-accessKeyId": "id", "db-accessKeySecret": "key"}, false},
+		{"ecs-key", `{"UserName": "root", "PrivateKey": "pem"}`, SecretTypeECS, map[string]string{"db-username": "root", "db-privateKey": "pem"}, false},
+		{"generic-with-rds-fields", `{"AccountName": "admin", "AccountPassword": "pwd"}`, SecretTypeGeneric, nil, true},
+		{"unknown-type", `{"AccountName": "admin", "AccountPassword": "pwd"}`, "", nil, true},
+		{"generic", `{"user": "admin"}`, SecretTypeGeneric, nil, true},
+		{"missing-field", `{"AccountName": "admin"}`, SecretTypeRds, nil, true},
+		{"not-json", `admin`, SecretTypeRds, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &SecretValue{
+				Value:      []byte(tt.value),
+				SecretObj:  SecretObject{ObjectName: "db", ExtractManagedFields: true},
+				SecretType: tt.secretType,
+			}
+			values, err := sv.getManagedSecrets()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getManagedSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(values) != len(tt.want) {
+				t.Fatalf("getManagedSecrets() returned %d values, want %d", len(values), len(tt.want))
+			}
+			for _, v := range values {
+				if tt.want[v.SecretObj.GetFileName()] != string(v.Value) {
+					t.Errorf("getManagedSecrets() %s = %q, want %q", v.SecretObj.GetFileName(), v.Value, tt.want[v.SecretObj.GetFileName()])
+				}
+			}
+		})
+	}
+}
+
+func TestManagedSecretReload(t *testing.T) {
+	const value = `{"AccountName": "admin", "AccountPassword": "pwd"}`
+	tests := []struct {
+		name         string
+		recordedType *string // Secret type recorded by the previous sync, nil for none
+		wantCalls    int
+		wantErr      string
+	}{
+		{"recorded-rds", tea.String(SecretTypeRds), 0, ""},
+		{"recorded-generic", tea.String(SecretTypeGeneric), 0, `of type "Generic"`},
+		{"not-recorded", nil, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupFetchTest(t)
+			kmsClient := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				return &kms.GetSecretValueResponse{Body: &kms.GetSecretValueResponseBody{
+					SecretData:     tea.String(value),
+					SecretDataType: tea.String("text"),
+					SecretType:     tea.String(SecretTypeRds),
+					VersionId:      tea.String("v1"),
+				}}, nil
+			}}
+			fs := newMemFileSystem()
+			fs.WriteFile("/mnt/db", []byte(value), 0644)
+			p := &SecretsManagerProvider{KmsClient: kmsClient, FS: fs}
+			objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db", "objectVersion": "v1", "extractManagedFields": true}]`, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			curMap := map[string]*v1alpha1.ObjectVersion{"db": {Id: "db", Version: "v1"}}
+			if tt.recordedType != nil {
+				curMap["secretType:db"] = &v1alpha1.ObjectVersion{Id: "secretType:db", Version: *tt.recordedType}
+			}
+
+			values, err := p.GetSecretValues(context.Background(), objects, curMap)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetSecretValues() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if kmsClient.calls != tt.wantCalls {
+				t.Errorf("made %d KMS calls, want %d", kmsClient.calls, tt.wantCalls)
+			}
+			got := make(map[string]string)
+			for _, v := range values {
+				got[v.SecretObj.GetFileName()] = string(v.Value)
+			}
+			if got["db-username"] != "admin" || got["db-password"] != "pwd" {
+				t.Errorf("GetSecretValues() = %v, want the managed fields", got)
+			}
+			if recorded := curMap["secretType:db"]; recorded == nil || recorded.Version != SecretTypeRds {
+				t.Errorf("recorded secret type %v, want %s", recorded, SecretTypeRds)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultManifestFile is the manifest file name used for a manifestFile of "true".
+const DefaultManifestFile = ".secrets-manifest.json"
+
+// Type of the mergeInto files in the manifest, which may combine several objects.
+const manifestTypeMerged = "merged"
+
+// An entry of the manifest file. It never holds the value itself.
+type manifestEntry struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`
+	Version string            `json:"version"`
+	Sha256  string            `json:"sha256"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// Check the name of a manifest file. Like a data map file it is a plain file
+// name in the mount directory; a secret mounted under the same name fails the
+// mount.
+func ValidateManifestFile(name string) error {
+	if len(name) == 0 || strings.ContainsRune(name, os.PathSeparator) || badPathRE.MatchString(name) {
+		return fmt.Errorf("Invalid manifestFile %q, it must be a file name without a path", name)
+	}
+	return nil
+}
+
+// Record the object a value was produced from. Its labels are informational,
+// they never come from the value.
+func (sv *SecretValue) setSource(secObj *SecretObject, version string) {
+	sv.objectType = secObj.ObjectType
+	if len(sv.objectType) == 0 {
+		sv.objectType = ObjectTypeKMS
+	}
+	sv.version = version
+	sv.labels = secObj.Labels
+}
+
+// Build the manifest file listing the files of the mount, in the order of the
+// values. With a data map file the entries are the files of the data map.
+func (p *SecretsManagerProvider) manifestValue(values []*SecretValue) (*SecretValue, error) {
+	entries := make([]manifestEntry, 0, len(values))
+	changed := false
+	for _, sv := range values {
+		name := sv.SecretObj.GetFileName()
+		if name == p.ManifestFile {
+			return nil, fmt.Errorf("File name %s is reserved for the manifestFile", name)
+		}
+		changed = changed || sv.changed
+		sum := sha256.Sum256(sv.Value)
+		entries = append(entries, manifestEntry{
+			Name:    name,
+			Type:    sv.objectType,
+			Version: sv.version,
+			Sha256:  hex.EncodeToString(sum[:]),
+			Labels:  sv.labels,
+		})
+	}
+	encoded, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &SecretValue{
+		Value:     encoded,
+		SecretObj: SecretObject{ObjectAlias: p.ManifestFile, mountDir: values[0].SecretObj.mountDir},
+		changed:   changed,
+	}, nil
+}
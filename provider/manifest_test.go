@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestGetSecretValuesManifestFile(t *testing.T) {
+	setupFetchTest(t)
+	kmsClient := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(`{"user": "admin"}`, "v1"), nil
+	}}
+	spec := `
+- objectName: "db"
+  labels:
+    team: "payments"
+  jmesPath:
+    - path: "user"
+      objectAlias: "user"
+    - path: "user"
+      objectAlias: "user"
+      mergeInto: "config.json"
+- objectName: "p"
+  objectType: "oos"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	digest := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	labels := map[string]string{"team": "payments"}
+	want := []manifestEntry{
+		{"db", "kms", "v1", digest(`{"user": "admin"}`), labels},
+		{"user", "kms", "v1", digest("admin"), labels},
+		{"p", "oos", "1", digest("param"), nil},
+		{"config.json", "merged", "db=v1", digest("{\n  \"user\": \"admin\"\n}"), nil},
+	}
+
+	for _, dataMap := range []string{"", "secrets.json"} {
+		p := &SecretsManagerProvider{KmsClient: kmsClient, OosClient: newVersionedOosClient("param"), FS: newMemFileSystem(),
+			ManifestFile: DefaultManifestFile, DataMapFile: dataMap}
+		values, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+		if err != nil {
+			t.Fatalf("GetSecretValues() error = %v", err)
+		}
+		manifest := values[len(values)-1]
+		if manifest.SecretObj.GetMountPath() != "/mnt/"+DefaultManifestFile {
+			t.Fatalf("last value is %s, want the manifest", manifest.SecretObj.GetMountPath())
+		}
+		if strings.Contains(string(manifest.Value), "admin") {
+			t.Errorf("manifest holds a secret value: %s", manifest.Value)
+		}
+		var got []manifestEntry
+		if err = json.Unmarshal(manifest.Value, &got); err != nil {
+			t.Fatalf("invalid manifest: %v", err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("manifest = %+v, want %+v", got, want)
+		}
+		for i := range want {
+			if !reflect.DeepEqual(got[i], want[i]) {
+				t.Errorf("manifest[%d] = %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestGetSecretValuesManifestFileCollision(t *testing.T) {
+	setupFetchTest(t)
+	kmsClient := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse("value", "v1"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: kmsClient, FS: newMemFileSystem(), ManifestFile: "index.json"}
+	objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "s", "objectAlias": "index.json"}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	if _, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion)); err == nil {
+		t.Errorf("expected a secret named like the manifest file to fail the mount")
+	}
+}
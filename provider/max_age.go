@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// Prefix of the current version map entries recording when the value of an
+// object with a maxAge was fetched. The driver stores the entries with the
+// versions of the mount and passes them back on every sync.
+const fetchedAtKeyPrefix = "fetchedAt:"
+
+// Check the maxAge of the object spec and parse the duration.
+func (s *SecretObject) validateMaxAge() (err error) {
+	if len(s.MaxAge) == 0 {
+		return nil
+	}
+	s.maxAge, err = time.ParseDuration(s.MaxAge)
+	if err != nil || s.maxAge <= 0 {
+		return fmt.Errorf("Invalid maxAge %q for object %s, expected a positive duration such as 24h", s.MaxAge, s.ObjectName)
+	}
+	return nil
+}
+
+// Key of the current version map entry recording when the object was fetched.
+func (s *SecretObject) fetchedAtKey() string {
+	return fetchedAtKeyPrefix + s.GetFileName()
+}
+
+// Report whether the mounted value of an object with a maxAge is older than
+// maxAge, or of unknown age, and must be fetched again.
+func (s *SecretObject) expired(curMap map[string]*v1alpha1.ObjectVersion, now time.Time) bool {
+	if s.maxAge == 0 {
+		return false
+	}
+	fetched := curMap[s.fetchedAtKey()]
+	if fetched == nil {
+		return true
+	}
+	fetchedAt, err := time.Parse(time.RFC3339, fetched.Version)
+	return err != nil || now.Sub(fetchedAt) >= s.maxAge
+}
+
+// Record in the current version map that the object was fetched now.
+func (s *SecretObject) recordFetchedAt(curMap map[string]*v1alpha1.ObjectVersion, now time.Time) {
+	if s.maxAge == 0 {
+		return
+	}
+	curMap[s.fetchedAtKey()] = &v1alpha1.ObjectVersion{
+		Id:      s.fetchedAtKey(),
+		Version: now.UTC().Format(time.RFC3339),
+	}
+}
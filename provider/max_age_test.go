@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestMaxAge(t *testing.T) {
+	setupFetchTest(t)
+	now := time.Now()
+	tests := []struct {
+		name      string
+		fetchedAt string // Recorded time of the mounted value, if any
+		wantFetch bool
+	}{
+		{"fresh", now.Add(-time.Minute).UTC().Format(time.RFC3339), false},
+		{"expired", now.Add(-2 * time.Hour).UTC().Format(time.RFC3339), true},
+		{"unknown-age", "", true},
+		{"invalid-time", "yesterday", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				return kmsSecretResponse("value", "v1"), nil
+			}}
+			fs := newMemFileSystem()
+			fs.WriteFile("/mnt/db", []byte("value"), 0644)
+			p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+			objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db", "objectVersion": "v1", "maxAge": "1h"}]`, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			curMap := map[string]*v1alpha1.ObjectVersion{"db": {Id: "db", Version: "v1"}}
+			if len(tt.fetchedAt) > 0 {
+				curMap["fetchedAt:db"] = &v1alpha1.ObjectVersion{Id: "fetchedAt:db", Version: tt.fetchedAt}
+			}
+			if _, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if (client.calls == 1) != tt.wantFetch {
+				t.Fatalf("expected fetch %v, got %d calls", tt.wantFetch, client.calls)
+			}
+			recorded := curMap["fetchedAt:db"].Version
+			if tt.wantFetch && recorded == tt.fetchedAt || !tt.wantFetch && recorded != tt.fetchedAt {
+				t.Errorf("recorded fetch time %s, expected it to be updated %v", recorded, tt.wantFetch)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListMaxAge(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"valid", `[{"objectName": "a", "maxAge": "24h"}]`, false},
+		{"zero", `[{"objectName": "a", "maxAge": "0s"}]`, true},
+		{"negative", `[{"objectName": "a", "maxAge": "-1h"}]`, true},
+		{"invalid", `[{"objectName": "a", "maxAge": "one day"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
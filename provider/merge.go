@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// A JSON document merged from jmesPath entries of one or more objects.
+type mergedFile struct {
+	secObj   SecretObject
+	data     map[string]interface{}
+	sources  map[string]string // key -> object name it was extracted from
+	versions map[string]string // object name -> version
+}
+
+// JSON documents declared with mergeInto, in the order they were first used.
+type mergedFiles struct {
+	order []string
+	files map[string]*mergedFile
+}
+
+// Add the mergeInto entries of a secret to their documents. A key extracted
+// by two entries fails the mount rather than silently picking one value.
+func (m *mergedFiles) add(sv *SecretValue, version string) error {
+	for i, jmesPathEntry := range sv.SecretObj.JMESPath {
+		if len(jmesPathEntry.MergeInto) == 0 {
+			continue
+		}
+		value, err := sv.searchJMES(&sv.SecretObj.JMESPath[i])
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return fmt.Errorf("JMES Path - %s for object alias - %s does not point to a valid object.",
+				jmesPathEntry.Path, jmesPathEntry.ObjectAlias)
+		}
+
+		if m.files == nil {
+			m.files = make(map[string]*mergedFile)
+		}
+		file, ok := m.files[jmesPathEntry.MergeInto]
+		if !ok {
+			file = &mergedFile{
+				secObj:   sv.SecretObj.getMergeFileSecretObject(jmesPathEntry.MergeInto),
+				data:     make(map[string]interface{}),
+				sources:  make(map[string]string),
+				versions: make(map[string]string),
+			}
+			m.files[jmesPathEntry.MergeInto] = file
+			m.order = append(m.order, jmesPathEntry.MergeInto)
+		}
+		if source, ok := file.sources[jmesPathEntry.ObjectAlias]; ok {
+			return fmt.Errorf("Key %s of mergeInto file %s is extracted from both %s and %s",
+				jmesPathEntry.ObjectAlias, jmesPathEntry.MergeInto, source, sv.SecretObj.ObjectName)
+		}
+		file.data[jmesPathEntry.ObjectAlias] = value
+		file.sources[jmesPathEntry.ObjectAlias] = sv.SecretObj.ObjectName
+		file.versions[sv.SecretObj.ObjectName] = version
+	}
+	return nil
+}
+
+// Build the merged documents and record their versions, made of the versions
+// of every contributing object, in the current version map.
+func (m *mergedFiles) values(curMap map[string]*v1alpha1.ObjectVersion) ([]*SecretValue, error) {
+	values := make([]*SecretValue, 0, len(m.order))
+	for _, name := range m.order {
+		file := m.files[name]
+		// Map keys are marshalled in sorted order, so the output is deterministic.
+		value, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to format mergeInto file %s.", name)
+		}
+		sources := make([]string, 0, len(file.versions))
+		for source, version := range file.versions {
+			sources = append(sources, source+"="+version)
+		}
+		sort.Strings(sources)
+		version := strings.Join(sources, ",")
+		prior := curMap[file.secObj.GetFileName()]
+		values = append(values, &SecretValue{Value: value, SecretObj: file.secObj, changed: prior == nil || prior.Version != version,
+			objectType: manifestTypeMerged, version: version})
+		curMap[file.secObj.GetFileName()] = &v1alpha1.ObjectVersion{
+			Id:      file.secObj.GetFileName(),
+			Version: version,
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"testing"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestMergedFiles(t *testing.T) {
+	db := &SecretValue{
+		Value: []byte(`{"user": "admin", "port": 5432}`),
+		SecretObj: SecretObject{ObjectName: "db", mountDir: "/mnt", translate: "_", JMESPath: []JMESPathObject{
+			{Path: "user", ObjectAlias: "dbUser", MergeInto: "config.json"},
+			{Path: "port", ObjectAlias: "dbPort", MergeInto: "config.json"},
+		}},
+	}
+	api := &SecretValue{
+		Value: []byte(`{"token": "t"}`),
+		SecretObj: SecretObject{ObjectName: "api", JMESPath: []JMESPathObject{
+			{Path: "token", ObjectAlias: "apiToken", MergeInto: "config.json"},
+			{Path: "token", ObjectAlias: "token"},
+		}},
+	}
+
+	var merged mergedFiles
+	if err := merged.add(db, "v1"); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	if err := merged.add(api, "v2"); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	curMap := make(map[string]*v1alpha1.ObjectVersion)
+	values, err := merged.values(curMap)
+	if err != nil {
+		t.Fatalf("values() error = %v", err)
+	}
+	want := "{\n  \"apiToken\": \"t\",\n  \"dbPort\": 5432,\n  \"dbUser\": \"admin\"\n}"
+	if len(values) != 1 || string(values[0].Value) != want {
+		t.Fatalf("values() = %q, want %q", values[0].Value, want)
+	}
+	if values[0].SecretObj.GetMountPath() != "/mnt/config.json" {
+		t.Errorf("values() path = %s", values[0].SecretObj.GetMountPath())
+	}
+	if v := curMap["config.json"]; v == nil || v.Version != "api=v2,db=v1" {
+		t.Errorf("values() version = %v", v)
+	}
+
+	if err := merged.add(db, "v1"); err == nil {
+		t.Errorf("expected an error for a key extracted twice")
+	}
+}
+
+func TestNewSecretObjectListMergeInto(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{
+			"shared-target",
+			`[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "mergeInto": "config.json"}]},
+			  {"objectName": "b", "jmesPath": [{"path": "x", "objectAlias": "y", "mergeInto": "config.json"}]}]`,
+			false,
+		},
+		{
+			"target-used-as-alias",
+			`[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "mergeInto": "config.json"}]},
+			  {"objectName": "b", "objectAlias": "config.json"}]`,
+			true,
+		},
+		{
+			"alias-used-as-target",
+			`[{"objectName": "config.json"},
+			  {"objectName": "b", "jmesPath": [{"path": "x", "objectAlias": "x", "mergeInto": "config.json"}]}]`,
+			true,
+		},
+		{"with-fanOut", `[{"objectName": "a", "jmesPath": [{"path": "x", "fanOut": true, "mergeInto": "c"}]}]`, true},
+		{"translated-path", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "mergeInto": "../c"}]}]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := NewSecretObjectList("/mnt", "False", "", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "mergeInto": "../c"}]}]`, PodMetadata{}); err == nil {
+		t.Errorf("expected an error for a mergeInto path escaping the mount dir")
+	}
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NameRewrite renames the file an object derives from its objectName, e.g. to
+// strip an environment prefix of hierarchical parameter names.
+type NameRewrite struct {
+	// Regular expression matched against the name, every match is replaced.
+	Pattern string `json:"pattern"`
+
+	// Replacement of each match, where $1 or ${name} expand to the groups of the pattern.
+	Replacement string `json:"replacement"`
+
+	// Lowercase the name after the replacement.
+	Lowercase bool `json:"lowercase"`
+}
+
+// Compile the nameRewrite of the object spec.
+func (s *SecretObject) compileNameRewrite() (err error) {
+	if s.NameRewrite == nil {
+		return nil
+	}
+	if len(s.NameRewrite.Pattern) == 0 && !s.NameRewrite.Lowercase {
+		return fmt.Errorf("nameRewrite of object %s requires a pattern or lowercase", s.ObjectName)
+	}
+	if len(s.NameRewrite.Pattern) > 0 {
+		s.nameRewriteRE, err = regexp.Compile(s.NameRewrite.Pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid nameRewrite pattern for object %s: %+v", s.ObjectName, err)
+		}
+	}
+	return nil
+}
+
+// Apply the nameRewrite of the object to a name derived from its objectName.
+func (s *SecretObject) rewriteName(name string) string {
+	if s.NameRewrite == nil {
+		return name
+	}
+	if s.nameRewriteRE != nil {
+		name = s.nameRewriteRE.ReplaceAllString(name, s.NameRewrite.Replacement)
+	}
+	if s.NameRewrite.Lowercase {
+		name = strings.ToLower(name)
+	}
+	return name
+}
@@ -0,0 +1,36 @@
+package provider
+
+import "testing"
+
+func TestNewSecretObjectListNameRewrite(t *testing.T) {
+	tests := []struct {
+		name      string
+		translate string
+		spec      string
+		wantFile  string
+		wantErr   bool
+	}{
+		{"strip-env", "", `[{"objectName": "prod/app/db-password", "nameRewrite": {"pattern": "^(prod|staging)/", "replacement": ""}}]`, "app_db-password", false},
+		{"capture-groups", "False", `[{"objectName": "app/prod/db", "nameRewrite": {"pattern": "^([^/]+)/([^/]+)/(.+)$", "replacement": "$2/$1-$3"}}]`, "prod/app-db", false},
+		{"named-group", "", `[{"objectName": "DB_PASSWORD_V2", "nameRewrite": {"pattern": "^(?P<key>.+)_V[0-9]+$", "replacement": "${key}", "lowercase": true}}]`, "db_password", false},
+		{"lowercase-only", "", `[{"objectName": "App/DB", "nameRewrite": {"lowercase": true}}]`, "app_db", false},
+		{"after-strip-prefix", "False", `[{"objectName": "/prod/app/DB", "stripPrefix": "/prod", "nameRewrite": {"pattern": "^app/", "replacement": "", "lowercase": true}}]`, "db", false},
+		{"alias-untouched", "", `[{"objectName": "prod/db", "objectAlias": "Prod-DB", "nameRewrite": {"pattern": "^prod/", "replacement": "", "lowercase": true}}]`, "Prod-DB", false},
+		{"no-match", "", `[{"objectName": "db", "nameRewrite": {"pattern": "^prod/", "replacement": ""}}]`, "db", false},
+		{"invalid-pattern", "", `[{"objectName": "db", "nameRewrite": {"pattern": "(", "replacement": ""}}]`, "", true},
+		{"empty", "", `[{"objectName": "db", "nameRewrite": {"replacement": "x"}}]`, "", true},
+		{"escapes-mount", "False", `[{"objectName": "app/db", "nameRewrite": {"pattern": "^app/", "replacement": "../"}}]`, "", true},
+		{"empty-result", "", `[{"objectName": "prod", "nameRewrite": {"pattern": ".*", "replacement": ""}}]`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", tt.translate, "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && objects[0].GetFileName() != tt.wantFile {
+				t.Errorf("GetFileName() = %s, want %s", objects[0].GetFileName(), tt.wantFile)
+			}
+		})
+	}
+}
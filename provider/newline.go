@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Values of newlineStyle.
+const (
+	newlinePreserve = "preserve" // The bytes as stored
+	newlineLF       = "lf"       // Line breaks written as \n
+	newlineCRLF     = "crlf"     // Line breaks written as \r\n
+)
+
+// Check the newlineStyle of the object spec.
+func (s *SecretObject) validateNewlineStyle() error {
+	switch s.NewlineStyle {
+	case "", newlinePreserve:
+		return nil
+	case newlineLF, newlineCRLF:
+	default:
+		return fmt.Errorf("Invalid newlineStyle %q for object %s, expected %q, %q or %q", s.NewlineStyle, s.ObjectName, newlineLF, newlineCRLF, newlinePreserve)
+	}
+	if s.isDataKey() {
+		return fmt.Errorf("newlineStyle is not supported for datakey object: %s", s.ObjectName)
+	}
+	return nil
+}
+
+// Append a line break, \r\n with the crlf newlineStyle, to a string value not
+// ending with one. Empty values are left empty for failOnEmpty, and binary
+// values are never modified.
+func (s *SecretObject) ensureTrailingNewline(value []byte) []byte {
+	if !s.EnsureTrailingNewline || len(value) == 0 || !utf8.Valid(value) || bytes.HasSuffix(value, []byte("\n")) {
+		return value
+	}
+	value = value[:len(value):len(value)] // Never write past a trimmed value
+	if s.NewlineStyle == newlineCRLF {
+		return append(value, '\r', '\n')
+	}
+	return append(value, '\n')
+}
+
+// Rewrite the line breaks of a string value in the newlineStyle of the
+// object. Values that are not valid UTF-8 are binary and never modified.
+func (s *SecretObject) normalizeNewlines(value []byte) []byte {
+	if (s.NewlineStyle != newlineLF && s.NewlineStyle != newlineCRLF) || !utf8.Valid(value) {
+		return value
+	}
+	value = bytes.ReplaceAll(value, []byte("\r\n"), []byte("\n"))
+	if s.NewlineStyle == newlineCRLF {
+		value = bytes.ReplaceAll(value, []byte("\n"), []byte("\r\n"))
+	}
+	return value
+}
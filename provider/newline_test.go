@@ -0,0 +1,105 @@
+package provider
+
+import "testing"
+
+func TestNormalizeNewlines(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     []byte
+		style     string
+		trimSpace bool
+		want      string
+	}{
+		{"preserve-default", []byte("a\r\nb\n"), "", false, "a\r\nb\n"},
+		{"preserve", []byte("a\r\nb\n"), "preserve", false, "a\r\nb\n"},
+		{"lf", []byte("a\r\nb\nc\r\n"), "lf", false, "a\nb\nc\n"},
+		{"crlf", []byte("a\r\nb\nc"), "crlf", false, "a\r\nb\r\nc"},
+		{"lone-cr-kept", []byte("a\rb\r\n"), "lf", false, "a\rb\n"},
+		{"trimmed-first", []byte("a\r\nb\r\n"), "crlf", true, "a\r\nb"},
+		{"binary", []byte{0xff, '\r', '\n'}, "lf", false, string([]byte{0xff, '\r', '\n'})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &SecretValue{
+				Value:     tt.value,
+				SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, NewlineStyle: tt.style, TrimSpace: tt.trimSpace},
+			}
+			sv.transform()
+			if string(sv.Value) != tt.want {
+				t.Errorf("transform() got = %q, want %q", sv.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureTrailingNewline(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     []byte
+		style     string
+		trimSpace bool
+		want      string
+	}{
+		{"appended", []byte("a"), "", false, "a\n"},
+		{"already-ending", []byte("a\n"), "", false, "a\n"},
+		{"single", []byte("a\n\n"), "", false, "a\n\n"},
+		{"trimmed-first", []byte(" a \n\n"), "", true, "a\n"},
+		{"crlf", []byte("a\nb"), "crlf", false, "a\r\nb\r\n"},
+		{"crlf-already-ending", []byte("a\r\n"), "crlf", false, "a\r\n"},
+		{"empty", []byte(""), "", false, ""},
+		{"binary", []byte{0xff, 'a'}, "", false, string([]byte{0xff, 'a'})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &SecretValue{
+				Value:     tt.value,
+				SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, NewlineStyle: tt.style, TrimSpace: tt.trimSpace, EnsureTrailingNewline: true},
+			}
+			sv.transform()
+			if string(sv.Value) != tt.want {
+				t.Errorf("transform() got = %q, want %q", sv.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewlineStyleJMESPath(t *testing.T) {
+	spec := `[{"objectName": "tls", "newlineStyle": "lf", "jmesPath": [{"path": "cert", "objectAlias": "cert"}, {"path": "cert", "objectAlias": "cert.b64", "encoding": "base64"}]}]`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	sv := &SecretValue{Value: []byte(`{"cert": "line1\r\nline2\r\n"}`), SecretObj: *objects[0]}
+	sv.transform()
+	values, err := sv.getJsonSecrets()
+	if err != nil {
+		t.Fatalf("getJsonSecrets() error = %v", err)
+	}
+	if string(values[0].Value) != "line1\nline2\n" {
+		t.Errorf("expected the extracted value to be normalized, got %q", values[0].Value)
+	}
+	if string(values[1].Value) != "bGluZTEKbGluZTIK" { // base64 of "line1\nline2\n"
+		t.Errorf("expected the value to be normalized before its encoding, got %q", values[1].Value)
+	}
+}
+
+func TestNewSecretObjectListNewlineStyle(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"lf", `[{"objectName": "a", "newlineStyle": "lf"}]`, false},
+		{"crlf", `[{"objectName": "a", "newlineStyle": "crlf"}]`, false},
+		{"preserve", `[{"objectName": "a", "newlineStyle": "preserve"}]`, false},
+		{"unknown", `[{"objectName": "a", "newlineStyle": "cr"}]`, true},
+		{"datakey", `[{"objectName": "k", "objectType": "datakey", "objectAlias": "k", "ciphertextAlias": "c", "newlineStyle": "lf"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
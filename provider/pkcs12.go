@@ -0,0 +1,236 @@
+package provider
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"unicode/utf16"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+	gopkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// PKCS12Bundle combines a PEM certificate and private key mounted by the
+// objects of the mount into a PKCS#12 keystore, e.g. for JVM workloads.
+type PKCS12Bundle struct {
+	// File name of the keystore, e.g. keystore.p12.
+	ObjectAlias string `json:"objectAlias"`
+
+	// File name of the PEM certificate in the mount, followed by its chain.
+	CertFile string `json:"certFile"`
+
+	// File name of the PEM private key of the certificate in the mount.
+	KeyFile string `json:"keyFile"`
+
+	// Password of the keystore, or the file name in the mount holding it.
+	Password     string `json:"password"`
+	PasswordFile string `json:"passwordFile"`
+}
+
+// Type of the keystore files in the manifest, which combine several files.
+const manifestTypePKCS12 = "pkcs12"
+
+// Check the pkcs12 bundle of the object spec. The files it combines are
+// checked against the other outputs of the mount by NewSecretObjectList.
+func (s *SecretObject) validatePKCS12() error {
+	bundle := s.PKCS12
+	if bundle == nil {
+		return nil
+	}
+	if len(bundle.ObjectAlias) == 0 || len(bundle.CertFile) == 0 || len(bundle.KeyFile) == 0 {
+		return fmt.Errorf("pkcs12 of object %s requires an objectAlias, a certFile and a keyFile", s.ObjectName)
+	}
+	if (len(bundle.Password) > 0) == (len(bundle.PasswordFile) > 0) {
+		return fmt.Errorf("pkcs12 of object %s requires either a password or a passwordFile", s.ObjectName)
+	}
+	if err := checkPKCS12Password(bundle.Password); err != nil {
+		return fmt.Errorf("Invalid pkcs12 password of object %s: %v", s.ObjectName, err)
+	}
+	bundleObj := s.getPKCS12SecretObject()
+	if isDirectoryName(bundleObj.GetFileName()) {
+		return fmt.Errorf("File name of pkcs12 %q is empty or a directory", bundle.ObjectAlias)
+	}
+	if badPathRE.MatchString(bundleObj.GetFileName()) {
+		return fmt.Errorf("path can not contain ../: %s", bundle.ObjectAlias)
+	}
+	return nil
+}
+
+func (p *SecretObject) getPKCS12SecretObject() (d SecretObject) {
+	return SecretObject{
+		ObjectAlias:  p.PKCS12.ObjectAlias,
+		LeadingSlash: p.LeadingSlash,
+		translate:    p.translate,
+		mountDir:     p.mountDir,
+	}
+}
+
+// Files of the mount a pkcs12 bundle is built from, with the field naming each.
+func (b *PKCS12Bundle) sourceFiles() [][2]string {
+	files := [][2]string{{"certFile", b.CertFile}, {"keyFile", b.KeyFile}}
+	if len(b.PasswordFile) > 0 {
+		files = append(files, [2]string{"passwordFile", b.PasswordFile})
+	}
+	return files
+}
+
+// Build the pkcs12 bundles of the objects from the values of the mount, and
+// record their versions, made of the names and versions of their source files
+// and a digest of an inline password, in the current version map. A bundle
+// whose sources and password are unchanged keeps the mounted keystore, since
+// every build encrypts with a new salt.
+func (p *SecretsManagerProvider) pkcs12Values(secretObjs []*SecretObject, values []*SecretValue, curMap map[string]*v1alpha1.ObjectVersion) ([]*SecretValue, error) {
+	var bundles []*SecretValue
+	byName := make(map[string]*SecretValue, len(values))
+	for _, value := range values {
+		byName[value.SecretObj.GetFileName()] = value
+	}
+	for _, secObj := range secretObjs {
+		if secObj.PKCS12 == nil {
+			continue
+		}
+		sources := make(map[string][]byte)
+		version := ""
+		for _, file := range secObj.PKCS12.sourceFiles() {
+			value, ok := byName[file[1]]
+			if !ok {
+				return nil, fmt.Errorf("%s %s of the pkcs12 bundle of object %s is not mounted", file[0], file[1], secObj.ObjectName)
+			}
+			sources[file[0]] = value.Value
+			if len(version) > 0 {
+				version += ","
+			}
+			version += file[0] + ":" + file[1] + "=" + value.version
+		}
+		if len(secObj.PKCS12.Password) > 0 {
+			version += ",password=" + pkcs12PasswordDigest(secObj.PKCS12.Password)
+		}
+
+		bundleObj := secObj.getPKCS12SecretObject()
+		bundleObj.fileMode = secObj.fileMode
+		prior := curMap[bundleObj.GetFileName()]
+		changed := prior == nil || prior.Version != version
+		var keystore []byte
+		if !changed {
+			if data, err := p.readMounted(&bundleObj); err == nil {
+				keystore = data
+			}
+		}
+		if keystore == nil {
+			password := secObj.PKCS12.Password
+			if len(secObj.PKCS12.PasswordFile) > 0 {
+				password = string(bytes.TrimSpace(sources["passwordFile"]))
+			}
+			var err error
+			keystore, err = encodePKCS12(sources["certFile"], sources["keyFile"], password)
+			if err != nil {
+				return nil, fmt.Errorf("Failed building the pkcs12 bundle %s of object %s: %v", bundleObj.GetFileName(), secObj.ObjectName, err)
+			}
+		}
+		bundles = append(bundles, &SecretValue{Value: keystore, SecretObj: bundleObj, changed: changed,
+			objectType: manifestTypePKCS12, version: version})
+		curMap[bundleObj.GetFileName()] = &v1alpha1.ObjectVersion{
+			Id:      bundleObj.GetFileName(),
+			Version: version,
+		}
+	}
+	return bundles, nil
+}
+
+// Encode a PEM certificate chain and private key as a password protected
+// PKCS#12 keystore. The key and certificates are encrypted with
+// pbeWithSHAAnd3-KeyTripleDES-CBC and the keystore is authenticated with
+// HMAC-SHA1, which every JVM and OpenSSL version reads. Neither the errors nor
+// the logs ever include the key.
+func encodePKCS12(certPEM, keyPEM []byte, password string) ([]byte, error) {
+	certs, err := parsePEMCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePEMPrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := key.(interface{ Public() crypto.PublicKey })
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type")
+	}
+	if certKey, ok := certs[0].PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !certKey.Equal(public.Public()) {
+		return nil, fmt.Errorf("the private key does not match the first certificate")
+	}
+	keystore, err := gopkcs12.LegacyDES.Encode(key, certs[0], certs[1:], password)
+	if err != nil {
+		return nil, fmt.Errorf("encoding the keystore: %v", err)
+	}
+	return keystore, nil
+}
+
+// Parse the certificates of a PEM file, the first being the one of the key.
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("the certificate file holds no PEM certificate")
+	}
+	return certs, nil
+}
+
+// Parse the first private key of a PEM file, in PKCS#8, PKCS#1 or SEC 1 form.
+func parsePEMPrivateKey(data []byte) (crypto.PrivateKey, error) {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid PKCS#8 private key")
+			}
+			return key, nil
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RSA private key")
+			}
+			return key, nil
+		case "EC PRIVATE KEY":
+			key, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EC private key")
+			}
+			return key, nil
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, fmt.Errorf("encrypted private keys are not supported")
+		}
+	}
+	return nil, fmt.Errorf("the key file holds no PEM private key")
+}
+
+// Check that a password can be encoded as the BMPString PKCS#12 derives keys
+// from, which can not hold characters outside of the basic multilingual plane.
+func checkPKCS12Password(password string) error {
+	for _, r := range password {
+		if r > 0xffff || utf16.IsSurrogate(r) {
+			return fmt.Errorf("character %U is not supported", r)
+		}
+	}
+	return nil
+}
+
+// Digest of an inline keystore password recorded in the bundle version, so a
+// changed password rebuilds the keystore without the password being recorded.
+func pkcs12PasswordDigest(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:8])
+}
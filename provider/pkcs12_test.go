@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+	gopkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// A self-signed PEM certificate and PEM private key for key.
+func testCertificate(t *testing.T, key crypto.Signer, keyType string, keyDER []byte) (string, string) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "app.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: keyType, Bytes: keyDER}))
+}
+
+func TestEncodePKCS12(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	pkcs8DER, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherDER, _ := x509.MarshalECPrivateKey(otherKey)
+
+	ecCert, ecPEM := testCertificate(t, ecKey, "EC PRIVATE KEY", ecDER)
+	_, pkcs8PEM := testCertificate(t, ecKey, "PRIVATE KEY", pkcs8DER)
+	rsaCert, rsaPEM := testCertificate(t, rsaKey, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	_, otherPEM := testCertificate(t, otherKey, "EC PRIVATE KEY", otherDER)
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		wantErr string
+	}{
+		{"ec", ecCert, ecPEM, ""},
+		{"pkcs8", ecCert, pkcs8PEM, ""},
+		{"rsa", rsaCert, rsaPEM, ""},
+		{"mismatched-key", ecCert, otherPEM, "does not match"},
+		{"no-certificate", ecPEM, ecPEM, "no PEM certificate"},
+		{"no-key", ecCert, ecCert, "no PEM private key"},
+		{"not-pem", "certificate", "key", "no PEM certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keystore, err := encodePKCS12([]byte(tt.cert), []byte(tt.key), "changeit")
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("encodePKCS12() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("encodePKCS12() error = %v", err)
+			}
+			key, cert, err := gopkcs12.Decode(keystore, "changeit")
+			if err != nil {
+				t.Fatalf("gopkcs12.Decode() error = %v", err)
+			}
+			if cert.Subject.CommonName != "app.example.com" || !cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(key.(crypto.Signer).Public()) {
+				t.Errorf("decoded a certificate and key which do not match")
+			}
+			if _, _, err = gopkcs12.Decode(keystore, "wrong"); err == nil {
+				t.Errorf("expected a wrong password to fail")
+			}
+		})
+	}
+}
+
+func TestPKCS12Bundle(t *testing.T) {
+	setupFetchTest(t)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	certPEM, keyPEM := testCertificate(t, ecKey, "EC PRIVATE KEY", ecDER)
+	tls, _ := json.Marshal(map[string]string{"cert": certPEM, "key": keyPEM})
+	client := &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		if tea.StringValue(request.SecretName) == "keystore-password" {
+			return kmsSecretResponse("changeit\n", "p1"), nil
+		}
+		return kmsSecretResponse(string(tls), "v1"), nil
+	}}
+	spec := `
+- objectName: "tls"
+  emitRawWhenJmes: false
+  jmesPath:
+    - path: "cert"
+      objectAlias: "tls.crt"
+    - path: "key"
+      objectAlias: "tls.key"
+  pkcs12:
+    objectAlias: "keystore.p12"
+    certFile: "tls.crt"
+    keyFile: "tls.key"
+    passwordFile: "keystore-password"
+- objectName: "keystore-password"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	fs := newMemFileSystem()
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	curMap := map[string]*v1alpha1.ObjectVersion{}
+	values, err := p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	keystore := values[len(values)-1]
+	if keystore.SecretObj.GetFileName() != "keystore.p12" || curMap["keystore.p12"].Version != "certFile:tls.crt=v1,keyFile:tls.key=v1,passwordFile:keystore-password=p1" {
+		t.Fatalf("expected the keystore last, got %s at %v", keystore.SecretObj.GetFileName(), curMap["keystore.p12"])
+	}
+	if _, _, err = gopkcs12.Decode(keystore.Value, "changeit"); err != nil {
+		t.Fatalf("gopkcs12.Decode() error = %v", err)
+	}
+
+	// Unchanged sources keep the mounted keystore instead of a new encryption.
+	fs.WriteFile("/mnt/keystore.p12", keystore.Value, 0644)
+	values, err = p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if string(values[len(values)-1].Value) != string(keystore.Value) {
+		t.Errorf("expected the mounted keystore to be kept")
+	}
+}
+
+func TestPKCS12BundleVersion(t *testing.T) {
+	setupFetchTest(t)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	certPEM, keyPEM := testCertificate(t, ecKey, "EC PRIVATE KEY", ecDER)
+	client := &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		if strings.HasPrefix(tea.StringValue(request.SecretName), "key") {
+			return kmsSecretResponse(keyPEM, "v1"), nil
+		}
+		return kmsSecretResponse(certPEM, "v1"), nil
+	}}
+	build := func(bundle string) (*SecretValue, string) {
+		spec := `[{"objectName": "cert", "pkcs12": ` + bundle + `}, {"objectName": "key"}, {"objectName": "key2"}]`
+		objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+		if err != nil {
+			t.Fatalf("NewSecretObjectList() error = %v", err)
+		}
+		fs := newMemFileSystem()
+		p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+		curMap := map[string]*v1alpha1.ObjectVersion{}
+		values, err := p.GetSecretValues(context.Background(), objects, curMap)
+		if err != nil {
+			t.Fatalf("GetSecretValues() error = %v", err)
+		}
+		return values[len(values)-1], curMap["ks.p12"].Version
+	}
+
+	keystore, version := build(`{"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key", "password": "changeit"}`)
+	if strings.Contains(version, "changeit") {
+		t.Errorf("expected the version %q not to hold the password", version)
+	}
+	if _, _, err := gopkcs12.Decode(keystore.Value, "changeit"); err != nil {
+		t.Fatalf("gopkcs12.Decode() error = %v", err)
+	}
+
+	// Another password or source file of the same version is another bundle.
+	if _, other := build(`{"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key", "password": "secret"}`); other == version {
+		t.Errorf("expected a new password to change the version %q", version)
+	}
+	if _, other := build(`{"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key2", "password": "changeit"}`); other == version {
+		t.Errorf("expected a new keyFile to change the version %q", version)
+	}
+	if _, same := build(`{"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key", "password": "changeit"}`); same != version {
+		t.Errorf("expected the same bundle to keep the version %q, got %q", version, same)
+	}
+}
+
+func TestNewSecretObjectListPKCS12(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"valid", `[{"objectName": "cert", "pkcs12": {"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key", "password": "changeit"}}, {"objectName": "key"}]`, false},
+		{"password-file", `[{"objectName": "cert", "pkcs12": {"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key", "passwordFile": "pw"}}, {"objectName": "key"}, {"objectName": "pw"}]`, false},
+		{"no-alias", `[{"objectName": "cert", "pkcs12": {"certFile": "cert", "keyFile": "key", "password": "changeit"}}, {"objectName": "key"}]`, true},
+		{"no-key-file", `[{"objectName": "cert", "pkcs12": {"objectAlias": "ks.p12", "certFile": "cert", "password": "changeit"}}]`, true},
+		{"no-password", `[{"objectName": "cert", "pkcs12": {"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key"}}, {"objectName": "key"}]`, true},
+		{"both-passwords", `[{"objectName": "cert", "pkcs12": {"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key", "password": "a", "passwordFile": "cert"}}, {"objectName": "key"}]`, true},
+		{"missing-key-object", `[{"objectName": "cert", "pkcs12": {"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key", "password": "changeit"}}]`, true},
+		{"missing-password-object", `[{"objectName": "cert", "pkcs12": {"objectAlias": "ks.p12", "certFile": "cert", "keyFile": "key", "passwordFile": "pw"}}, {"objectName": "key"}]`, true},
+		{"collides-with-object", `[{"objectName": "cert", "pkcs12": {"objectAlias": "key", "certFile": "cert", "keyFile": "key", "password": "changeit"}}, {"objectName": "key"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
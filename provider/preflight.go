@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// PrevalidateSecrets makes GetSecretValues check that the KMS secrets of a
+// mount exist with DescribeSecret before fetching any value, failing with the
+// complete list of missing secrets. It costs one extra call per secret that is
+// not mounted yet.
+var PrevalidateSecrets = false
+
+// Check that every required KMS secret which is not mounted yet exists. Only
+// missing secrets are reported here; other errors, such as a policy not
+// granting kms:DescribeSecret, are left for the value fetch to report.
+func (p *SecretsManagerProvider) prevalidateSecrets(
+	ctx context.Context,
+	secretObjs []*SecretObject,
+	curMap map[string]*v1alpha1.ObjectVersion,
+) error {
+	var missing []string
+	for _, secObj := range secretObjs {
+		if !secObj.isKMS() || !secObj.isRequired() || curMap[secObj.GetFileName()] != nil {
+			continue
+		}
+		_, err := p.describeSecret(ctx, secObj, false)
+		if err == nil {
+			continue
+		}
+		if !isNotFound(err) {
+			klog.Warningf("failed to pre-validate secret %s: %s", secObj.ObjectName, err.Error())
+			continue
+		}
+		missing = append(missing, secObj.ObjectName)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Secrets not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Call DescribeSecret for a KMS secret, which returns its metadata only, with
+// its tags when fetchTags is set.
+func (p *SecretsManagerProvider) describeSecret(ctx context.Context, secObj *SecretObject, fetchTags bool) (*kms.DescribeSecretResponse, error) {
+	fetchTimeoutCtx, cancel := p.fetchContext(ctx, secObj)
+	defer cancel()
+	if err := waitForToken(fetchTimeoutCtx, LimiterInstance.Kms); err != nil {
+		return nil, err
+	}
+	client, err := p.kmsClientFor(secObj)
+	if err != nil {
+		return nil, err
+	}
+	request := &kms.DescribeSecretRequest{SecretName: tea.String(secObj.ObjectName)}
+	if fetchTags {
+		request.FetchTags = tea.String("true")
+	}
+	var response *kms.DescribeSecretResponse
+	err = p.withRetry(fetchTimeoutCtx, ObjectTypeKMS, secObj, func() (err error) {
+		response, err = client.DescribeSecret(request)
+		return p.accessDeniedError(err, "kms:DescribeSecret", secObj)
+	})
+	return response, err
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestGetSecretValuesPrevalidate(t *testing.T) {
+	setupFetchTest(t)
+	oldPrevalidate := PrevalidateSecrets
+	defer func() { PrevalidateSecrets = oldPrevalidate }()
+	PrevalidateSecrets = true
+
+	var described []string
+	client := &mockKmsClient{
+		describeSecret: func(request *kms.DescribeSecretRequest) (*kms.DescribeSecretResponse, error) {
+			name := tea.StringValue(request.SecretName)
+			described = append(described, name)
+			switch name {
+			case "missing1", "missing2", "optional":
+				return nil, &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound")}
+			case "denied":
+				return nil, &tea.SDKError{Code: tea.String("Forbidden.NoPermission")}
+			}
+			return &kms.DescribeSecretResponse{}, nil
+		},
+		getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+			return kmsSecretResponse("secret", "v1"), nil
+		},
+	}
+	p := &SecretsManagerProvider{KmsClient: client}
+	spec := `
+- objectName: "missing1"
+- objectName: "exists"
+- objectName: "missing2"
+- objectName: "optional"
+  required: false
+- objectName: "mounted"
+- objectName: "denied"
+- objectName: "param"
+  objectType: "oos"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := map[string]*v1alpha1.ObjectVersion{"mounted": {Id: "mounted", Version: "v1"}}
+
+	_, err = p.GetSecretValues(context.Background(), objects, curMap)
+	if err == nil || !strings.Contains(err.Error(), "missing1, missing2") {
+		t.Fatalf("expected both missing secrets to be reported, got %v", err)
+	}
+	if strings.Join(described, ",") != "missing1,exists,missing2,denied" {
+		t.Errorf("described %v, expected required kms secrets that are not mounted only", described)
+	}
+	if client.calls != len(described) {
+		t.Errorf("expected no value fetch before the pre-validation passes, got %d calls", client.calls)
+	}
+
+	// Other errors are left for the value fetch.
+	client.describeSecret = func(*kms.DescribeSecretRequest) (*kms.DescribeSecretResponse, error) {
+		return nil, errors.New("connection refused")
+	}
+	objects, _ = NewSecretObjectList("/mnt", "", "", `[{"objectName": "exists"}]`, PodMetadata{})
+	if _, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion)); err != nil {
+		t.Errorf("GetSecretValues() error = %v, expected the fetch to go ahead", err)
+	}
+}
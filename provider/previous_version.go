@@ -0,0 +1,148 @@
+package provider
+
+import (
+	"context"
+	"strconv"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	oos "github.com/alibabacloud-go/oos-20190601/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"k8s.io/klog/v2"
+)
+
+// Suffix appended to the file name of an object to name the file holding its
+// previous version.
+const previousFileSuffix = ".prev"
+
+// Page size used when listing the versions of an OOS parameter.
+const parameterVersionPageSize = int32(50)
+
+func (p *SecretObject) getPreviousSecretObject() (d SecretObject) {
+	return SecretObject{
+		ObjectAlias:           p.GetFileName() + previousFileSuffix,
+		TrimSpace:             p.TrimSpace,
+		NewlineStyle:          p.NewlineStyle,
+		EnsureTrailingNewline: p.EnsureTrailingNewline,
+		translate:             p.translate,
+		mountDir:              p.mountDir,
+	}
+}
+
+// Build the <file name>.prev file of an object holding the version before the
+// fetched one, or nil when the object has a single version. A reloaded object
+// keeps the previous version already mounted.
+func (p *SecretsManagerProvider) previousSecretFor(ctx context.Context, secret *SecretValue, version string, reloaded bool) (*SecretValue, error) {
+	prevObj := secret.SecretObj.getPreviousSecretObject()
+	if reloaded {
+		value, err := p.readMounted(&prevObj)
+		if err != nil {
+			return nil, nil // Nothing was mounted, the object had a single version
+		}
+		return &SecretValue{Value: value, SecretObj: prevObj}, nil
+	}
+
+	fetchTimeoutCtx, cancel := p.fetchContext(ctx, &secret.SecretObj)
+	defer cancel()
+	var value []byte
+	var err error
+	if secret.SecretObj.ObjectType == ObjectTypeOOS {
+		value, err = p.getOOSPreviousValue(fetchTimeoutCtx, &secret.SecretObj, version)
+	} else {
+		value, err = p.getKMSPreviousValue(fetchTimeoutCtx, &secret.SecretObj, version)
+	}
+	if err != nil || value == nil {
+		return nil, err
+	}
+	prev := &SecretValue{Value: value, SecretObj: prevObj}
+	prev.transform()
+	if err = p.process(ctx, &secret.SecretObj, prev); err != nil {
+		return nil, err
+	}
+	if err = prev.applyJQFilter(ctx); err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
+
+// Fetch the ACSPrevious version of a KMS secret, or nil when the secret has no
+// previous version or it is the fetched version.
+func (p *SecretsManagerProvider) getKMSPreviousValue(ctx context.Context, secObj *SecretObject, version string) ([]byte, error) {
+	if err := waitForToken(ctx, LimiterInstance.Kms); err != nil {
+		return nil, err
+	}
+	client, err := p.kmsClientFor(secObj)
+	if err != nil {
+		return nil, err
+	}
+	var response *kms.GetSecretValueResponse
+	err = p.withRetry(ctx, ObjectTypeKMS, secObj, func() (err error) {
+		response, err = client.GetSecretValue(&kms.GetSecretValueRequest{
+			SecretName:   tea.String(secObj.ObjectName),
+			VersionStage: tea.String(KMS_PREVIOUS_VERSION_STAGE),
+		})
+		return p.accessDeniedError(err, "kms:GetSecretValue", secObj)
+	})
+	if isNotFound(err) {
+		klog.Infof("secret %s has no previous version", secObj.ObjectName)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tea.StringValue(response.Body.VersionId) == version {
+		return nil, nil
+	}
+	return []byte(tea.StringValue(response.Body.SecretData)), nil
+}
+
+// Fetch the latest version of an OOS parameter older than the fetched one, or
+// nil when the parameter has a single version.
+func (p *SecretsManagerProvider) getOOSPreviousValue(ctx context.Context, secObj *SecretObject, version string) ([]byte, error) {
+	current, err := strconv.Atoi(version)
+	if err != nil {
+		return nil, nil // Unversioned response, there is nothing to compare with
+	}
+	if err = waitForToken(ctx, LimiterInstance.OOS); err != nil {
+		return nil, err
+	}
+	client, err := p.oosClientFor(secObj)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *oos.ListSecretParameterVersionsResponseBodyParameterVersions
+	var nextToken *string
+	for {
+		var response *oos.ListSecretParameterVersionsResponse
+		err = p.withRetry(ctx, ObjectTypeOOS, secObj, func() (err error) {
+			response, err = client.ListSecretParameterVersions(&oos.ListSecretParameterVersionsRequest{
+				Name:           tea.String(secObj.parameterName()),
+				WithDecryption: tea.Bool(true),
+				MaxResults:     tea.Int32(parameterVersionPageSize),
+				NextToken:      nextToken,
+			})
+			return p.accessDeniedError(err, "oos:ListSecretParameterVersions", secObj)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if response.Body == nil {
+			break
+		}
+		for _, v := range response.Body.ParameterVersions {
+			n := int(tea.Int32Value(v.ParameterVersion))
+			if n < current && (previous == nil || n > int(tea.Int32Value(previous.ParameterVersion))) {
+				previous = v
+			}
+		}
+		nextToken = response.Body.NextToken
+		if len(tea.StringValue(nextToken)) == 0 || len(response.Body.ParameterVersions) == 0 {
+			break
+		}
+	}
+	if previous == nil {
+		klog.Infof("parameter %s has no previous version", secObj.ObjectName)
+		return nil, nil
+	}
+	return []byte(tea.StringValue(previous.Value)), nil
+}
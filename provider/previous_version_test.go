@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	oos "github.com/alibabacloud-go/oos-20190601/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+type mockOosClient struct {
+	getSecretParameter          func(*oos.GetSecretParameterRequest) (*oos.GetSecretParameterResponse, error)
+	listSecretParameterVersions func(*oos.ListSecretParameterVersionsRequest) (*oos.ListSecretParameterVersionsResponse, error)
+	calls                       int
+}
+
+func (m *mockOosClient) GetSecretParameter(request *oos.GetSecretParameterRequest) (*oos.GetSecretParameterResponse, error) {
+	m.calls++
+	return m.getSecretParameter(request)
+}
+
+func (m *mockOosClient) ListSecretParameterVersions(request *oos.ListSecretParameterVersionsRequest) (*oos.ListSecretParameterVersionsResponse, error) {
+	m.calls++
+	return m.listSecretParameterVersions(request)
+}
+
+// An OOS client for a parameter whose versions 1 to len(values) hold values,
+// listed two per page, newest first.
+func newVersionedOosClient(values ...string) *mockOosClient {
+	return &mockOosClient{
+		getSecretParameter: func(request *oos.GetSecretParameterRequest) (*oos.GetSecretParameterResponse, error) {
+			version := int32(len(values))
+			if request.ParameterVersion != nil {
+				version = *request.ParameterVersion
+			}
+			return &oos.GetSecretParameterResponse{Body: &oos.GetSecretParameterResponseBody{
+				Parameter: &oos.GetSecretParameterResponseBodyParameter{
+					Value:            tea.String(values[version-1]),
+					ParameterVersion: tea.Int32(version),
+				},
+			}}, nil
+		},
+		listSecretParameterVersions: func(request *oos.ListSecretParameterVersionsRequest) (*oos.ListSecretParameterVersionsResponse, error) {
+			start := len(values)
+			if request.NextToken != nil {
+				start, _ = strconv.Atoi(tea.StringValue(request.NextToken))
+			}
+			body := &oos.ListSecretParameterVersionsResponseBody{}
+			for v := start; v > 0 && v > start-2; v-- {
+				body.ParameterVersions = append(body.ParameterVersions, &oos.ListSecretParameterVersionsResponseBodyParameterVersions{
+					ParameterVersion: tea.Int32(int32(v)),
+					Value:            tea.String(values[v-1]),
+				})
+			}
+			if start > 2 {
+				body.NextToken = tea.String(strconv.Itoa(start - 2))
+			}
+			return &oos.ListSecretParameterVersionsResponse{Body: body}, nil
+		},
+	}
+}
+
+func TestGetSecretValuesPreviousVersionOOS(t *testing.T) {
+	setupFetchTest(t)
+	tests := []struct {
+		name        string
+		values      []string
+		spec        string
+		wantVersion string
+		wantPrev    string
+	}{
+		{"latest", []string{"one", "two", "three", "four", "five"}, `[{"objectName": "p", "objectType": "oos", "includePreviousVersion": true}]`, "5", "four"},
+		{"pinned", []string{"one", "two", "three", "four", "five"}, `[{"objectName": "p", "objectType": "oos", "objectVersion": "3", "includePreviousVersion": true}]`, "3", "two"},
+		{"single-version", []string{"one"}, `[{"objectName": "p", "objectType": "oos", "includePreviousVersion": true}]`, "1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &SecretsManagerProvider{OosClient: newVersionedOosClient(tt.values...), FS: newMemFileSystem()}
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			curMap := make(map[string]*v1alpha1.ObjectVersion)
+			values, err := p.GetSecretValues(context.Background(), objects, curMap)
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if curMap["p"].Version != tt.wantVersion {
+				t.Errorf("version = %s, want %s", curMap["p"].Version, tt.wantVersion)
+			}
+			if len(tt.wantPrev) == 0 {
+				if len(values) != 1 {
+					t.Errorf("expected no previous version file, got %d values", len(values))
+				}
+				return
+			}
+			if len(values) != 2 || values[1].SecretObj.GetFileName() != "p.prev" || string(values[1].Value) != tt.wantPrev {
+				t.Fatalf("expected p.prev holding %q, got %d values", tt.wantPrev, len(values))
+			}
+		})
+	}
+}
+
+func TestGetSecretValuesPreviousVersionKMS(t *testing.T) {
+	setupFetchTest(t)
+	client := &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		if tea.StringValue(request.VersionStage) == KMS_PREVIOUS_VERSION_STAGE {
+			return kmsSecretResponse(" old ", "v1"), nil
+		}
+		return kmsSecretResponse(" new ", "v2"), nil
+	}}
+	fs := newMemFileSystem()
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "s", "objectVersion": "v2", "trimSpace": true, "includePreviousVersion": true}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := make(map[string]*v1alpha1.ObjectVersion)
+	values, err := p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if len(values) != 2 || string(values[1].Value) != "old" || values[1].SecretObj.GetFileName() != "s.prev" {
+		t.Fatalf("expected a trimmed s.prev file, got %d values", len(values))
+	}
+
+	// A current object keeps the mounted previous version without API calls.
+	for _, v := range values {
+		fs.WriteFile(v.SecretObj.GetMountPath(), v.Value, 0644)
+	}
+	client.calls = 0
+	values, err = p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if client.calls != 0 || len(values) != 2 || string(values[1].Value) != "old" {
+		t.Errorf("expected the mounted s.prev to be reloaded, got %d values after %d calls", len(values), client.calls)
+	}
+
+	// No previous version stage.
+	client.getSecretValue = func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		if tea.StringValue(request.VersionStage) == KMS_PREVIOUS_VERSION_STAGE {
+			return nil, &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound")}
+		}
+		return kmsSecretResponse("new", "v2"), nil
+	}
+	values, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+	if err != nil || len(values) != 1 {
+		t.Errorf("expected no previous version file, got %d values (%v)", len(values), err)
+	}
+}
+
+func TestNewSecretObjectListPreviousVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"oos-version", `[{"objectName": "p", "objectType": "oos", "objectVersion": "2"}]`, false},
+		{"oos-bad-version", `[{"objectName": "p", "objectType": "oos", "objectVersion": "v2"}]`, true},
+		{"name-in-use", `[{"objectName": "s", "includePreviousVersion": true}, {"objectName": "t", "objectAlias": "s.prev"}]`, true},
+		{"datakey", `[{"objectName": "key", "objectType": "datakey", "objectAlias": "k", "ciphertextAlias": "k.enc", "includePreviousVersion": true}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
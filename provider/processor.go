@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+)
+
+// SecretProcessor runs custom logic on each fetched value before it is
+// written, e.g. to decrypt it with an in-cluster key or reformat it.
+//
+// Processors see the value of an object after trimSpace and the failOnEmpty,
+// valuePattern and expectedSha256 checks, which apply to the value as fetched,
+// and before jmesPath entries, their encodings, mergeInto files and the files
+// derived from the object are built from it. The previous version of an
+// includePreviousVersion object is processed too. Values reloaded from the
+// mount, or served stale by the circuit breaker, were processed when they
+// were fetched and are not processed again. GetSecret returns processed values.
+//
+// Only the Value of the returned SecretValue is used, the object and its file
+// name stay the same. A processor must not keep the value after returning.
+type SecretProcessor interface {
+	Process(ctx context.Context, secret *SecretValue) (*SecretValue, error)
+}
+
+// Run the processors of the provider on a fetched value of secObj, in
+// registration order. An error fails the object.
+func (p *SecretsManagerProvider) process(ctx context.Context, secObj *SecretObject, secret *SecretValue) error {
+	for i, processor := range p.Processors {
+		processed, err := processor.Process(ctx, secret)
+		if err != nil {
+			return fmt.Errorf("Processor %d failed for object %s: %w", i, secObj.ObjectName, err)
+		}
+		if processed == nil {
+			return fmt.Errorf("Processor %d returned no value for object %s", i, secObj.ObjectName)
+		}
+		secret.Value = processed.Value
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+type processorFunc func(*SecretValue) (*SecretValue, error)
+
+func (f processorFunc) Process(_ context.Context, secret *SecretValue) (*SecretValue, error) {
+	return f(secret)
+}
+
+func TestGetSecretValuesProcessors(t *testing.T) {
+	setupFetchTest(t)
+	kmsClient := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(` {"user": "admin"} `, "v1"), nil
+	}}
+	calls := 0
+	upper := processorFunc(func(sv *SecretValue) (*SecretValue, error) {
+		calls++
+		return &SecretValue{Value: bytes.ToUpper(sv.Value)}, nil
+	})
+	rename := processorFunc(func(sv *SecretValue) (*SecretValue, error) {
+		return &SecretValue{Value: bytes.ReplaceAll(sv.Value, []byte("USER"), []byte("name")), SecretObj: SecretObject{ObjectAlias: "other"}}, nil
+	})
+	fs := newMemFileSystem()
+	p := &SecretsManagerProvider{KmsClient: kmsClient, FS: fs, Processors: []SecretProcessor{upper, rename}}
+	objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db", "objectVersion": "v1", "trimSpace": true, "valuePattern": "^[{]", "jmesPath": [{"path": "name", "objectAlias": "name"}]}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := make(map[string]*v1alpha1.ObjectVersion)
+	values, err := p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if len(values) != 2 || string(values[0].Value) != `{"name": "ADMIN"}` || values[0].SecretObj.GetFileName() != "db" || string(values[1].Value) != "ADMIN" {
+		t.Fatalf("expected the processors to run in order before jmesPath, got %d values", len(values))
+	}
+
+	// Mounted values were processed already.
+	for _, v := range values {
+		fs.WriteFile(v.SecretObj.GetMountPath(), v.Value, 0644)
+	}
+	if _, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil || calls != 1 {
+		t.Errorf("expected reloaded values not to be processed again, processed %d times (%v)", calls, err)
+	}
+}
+
+func TestGetSecretValuesProcessorError(t *testing.T) {
+	setupFetchTest(t)
+	kmsClient := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse("value", "v1"), nil
+	}}
+	errDecrypt := errors.New("decryption failed")
+	tests := []struct {
+		name      string
+		processor processorFunc
+		wantIs    error
+	}{
+		{"error", func(*SecretValue) (*SecretValue, error) { return nil, errDecrypt }, errDecrypt},
+		{"no-value", func(*SecretValue) (*SecretValue, error) { return nil, nil }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &SecretsManagerProvider{KmsClient: kmsClient, FS: newMemFileSystem(), Processors: []SecretProcessor{tt.processor}}
+			objects, _ := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db"}]`, PodMetadata{})
+			_, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+			if err == nil || !bytes.Contains([]byte(err.Error()), []byte("object db")) {
+				t.Fatalf("GetSecretValues() error = %v, want an error naming the object", err)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("GetSecretValues() error = %v, want it to wrap %v", err, tt.wantIs)
+			}
+		})
+	}
+}
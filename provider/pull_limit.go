@@ -2,10 +2,69 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/metrics"
 	"golang.org/x/time/rate"
+	"time"
 )
 
+// LimiterWaitTimeout bounds how long a fetch waits for a rate token before
+// failing, independently of the overall fetch timeout.
+var LimiterWaitTimeout = 30 * time.Second
+
+// ErrLimiterTimeout is returned when a rate token could not be acquired within LimiterWaitTimeout.
+var ErrLimiterTimeout = errors.New("timed out waiting for secret pull rate limiter")
+
+var errEmptyLimiter = errors.New("secret pull limiter is empty")
+
+// ConcurrencyLimiter caps the number of SDK calls in flight across all mounts
+// served by the process. A nil limiter does not limit anything.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter returns a limiter allowing max calls in flight, or nil
+// (no limit) when max is not positive.
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &ConcurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// Acquire blocks until a slot is free or the context is done.
+func (c *ConcurrencyLimiter) Acquire(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for an in-flight secret pull slot: %w", ctx.Err())
+	}
+}
+
+// Release frees a slot taken by Acquire.
+func (c *ConcurrencyLimiter) Release() {
+	if c == nil {
+		return
+	}
+	<-c.slots
+}
+
+// Run f while holding a slot of the limiter. Slots are only held for the
+// duration of a single SDK call, never across retries or nested calls, so
+// concurrent mounts can not deadlock on each other.
+func (c *ConcurrencyLimiter) Do(ctx context.Context, f func() error) error {
+	if err := c.Acquire(ctx); err != nil {
+		return err
+	}
+	defer c.Release()
+	return f()
+}
+
 type PullLimit interface {
 	Wait(context.Context) error
 }
@@ -16,7 +75,7 @@ type KmsLimiter struct {
 
 func (k KmsLimiter) Wait(c context.Context) error {
 	if k.SecretPullLimiter == nil {
-		return fmt.Errorf("secret pull limiter is empty")
+		return errEmptyLimiter
 	}
 	return k.SecretPullLimiter.Wait(c)
 }
@@ -27,7 +86,39 @@ type OosLimiter struct {
 
 func (o OosLimiter) Wait(c context.Context) error {
 	if o.SecretPullLimiter == nil {
-		return fmt.Errorf("secret pull limiter is empty")
+		return errEmptyLimiter
 	}
 	return o.SecretPullLimiter.Wait(c)
 }
+
+// Return the backend a limiter belongs to, for metrics.
+func limiterBackend(limiter PullLimit) string {
+	if _, ok := limiter.(OosLimiter); ok {
+		return ObjectTypeOOS
+	}
+	return ObjectTypeKMS
+}
+
+// Wait for a token from the limiter, failing fast with ErrLimiterTimeout when
+// none is available within LimiterWaitTimeout. When ctx is done first, or its
+// deadline comes before LimiterWaitTimeout, the error of ctx is returned
+// instead and no limiter timeout is counted.
+func waitForToken(ctx context.Context, limiter PullLimit) error {
+	waitCtx, cancel := context.WithTimeout(ctx, LimiterWaitTimeout)
+	defer cancel()
+	err := limiter.Wait(waitCtx)
+	if err == nil || errors.Is(err, errEmptyLimiter) {
+		return err
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	// The limiter fails without waiting when the token would come after the
+	// deadline of waitCtx, which is the deadline of ctx when it is the earlier.
+	waitDeadline, _ := waitCtx.Deadline()
+	if deadline, ok := ctx.Deadline(); ok && !deadline.After(waitDeadline) {
+		return fmt.Errorf("%w: %s", context.DeadlineExceeded, err.Error())
+	}
+	metrics.LimiterTimeouts.Add(limiterBackend(limiter), 1)
+	return fmt.Errorf("%w after %s: %s", ErrLimiterTimeout, LimiterWaitTimeout, err.Error())
+}
@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/metrics"
+	"golang.org/x/time/rate"
+)
+
+// Read the value of a backend in an expvar map of the metrics package.
+func counter(m interface{ Get(string) expvar.Var }, backend string) int64 {
+	if v, ok := m.Get(backend).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestFetchSecretSaturatedLimiter(t *testing.T) {
+	oldLimiter, oldTimeout := LimiterInstance, LimiterWaitTimeout
+	defer func() { LimiterInstance, LimiterWaitTimeout = oldLimiter, oldTimeout }()
+
+	// One token per hour with the only token already consumed.
+	limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+	limiter.Allow()
+	LimiterInstance.Kms.SecretPullLimiter = limiter
+	LimiterInstance.OOS.SecretPullLimiter = limiter
+	LimiterWaitTimeout = 50 * time.Millisecond
+
+	p := &SecretsManagerProvider{}
+	for _, objectType := range []string{ObjectTypeKMS, ObjectTypeOOS} {
+		timeouts := counter(metrics.LimiterTimeouts, objectType)
+		start := time.Now()
+		_, _, err := p.fetchSecret(context.Background(), &SecretObject{ObjectName: "MySecret", ObjectType: objectType})
+		if !errors.Is(err, ErrLimiterTimeout) {
+			t.Fatalf("expected limiter timeout error for %s, got: %v", objectType, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("limiter wait for %s took %s, expected to fail fast", objectType, elapsed)
+		}
+		if got := counter(metrics.LimiterTimeouts, objectType) - timeouts; got != 1 {
+			t.Errorf("counted %d limiter timeouts for %s, want 1", got, objectType)
+		}
+	}
+}
+
+func TestWaitForTokenRequestDone(t *testing.T) {
+	oldTimeout := LimiterWaitTimeout
+	defer func() { LimiterWaitTimeout = oldTimeout }()
+	LimiterWaitTimeout = time.Minute
+
+	// One token per hour with the only token already consumed.
+	limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+	limiter.Allow()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expiring, cancelExpiring := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelExpiring()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{"cancelled", cancelled, context.Canceled},
+		{"deadline-before-wait-timeout", expiring, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeouts := counter(metrics.LimiterTimeouts, ObjectTypeKMS)
+			err := waitForToken(tt.ctx, KmsLimiter{SecretPullLimiter: limiter})
+			if !errors.Is(err, tt.wantErr) || errors.Is(err, ErrLimiterTimeout) {
+				t.Fatalf("waitForToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := counter(metrics.LimiterTimeouts, ObjectTypeKMS) - timeouts; got != 0 {
+				t.Errorf("counted %d limiter timeouts, want 0", got)
+			}
+		})
+	}
+}
+
+func TestFetchSecretEmptyLimiter(t *testing.T) {
+	oldLimiter := LimiterInstance
+	defer func() { LimiterInstance = oldLimiter }()
+	LimiterInstance = Limiter{}
+
+	p := &SecretsManagerProvider{}
+	_, _, err := p.fetchSecret(context.Background(), &SecretObject{ObjectName: "MySecret"})
+	if err == nil || errors.Is(err, ErrLimiterTimeout) {
+		t.Fatalf("expected empty limiter error, got: %v", err)
+	}
+}
+
+func TestConcurrencyLimiterCancel(t *testing.T) {
+	c := NewConcurrencyLimiter(1)
+	if err := c.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled acquire, got: %v", err)
+	}
+	c.Release()
+	if err := c.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() after Release() error = %v", err)
+	}
+
+	var unlimited *ConcurrencyLimiter
+	if err := unlimited.Do(ctx, func() error { return nil }); err != nil {
+		t.Fatalf("nil limiter should not limit, got: %v", err)
+	}
+}
+
+func TestConcurrencyLimiterCapsInFlight(t *testing.T) {
+	const max = 2
+	c := NewConcurrencyLimiter(max)
+	var inFlight, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = c.Do(context.Background(), func() error {
+				n := atomic.AddInt32(&inFlight, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&inFlight, -1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if peak > max {
+		t.Errorf("peak in-flight calls = %d, want <= %d", peak, max)
+	}
+}
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"sync"
+)
+
+// maxRefreshTokens bounds the honored refresh tokens kept by the provider. When
+// it is reached they are all forgotten, which costs at most one extra fetch of
+// each object with a refreshToken.
+const maxRefreshTokens = 4096
+
+// The refreshToken last honored for each object of each mount, keyed by
+// refreshTokenKey. The provider is rebuilt for every mount request, so the
+// record lives in the process.
+type refreshTokens struct {
+	mu      sync.Mutex
+	honored map[string]string
+}
+
+var honoredRefreshTokens refreshTokens
+
+// Key of the honored token of an object, its file in its mount.
+func (s *SecretObject) refreshTokenKey() string {
+	return s.mountDir + "\x00" + s.GetFileName()
+}
+
+// Report whether the refreshToken of the object was not honored yet, so the
+// mounted version must not be reused on this sync.
+func (s *SecretObject) refreshPending() bool {
+	if len(s.RefreshToken) == 0 {
+		return false
+	}
+	honoredRefreshTokens.mu.Lock()
+	defer honoredRefreshTokens.mu.Unlock()
+	return honoredRefreshTokens.honored[s.refreshTokenKey()] != s.RefreshToken
+}
+
+// Record the refreshToken of the object as honored after its value was fetched,
+// so later syncs use the mounted version again until the token changes.
+func (s *SecretObject) honorRefreshToken() {
+	if len(s.RefreshToken) == 0 {
+		return
+	}
+	honoredRefreshTokens.mu.Lock()
+	defer honoredRefreshTokens.mu.Unlock()
+	if honoredRefreshTokens.honored == nil || len(honoredRefreshTokens.honored) >= maxRefreshTokens {
+		honoredRefreshTokens.honored = make(map[string]string)
+	}
+	honoredRefreshTokens.honored[s.refreshTokenKey()] = s.RefreshToken
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestRefreshToken(t *testing.T) {
+	setupFetchTest(t)
+	t.Cleanup(func() { honoredRefreshTokens = refreshTokens{} })
+	var failing bool
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		if failing {
+			return nil, errors.New("backend down")
+		}
+		return kmsSecretResponse("value", "v1"), nil
+	}}
+	fs := newMemFileSystem()
+	fs.WriteFile("/mnt/db", []byte("value"), 0644)
+	sync := func(token string) error {
+		spec := `[{"objectName": "db", "objectVersion": "v1", "refreshToken": "` + token + `"}]`
+		objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+		if err != nil {
+			t.Fatalf("NewSecretObjectList() error = %v", err)
+		}
+		p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+		_, err = p.GetSecretValues(context.Background(), objects, map[string]*v1alpha1.ObjectVersion{"db": {Id: "db", Version: "v1"}})
+		return err
+	}
+	steps := []struct {
+		token     string
+		failing   bool
+		wantCalls int
+	}{
+		{"", false, 0},  // No token, the mounted version is reused
+		{"1", false, 1}, // A new token fetches once
+		{"1", false, 1}, // and then the mounted version is reused again
+		{"2", true, 2},  // A failed refresh is not honored
+		{"2", false, 3}, // so the next sync tries again
+		{"2", false, 3},
+	}
+	for i, step := range steps {
+		failing = step.failing
+		if err := sync(step.token); (err != nil) != step.failing {
+			t.Fatalf("step %d: GetSecretValues() error = %v", i, err)
+		}
+		if client.calls != step.wantCalls {
+			t.Fatalf("step %d: expected %d fetches in total, got %d", i, step.wantCalls, client.calls)
+		}
+	}
+}
+
+func TestRefreshTokenPerMount(t *testing.T) {
+	t.Cleanup(func() { honoredRefreshTokens = refreshTokens{} })
+	a := &SecretObject{ObjectName: "db", RefreshToken: "1", mountDir: "/mnt/a"}
+	b := &SecretObject{ObjectName: "db", RefreshToken: "1", mountDir: "/mnt/b"}
+	a.honorRefreshToken()
+	if a.refreshPending() || !b.refreshPending() {
+		t.Errorf("expected the token to be honored for the first mount only")
+	}
+}
@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Check the requiredKeys of the object spec.
+func (s *SecretObject) validateRequiredKeys() error {
+	if len(s.RequiredKeys) == 0 {
+		return nil
+	}
+	if s.isDataKey() {
+		return fmt.Errorf("requiredKeys is not supported for datakey object: %s", s.ObjectName)
+	}
+	if s.formatsStringList() {
+		return fmt.Errorf("requiredKeys can not be used with stringListFormat %s: %s", s.StringListFormat, s.ObjectName)
+	}
+	seen := make(map[string]bool, len(s.RequiredKeys))
+	for _, key := range s.RequiredKeys {
+		if len(key) == 0 {
+			return fmt.Errorf("requiredKeys can not be empty for object: %s", s.ObjectName)
+		}
+		if seen[key] {
+			return fmt.Errorf("Duplicate required key %q for object: %s", key, s.ObjectName)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Check that a fetched value is a JSON object holding every key of the
+// requiredKeys of its object. The error lists the missing keys, never a value.
+func (sv *SecretValue) checkRequiredKeys() error {
+	if len(sv.SecretObj.RequiredKeys) == 0 {
+		return nil
+	}
+	data, err := sv.jsonData()
+	if err != nil {
+		return fmt.Errorf("Value of secret %s is not JSON, its requiredKeys can not be checked", sv.SecretObj.ObjectName)
+	}
+	document, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Value of secret %s is not a JSON object, its requiredKeys can not be checked", sv.SecretObj.ObjectName)
+	}
+	var missing []string
+	for _, key := range sv.SecretObj.RequiredKeys {
+		if _, ok := document[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Secret %s is missing required keys: %s", sv.SecretObj.ObjectName, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestCheckRequiredKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		keys    []string
+		wantErr string
+	}{
+		{"present", `{"user": "admin", "password": "s3cr3t", "host": null}`, []string{"user", "password", "host"}, ""},
+		{"missing", `{"user": "admin", "token": "s3cr3t"}`, []string{"user", "password", "host"}, "missing required keys: password, host"},
+		{"nested-not-top-level", `{"db": {"password": "s3cr3t"}}`, []string{"password"}, "missing required keys: password"},
+		{"array", `["s3cr3t"]`, []string{"password"}, "not a JSON object"},
+		{"not-json", `s3cr3t`, []string{"password"}, "not JSON"},
+		{"no-keys", `s3cr3t`, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &SecretValue{Value: []byte(tt.value), SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, RequiredKeys: tt.keys}}
+			err := sv.checkRequiredKeys()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("checkRequiredKeys() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("checkRequiredKeys() error = %v, want %q", err, tt.wantErr)
+			}
+			if strings.Contains(err.Error(), "s3cr3t") || strings.Contains(err.Error(), "admin") {
+				t.Errorf("checkRequiredKeys() error leaks the value: %v", err)
+			}
+		})
+	}
+}
+
+func TestRequiredKeysWithJMESPath(t *testing.T) {
+	setupFetchTest(t)
+	value := `{"user": "admin"}`
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(value, "v1"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client, FS: newMemFileSystem()}
+	spec := `[{"objectName": "db", "requiredKeys": ["user", "password"], "jmesPath": [{"path": "user", "objectAlias": "user"}]}]`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	if _, err = p.GetSecretValues(context.Background(), objects, map[string]*v1alpha1.ObjectVersion{}); err == nil || !strings.Contains(err.Error(), "password") {
+		t.Fatalf("expected an error naming the missing key, got %v", err)
+	}
+
+	value = `{"user": "admin", "password": "s3cr3t"}`
+	secrets, err := p.GetSecretValues(context.Background(), objects, map[string]*v1alpha1.ObjectVersion{})
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if len(secrets) != 2 || string(secrets[1].Value) != "admin" {
+		t.Errorf("expected the raw value and the user entry, got %d values", len(secrets))
+	}
+}
+
+func TestNewSecretObjectListRequiredKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"keys", `[{"objectName": "a", "requiredKeys": ["user", "password"]}]`, false},
+		{"empty-key", `[{"objectName": "a", "requiredKeys": [""]}]`, true},
+		{"duplicate-key", `[{"objectName": "a", "requiredKeys": ["user", "user"]}]`, true},
+		{"datakey", `[{"objectName": "k", "objectType": "datakey", "objectAlias": "k", "ciphertextAlias": "c", "requiredKeys": ["user"]}]`, true},
+		{"string-list", `[{"objectName": "p", "objectType": "oos", "stringListFormat": "json", "requiredKeys": ["user"]}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
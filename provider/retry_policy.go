@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Upper bound of the maxRetries of an object.
+const maxObjectRetries = 10
+
+// Retry and timeout settings of the requests made for an object.
+type retryPolicy struct {
+	maxRetries    int
+	retryInterval time.Duration
+	fetchTimeout  time.Duration
+}
+
+// Resolve the retry policy of an object: its maxRetries, retryInterval and
+// fetchTimeout, else the MaxRetries, RetryInterval and FetchTimeout of the
+// provider, else BACKOFF_DEFAULT_MAX_RETRIES, BACKOFF_DEFAULT_RETRY_INTERVAL
+// and FETCH_DEFAULT_TIMEOUT.
+func (p *SecretsManagerProvider) retryPolicyFor(secObj *SecretObject) retryPolicy {
+	policy := retryPolicy{
+		maxRetries:    BACKOFF_DEFAULT_MAX_RETRIES,
+		retryInterval: BACKOFF_DEFAULT_RETRY_INTERVAL,
+		fetchTimeout:  FETCH_DEFAULT_TIMEOUT,
+	}
+	if p.MaxRetries != nil {
+		policy.maxRetries = *p.MaxRetries
+	}
+	if p.RetryInterval > 0 {
+		policy.retryInterval = p.RetryInterval
+	}
+	if p.FetchTimeout > 0 {
+		policy.fetchTimeout = p.FetchTimeout
+	}
+	if secObj == nil {
+		return policy
+	}
+	if secObj.MaxRetries != nil {
+		policy.maxRetries = *secObj.MaxRetries
+	}
+	if secObj.retryInterval > 0 {
+		policy.retryInterval = secObj.retryInterval
+	}
+	if secObj.fetchTimeout > 0 {
+		policy.fetchTimeout = secObj.fetchTimeout
+	}
+	return policy
+}
+
+// Return a context bounded by the fetch timeout of the object.
+func (p *SecretsManagerProvider) fetchContext(ctx context.Context, secObj *SecretObject) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, p.retryPolicyFor(secObj).fetchTimeout)
+}
+
+// ValidateRetryPolicy checks the retry and timeout settings of a provider,
+// which follow the bounds of the maxRetries, retryInterval and fetchTimeout of
+// an object.
+func ValidateRetryPolicy(maxRetries int, retryInterval, fetchTimeout time.Duration) error {
+	if maxRetries < 0 || maxRetries > maxObjectRetries {
+		return fmt.Errorf("max-retries must be between 0 and %d", maxObjectRetries)
+	}
+	if retryInterval <= 0 || fetchTimeout <= 0 {
+		return fmt.Errorf("retry-interval and fetch-timeout must be positive durations")
+	}
+	return nil
+}
+
+// Check the maxRetries, retryInterval and fetchTimeout of the object spec and
+// parse the durations.
+func (s *SecretObject) validateRetryPolicy() (err error) {
+	if s.MaxRetries != nil && (*s.MaxRetries < 0 || *s.MaxRetries > maxObjectRetries) {
+		return fmt.Errorf("maxRetries of object %s must be between 0 and %d", s.ObjectName, maxObjectRetries)
+	}
+	if len(s.RetryInterval) > 0 {
+		s.retryInterval, err = time.ParseDuration(s.RetryInterval)
+		if err != nil || s.retryInterval <= 0 {
+			return fmt.Errorf("Invalid retryInterval %q for object %s, expected a positive duration such as 500ms", s.RetryInterval, s.ObjectName)
+		}
+	}
+	if len(s.FetchTimeout) > 0 {
+		s.fetchTimeout, err = time.ParseDuration(s.FetchTimeout)
+		if err != nil || s.fetchTimeout <= 0 {
+			return fmt.Errorf("Invalid fetchTimeout %q for object %s, expected a positive duration such as 30s", s.FetchTimeout, s.ObjectName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/metrics"
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	oos "github.com/alibabacloud-go/oos-20190601/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+func TestRetryPolicyFor(t *testing.T) {
+	three := 3
+	zero := 0
+	tests := []struct {
+		name     string
+		provider *SecretsManagerProvider
+		spec     string
+		want     retryPolicy
+	}{
+		{"package-defaults", &SecretsManagerProvider{}, `[{"objectName": "s"}]`,
+			retryPolicy{BACKOFF_DEFAULT_MAX_RETRIES, BACKOFF_DEFAULT_RETRY_INTERVAL, FETCH_DEFAULT_TIMEOUT}},
+		{"provider", &SecretsManagerProvider{MaxRetries: &three, RetryInterval: time.Second, FetchTimeout: time.Minute}, `[{"objectName": "s"}]`,
+			retryPolicy{3, time.Second, time.Minute}},
+		{"object", &SecretsManagerProvider{MaxRetries: &three, RetryInterval: time.Second, FetchTimeout: time.Minute},
+			`[{"objectName": "s", "maxRetries": 0, "retryInterval": "250ms", "fetchTimeout": "10s"}]`,
+			retryPolicy{0, 250 * time.Millisecond, 10 * time.Second}},
+		{"partial-object", &SecretsManagerProvider{MaxRetries: &zero}, `[{"objectName": "s", "fetchTimeout": "10s"}]`,
+			retryPolicy{0, BACKOFF_DEFAULT_RETRY_INTERVAL, 10 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			if got := tt.provider.retryPolicyFor(objects[0]); got != tt.want {
+				t.Errorf("retryPolicyFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectRetryPolicyFetch(t *testing.T) {
+	setupFetchTest(t)
+	var sleeps []time.Duration
+	oldSleep := sleep
+	defer func() { sleep = oldSleep }()
+	sleep = func(_ context.Context, d time.Duration) error { sleeps = append(sleeps, d); return nil }
+
+	throttled := &tea.SDKError{Code: tea.String(REJECTED_THROTTLING)}
+	kmsClient := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return nil, throttled
+	}}
+	oosClient := &mockOosClient{getSecretParameter: func(*oos.GetSecretParameterRequest) (*oos.GetSecretParameterResponse, error) {
+		return nil, throttled
+	}}
+	p := &SecretsManagerProvider{KmsClient: kmsClient, OosClient: oosClient}
+	spec := `
+- objectName: "s"
+  maxRetries: 3
+  retryInterval: "100ms"
+- objectName: "p"
+  objectType: "oos"
+  maxRetries: 2
+  retryInterval: "50ms"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	kmsExhausted, oosExhausted := counter(metrics.RetriesExhausted, ObjectTypeKMS), counter(metrics.RetriesExhausted, ObjectTypeOOS)
+	for _, secObj := range objects {
+		if _, _, err = p.fetchSecret(context.Background(), secObj); err == nil {
+			t.Fatalf("expected fetching %s to fail", secObj.ObjectName)
+		}
+	}
+	if kmsClient.calls != 4 || oosClient.calls != 3 {
+		t.Errorf("made %d kms and %d oos calls, want 4 and 3", kmsClient.calls, oosClient.calls)
+	}
+	if counter(metrics.RetriesExhausted, ObjectTypeKMS)-kmsExhausted != 1 || counter(metrics.RetriesExhausted, ObjectTypeOOS)-oosExhausted != 1 {
+		t.Errorf("expected one exhausted retry budget per backend")
+	}
+	want := []time.Duration{200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond}
+	if len(sleeps) != len(want) {
+		t.Fatalf("backoffs = %v, want %v", sleeps, want)
+	}
+	for i := range want {
+		if sleeps[i] != want[i] {
+			t.Errorf("backoffs = %v, want %v", sleeps, want)
+			break
+		}
+	}
+}
+
+func TestNewSecretObjectListRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"valid", `[{"objectName": "s", "maxRetries": 5, "retryInterval": "1s", "fetchTimeout": "1m"}]`, false},
+		{"negative-retries", `[{"objectName": "s", "maxRetries": -1}]`, true},
+		{"too-many-retries", `[{"objectName": "s", "maxRetries": 11}]`, true},
+		{"bad-interval", `[{"objectName": "s", "retryInterval": "fast"}]`, true},
+		{"zero-timeout", `[{"objectName": "s", "fetchTimeout": "0s"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxRetries    int
+		retryInterval time.Duration
+		fetchTimeout  time.Duration
+		wantErr       bool
+	}{
+		{"defaults", BACKOFF_DEFAULT_MAX_RETRIES, BACKOFF_DEFAULT_RETRY_INTERVAL, FETCH_DEFAULT_TIMEOUT, false},
+		{"no-retries", 0, time.Second, time.Minute, false},
+		{"negative-retries", -1, time.Second, time.Minute, true},
+		{"too-many-retries", 11, time.Second, time.Minute, true},
+		{"zero-interval", 1, 0, time.Minute, true},
+		{"zero-timeout", 1, time.Second, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateRetryPolicy(tt.maxRetries, tt.retryInterval, tt.fetchTimeout); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRetryPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
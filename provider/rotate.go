@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"k8s.io/klog/v2"
+)
+
+// ErrRotationDisabled is returned by RotateSecret unless EnableRotation is set.
+var ErrRotationDisabled = errors.New("secret rotation is disabled")
+
+// RotateSecret asks KMS to rotate the secret named name (a name or an ARN). It is meant for out-of-band tooling, is never
+// called while mounting, and fails with ErrRotationDisabled unless the
+// provider has EnableRotation set. The call is rate limited like fetches but
+// not retried, since a failed rotation may still have happened.
+func (p *SecretsManagerProvider) RotateSecret(ctx context.Context, name string) error {
+	if !p.EnableRotation {
+		return ErrRotationDisabled
+	}
+	secObj := &SecretObject{ObjectName: name, ObjectType: ObjectTypeKMS}
+	if err := secObj.validateSecretObject(); err != nil {
+		return err
+	}
+
+	fetchTimeoutCtx, cancel := p.fetchContext(ctx, secObj)
+	defer cancel()
+	if err := waitForToken(fetchTimeoutCtx, LimiterInstance.Kms); err != nil {
+		return err
+	}
+	client, err := p.kmsClientFor(secObj)
+	if err != nil {
+		return err
+	}
+
+	request := &kms.RotateSecretRequest{SecretName: tea.String(name)}
+	var response *kms.RotateSecretResponse
+	err = LimiterInstance.InFlight.Do(fetchTimeoutCtx, func() (err error) {
+		response, err = client.RotateSecret(request)
+		return p.accessDeniedError(err, "kms:RotateSecret", secObj)
+	})
+	if err != nil {
+		klog.Error(err, "failed to rotate secret in kms", "key", name)
+		return fmt.Errorf("Failed rotating secret %s: %w", name, err)
+	}
+	klog.Infof("rotated secret %s to version %s", name, tea.StringValue(response.Body.VersionId))
+	return nil
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestRotateSecret(t *testing.T) {
+	setupFetchTest(t)
+	client := &mockKmsClient{
+		rotateSecret: func(request *kms.RotateSecretRequest) (*kms.RotateSecretResponse, error) {
+			if tea.StringValue(request.SecretName) != "MySecret" {
+				return nil, &tea.SDKError{Code: tea.String("Forbidden.ResourceNotFound")}
+			}
+			return &kms.RotateSecretResponse{Body: &kms.RotateSecretResponseBody{VersionId: tea.String("v2")}}, nil
+		},
+		getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+			return kmsSecretResponse("value", "v1"), nil
+		},
+	}
+	p := &SecretsManagerProvider{KmsClient: client}
+
+	if err := p.RotateSecret(context.Background(), "MySecret"); !errors.Is(err, ErrRotationDisabled) {
+		t.Fatalf("RotateSecret() error = %v, want %v", err, ErrRotationDisabled)
+	}
+	if client.calls != 0 {
+		t.Fatalf("RotateSecret() called KMS while disabled")
+	}
+
+	// Mounting never rotates, even when rotation is enabled.
+	p.EnableRotation = true
+	if _, err := p.GetSecretValues(context.Background(), []*SecretObject{{ObjectName: "MySecret"}}, make(map[string]*v1alpha1.ObjectVersion)); err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if client.calls != 1 {
+		t.Fatalf("GetSecretValues() made %d calls, want 1", client.calls)
+	}
+
+	if err := p.RotateSecret(context.Background(), "MySecret"); err != nil {
+		t.Errorf("RotateSecret() error = %v", err)
+	}
+	if err := p.RotateSecret(context.Background(), "Missing"); err == nil {
+		t.Errorf("expected an error rotating a missing secret")
+	}
+	if err := p.RotateSecret(context.Background(), ""); err == nil {
+		t.Errorf("expected an error rotating an empty name")
+	}
+}
@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSecretRotating is returned for a failDuringRotation object while its
+// rotation is in progress. The driver retries the mount, which succeeds once
+// the rotation completes.
+var ErrSecretRotating = errors.New("secret is being rotated, retry once the rotation completes")
+
+// Fail with ErrSecretRotating when a version of the KMS secret is in the
+// ACSPending stage, which only exists while a rotation is in progress.
+// DescribeSecret reports the rotation schedule but not whether one is running,
+// so the stages come from ListSecretVersionIds. A missing secret is left for
+// the fetch to report.
+func (p *SecretsManagerProvider) checkRotation(ctx context.Context, secObj *SecretObject) error {
+	_, pending, err := p.findVersionStage(ctx, secObj, KMS_PENDING_VERSION_STAGE)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pending {
+		return fmt.Errorf("%w: %s has a version in stage %s", ErrSecretRotating, secObj.ObjectName, KMS_PENDING_VERSION_STAGE)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestGetSecretValuesFailDuringRotation(t *testing.T) {
+	setupFetchTest(t)
+	tests := []struct {
+		name      string
+		spec      string
+		stages    []string
+		wantErr   bool
+		wantLists int
+	}{
+		{"rotating", `[{"objectName": "s", "failDuringRotation": true}]`, []string{KMS_CURRENT_VERSION_STAGE, KMS_PENDING_VERSION_STAGE}, true, 1},
+		{"settled", `[{"objectName": "s", "failDuringRotation": true}]`, []string{KMS_CURRENT_VERSION_STAGE, KMS_PREVIOUS_VERSION_STAGE}, false, 1},
+		{"default-off", `[{"objectName": "s"}]`, []string{KMS_CURRENT_VERSION_STAGE, KMS_PENDING_VERSION_STAGE}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lists := 0
+			client := &mockKmsClient{
+				listSecretVersionIds: func(*kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error) {
+					lists++
+					var versions []*kms.ListSecretVersionIdsResponseBodyVersionIdsVersionId
+					for i, stage := range tt.stages {
+						versions = append(versions, &kms.ListSecretVersionIdsResponseBodyVersionIdsVersionId{
+							VersionId:     tea.String("v" + strconv.Itoa(i+1)),
+							VersionStages: &kms.ListSecretVersionIdsResponseBodyVersionIdsVersionIdVersionStages{VersionStage: []*string{tea.String(stage)}},
+						})
+					}
+					return &kms.ListSecretVersionIdsResponse{Body: &kms.ListSecretVersionIdsResponseBody{
+						TotalCount: tea.Int32(int32(len(versions))),
+						VersionIds: &kms.ListSecretVersionIdsResponseBodyVersionIds{VersionId: versions},
+					}}, nil
+				},
+				getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+					return kmsSecretResponse("value", "v1"), nil
+				},
+			}
+			p := &SecretsManagerProvider{KmsClient: client, FS: newMemFileSystem()}
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			_, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+			if tt.wantErr != errors.Is(err, ErrSecretRotating) {
+				t.Errorf("GetSecretValues() error = %v, want ErrSecretRotating %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("GetSecretValues() error = %v", err)
+			}
+			if lists != tt.wantLists {
+				t.Errorf("listed the versions %d times, want %d", lists, tt.wantLists)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListFailDuringRotation(t *testing.T) {
+	if _, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "p", "objectType": "oos", "failDuringRotation": true}]`, PodMetadata{}); err == nil {
+		t.Errorf("expected failDuringRotation to be rejected for oos parameters")
+	}
+}
@@ -1,24 +1,68 @@
 package provider
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/utils"
+	"github.com/itchyny/gojq"
+	"github.com/jmespath/go-jmespath"
 	"k8s.io/klog/v2"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sigs.k8s.io/yaml"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
 )
 
 // An RE pattern to check for bad paths
-var badPathRE = regexp.MustCompile("(/../)|(^../)|(/..$)")
+var badPathRE = regexp.MustCompile(`(/\.\./)|(^\.\./)|(/\.\.$)|(^\.{1,2}$)`)
+
+// Values of leadingSlash.
+const (
+	leadingSlashStrip     = "strip"
+	leadingSlashTranslate = "translate"
+)
+
+// Extension value asking for the extension of the jmesPath path to be used.
+const extensionAuto = "auto"
+
+// Encodings of jmesPath entries.
+const (
+	jmesEncodingNone   = "none"
+	jmesEncodingBase64 = "base64"
+	jmesEncodingHex    = "hex"
+)
+
+// Extensions allowed on jmesPath file names, e.g. yaml or tar.gz.
+var extensionRE = regexp.MustCompile(`^[A-Za-z0-9]+(\.[A-Za-z0-9]+)*$`)
+
+// An RE pattern for a hex SHA-256 digest.
+var sha256RE = regexp.MustCompile(`^[0-9A-Fa-f]{64}$`)
+
+// Placeholder for the version of the secret in objectAlias, rendered after fetch.
+const versionPlaceholder = "{{.Version}}"
+
+// Metadata of the pod being mounted, available as placeholders in objectAlias
+// (e.g. {{.Namespace}}/{{.PodName}}).
+type PodMetadata struct {
+	Namespace      string
+	PodName        string
+	ServiceAccount string
+}
 
 // An individual record from the mount request indicating the secret to be
 // fetched and mounted.
 type SecretObject struct {
 
-	// Name of the secret
+	// Name of the secret. In the YAML spec this may also be a list of names,
+	// which is expanded into one object per name.
 	ObjectName string `json:"objectName"`
 
 	// Optional base file name in which to store the secret (use ObjectName if nil).
@@ -30,12 +74,167 @@ type SecretObject struct {
 	// Optional version/stage label of the secret (defaults to latest).
 	ObjectVersionLabel string `json:"objectVersionLabel"`
 
+	// Optional flag to fetch the object again on every sync, even when --check-current-version finds it current.
+	// An objectVersionLabel of LATEST sets it.
+	AlwaysLatest bool `json:"alwaysLatest"`
+
+	// Optional flag to treat the fetched value as the name of the secret to mount, following a single level.
+	FollowIndirection bool `json:"followIndirection"`
+
+	// Optional flag to split an objectName of the form name@version at its last "@" for any version,
+	// not only for a version stage or "*".
+	NameVersionShorthand bool `json:"nameVersionShorthand"`
+
+	// Optional token forcing a single fetch of the object on the next sync whenever it changes.
+	RefreshToken string `json:"refreshToken"`
+
+	// Optional flag to fail the mount while a rotation of the KMS secret is in progress (defaults to false).
+	FailDuringRotation bool `json:"failDuringRotation"`
+
 	// Optional type of the secret (defaults to kms)
 	ObjectType string `json:"objectType"`
 
+	// Optional region of the secret (defaults to the ARN region or the mount region).
+	Region string `json:"region"`
+
+	// Optional KMS endpoint of the object, overriding the endpoint of its region.
+	KmsEndpoint string `json:"kmsEndpoint"`
+
+	// Optional KMS endpoints tried in order when the endpoint of a kms secret can not be reached.
+	KmsFallbackEndpoints []string `json:"kmsFallbackEndpoints"`
+
+	// Optional leading path removed from the object name when deriving the file name (defaults to the mount stripPrefix).
+	StripPrefix string `json:"stripPrefix"`
+
+	// Optional regular expression replacement renaming the file name derived from objectName, applied
+	// after stripPrefix and before pathTranslation. An objectAlias is used as is.
+	NameRewrite *NameRewrite `json:"nameRewrite"`
+
+	// Optional handling of a leading slash in the file name, "strip" or "translate" (defaults to
+	// translate with pathTranslation and to strip without).
+	LeadingSlash string `json:"leadingSlash"`
+
+	// Name of the file holding the ciphertext blob of a datakey object (required for datakey).
+	CiphertextAlias string `json:"ciphertextAlias"`
+
+	// Optional data key spec of a datakey object, AES_256 or AES_128 (defaults to AES_256).
+	KeySpec string `json:"keySpec"`
+
+	// Optional encryption context bound to the ciphertext of a datakey object.
+	EncryptionContext map[string]string `json:"encryptionContext"`
+
+	// Optional RAM role ARN to assume when fetching this object, e.g. for cross account access.
+	AssumeRole string `json:"assumeRole"`
+
 	//Optional array to specify what json key value pairs to extract from a secret and mount as individual secrets
 	JMESPath []JMESPathObject `json:"jmesPath"`
 
+	// Optional jq filter evaluated against the JSON secret, whose result is mounted instead of the secret.
+	JQFilter string `json:"jqFilter"`
+
+	// Optional flag to also write the raw secret of an object with jmesPath entries (defaults to true).
+	EmitRawWhenJmes *bool `json:"emitRawWhenJmes"`
+
+	// Optional flag to fail the mount when the secret does not exist (defaults to true).
+	Required *bool `json:"required"`
+
+	// Optional flag to trim leading and trailing white space from string secret values (defaults to false).
+	TrimSpace bool `json:"trimSpace"`
+
+	// Optional flag to extract the standard fields of RDS, RAM and ECS managed KMS secrets into individual files.
+	ExtractManagedFields bool `json:"extractManagedFields"`
+
+	// Optional flag to fail the mount when the fetched value is empty instead of logging a warning (defaults to false).
+	FailOnEmpty bool `json:"failOnEmpty"`
+
+	// Optional regular expression the fetched value must match.
+	ValuePattern string `json:"valuePattern"`
+
+	// Optional handling of jmesPath strings holding bytes that are not valid UTF-8, base64 to write
+	// them base64 encoded or fail to fail the mount (defaults to base64).
+	JmesBinary string `json:"jmesBinary"`
+
+	// Optional hex SHA-256 digest the value must have after trimSpace, e.g. for a pinned certificate.
+	ExpectedSha256 string `json:"expectedSha256"`
+
+	// Optional line breaks of string values, lf, crlf or preserve to keep the bytes as stored (defaults to preserve).
+	NewlineStyle string `json:"newlineStyle"`
+
+	// Optional flag to end string values with a line break when they do not already (defaults to false).
+	EnsureTrailingNewline bool `json:"ensureTrailingNewline"`
+
+	// Optional top level keys the fetched value must hold as a JSON object.
+	RequiredKeys []string `json:"requiredKeys"`
+
+	// Optional file name in which to write all jmesPath extractions as KEY=VALUE lines.
+	EnvFile string `json:"envFile"`
+
+	// Optional flag to reject jmesPath aliases that are not valid env keys instead of sanitizing them.
+	EnvStrictKeys bool `json:"envStrictKeys"`
+
+	// Optional file name in which to write the tags of a kms secret, fetched with DescribeSecret.
+	TagsFile string `json:"tagsFile"`
+
+	// Optional format of the tagsFile, json for a JSON object or env for KEY=VALUE lines (defaults to json).
+	TagsFormat string `json:"tagsFormat"`
+
+	// Optional PKCS#12 keystore to build from a certificate and a private key of the mount.
+	PKCS12 *PKCS12Bundle `json:"pkcs12"`
+
+	// Optional flag to write the non-sensitive metadata of the object to <file name>.info (defaults to false).
+	InfoFile bool `json:"infoFile"`
+
+	// Optional informational labels of the object, e.g. team: payments, listed in its infoFile and the manifestFile.
+	Labels map[string]string `json:"labels"`
+
+	// Optional flag to also write the version before the fetched one to <file name>.prev (defaults to false).
+	IncludePreviousVersion bool `json:"includePreviousVersion"`
+
+	// Optional format of an oos StringList parameter, raw for the comma separated string, split for one
+	// <file name>.<index> file per element or json for a JSON array (defaults to raw).
+	StringListFormat string `json:"stringListFormat"`
+
+	// Optional number of retries of a failed request for this object, 0 to 10 (defaults to the provider setting).
+	MaxRetries *int `json:"maxRetries"`
+
+	// Optional base interval of the exponential backoff between retries, e.g. 500ms (defaults to the provider setting).
+	RetryInterval string `json:"retryInterval"`
+
+	// Optional timeout of the requests fetching this object, e.g. 30s (defaults to the provider setting).
+	FetchTimeout string `json:"fetchTimeout"`
+
+	// Optional age after which the mounted value is fetched again even when its version is current, e.g. 24h.
+	MaxAge string `json:"maxAge"`
+
+	// Optional time the file of the previous version of a {{.Version}} alias is kept after a new version, e.g. 10m.
+	PreviousFileGracePeriod string `json:"previousFileGracePeriod"`
+
+	// Optional octal mode of the files of this object, e.g. 0400 (defaults to the mount fileMode).
+	FileMode string `json:"fileMode"`
+
+	// Compiled ValuePattern (not part of YAML spec).
+	valuePatternRE *regexp.Regexp `json:"-"`
+
+	// Compiled JQFilter (not part of YAML spec).
+	jqCode *gojq.Code `json:"-"`
+
+	// Compiled NameRewrite pattern (not part of YAML spec).
+	nameRewriteRE *regexp.Regexp `json:"-"`
+
+	// Parsed ARN of an objectName given as an ARN, set by validation (not part of YAML spec).
+	arn *utils.ARN `json:"-"`
+
+	// Parsed FileMode (not part of YAML spec).
+	fileMode *os.FileMode `json:"-"`
+
+	// Parsed RetryInterval and FetchTimeout (not part of YAML spec).
+	retryInterval time.Duration `json:"-"`
+	fetchTimeout  time.Duration `json:"-"`
+
+	// Parsed MaxAge and PreviousFileGracePeriod (not part of YAML spec).
+	maxAge                  time.Duration `json:"-"`
+	previousFileGracePeriod time.Duration `json:"-"`
+
 	// Path translation character (not part of YAML spec).
 	translate string `json:"-"`
 
@@ -50,26 +249,157 @@ type JMESPathObject struct {
 
 	//File name in which to store the secret in.
 	ObjectAlias string `json:"objectAlias"`
+
+	//Optional flag to write a JSON object or array result as indented JSON.
+	PrettyJSON bool `json:"prettyJSON"`
+
+	//Optional flag to write each key or element of an object or array result to its own file,
+	//named objectAlias (used as a prefix) followed by the key or index.
+	FanOut bool `json:"fanOut"`
+
+	//Optional name of a JSON file shared with other jmesPath entries, possibly of other objects,
+	//in which the result is written under the objectAlias key instead of its own file.
+	MergeInto string `json:"mergeInto"`
+
+	//Optional extension appended to objectAlias, e.g. yaml for config.yaml, or auto to use the
+	//extension of the last field of path, as in "config.yaml".
+	Extension string `json:"extension"`
+
+	//Optional encoding of the extracted value, base64, hex or none (defaults to none).
+	Encoding string `json:"encoding"`
+
+	//Optional flag to trim leading and trailing white space from the extracted value, before it is encoded.
+	TrimSpace bool `json:"trimSpace"`
+
+	// Compiled Path (not part of YAML spec).
+	compiled *jmespath.JMESPath `json:"-"`
+}
+
+// Name of the file the entry is written to: objectAlias with the extension,
+// unless objectAlias already ends with it.
+func (j *JMESPathObject) fileAlias() string {
+	ext := j.Extension
+	if ext == extensionAuto {
+		ext = pathExtension(j.Path)
+	}
+	ext = strings.TrimPrefix(ext, ".")
+	if len(ext) == 0 || strings.HasSuffix(j.ObjectAlias, "."+ext) {
+		return j.ObjectAlias
+	}
+	return j.ObjectAlias + "." + ext
+}
+
+// Extension of the last field of a path, which can only contain a dot when it
+// is a quoted identifier, e.g. yaml for data."config.yaml".
+func pathExtension(path string) string {
+	if !strings.HasSuffix(path, `"`) {
+		return ""
+	}
+	field := path[:len(path)-1]
+	field = field[strings.LastIndex(field, `"`)+1:]
+	ext := strings.TrimPrefix(filepath.Ext(field), ".")
+	if !extensionRE.MatchString(ext) {
+		return ""
+	}
+	return ext
+}
+
+// Report whether the entry encodes its extracted value.
+func (j *JMESPathObject) encodes() bool {
+	return j.Encoding == jmesEncodingBase64 || j.Encoding == jmesEncodingHex
+}
+
+// Apply the trimSpace and encoding of the entry to an extracted value.
+func (j *JMESPathObject) encode(value []byte) []byte {
+	if j.TrimSpace && utf8.Valid(value) { // Never modify binary content
+		value = bytes.TrimSpace(value)
+	}
+	switch j.Encoding {
+	case jmesEncodingBase64:
+		return []byte(base64.StdEncoding.EncodeToString(value))
+	case jmesEncodingHex:
+		return []byte(hex.EncodeToString(value))
+	default:
+		return value
+	}
+}
+
+// Compile the path once, so searches do not parse it again.
+func (j *JMESPathObject) compile() error {
+	if j.compiled != nil {
+		return nil
+	}
+	compiled, err := jmespath.Compile(j.Path)
+	if err != nil {
+		return fmt.Errorf("Invalid JMES Path: %s.", j.Path)
+	}
+	j.compiled = compiled
+	return nil
+}
+
+// Evaluate the path against parsed JSON data.
+func (j *JMESPathObject) search(data interface{}) (interface{}, error) {
+	if err := j.compile(); err != nil {
+		return nil, err
+	}
+	result, err := j.compiled.Search(data)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid JMES Path: %s.", j.Path)
+	}
+	return result, nil
 }
 
 // Returns the file name where the secrets are to be written.
 func (s *SecretObject) GetFileName() (path string) {
 	fileName := s.ObjectName
+	if stripped, ok := stripNamePrefix(fileName, s.StripPrefix); ok {
+		fileName = stripped
+	}
+	fileName = s.rewriteName(fileName)
 	if len(s.ObjectAlias) != 0 {
 		fileName = s.ObjectAlias
 	}
 
+	if s.stripLeadingSlash() {
+		fileName = strings.TrimLeft(fileName, string(os.PathSeparator))
+	}
+
 	// Translate slashes to underscore if required.
 	if len(s.translate) != 0 {
 		fileName = strings.ReplaceAll(fileName, string(os.PathSeparator), s.translate)
-	} else {
-		fileName = strings.TrimLeft(fileName, string(os.PathSeparator)) // Strip leading slash
 	}
 
 	return fileName
 }
 
-func NewSecretObjectList(mountDir, translate, objectSpec string) (objects []*SecretObject, e error) {
+// Whether leading slashes are removed from the file name. Without pathTranslation
+// they always are, so files never escape the mount directory.
+func (s *SecretObject) stripLeadingSlash() bool {
+	return s.LeadingSlash == leadingSlashStrip || len(s.translate) == 0
+}
+
+// Whether a resolved file name is empty or names a directory, e.g. the mount
+// directory itself for an objectName of "/" without pathTranslation, or a name
+// ending with a slash.
+func isDirectoryName(name string) bool {
+	return len(name) == 0 || strings.HasSuffix(name, string(os.PathSeparator)) || filepath.Base(name) == "."
+}
+
+// Remove prefix from name when it is a leading path of the name, along with
+// the separators that follow it. Names outside of prefix are left unchanged.
+func stripNamePrefix(name, prefix string) (string, bool) {
+	prefix = strings.TrimRight(prefix, string(os.PathSeparator))
+	if len(prefix) == 0 || !strings.HasPrefix(name, prefix) {
+		return name, false
+	}
+	rest := name[len(prefix):]
+	if len(rest) > 0 && !strings.HasPrefix(rest, string(os.PathSeparator)) {
+		return name, false // Only strip whole path segments
+	}
+	return strings.TrimLeft(rest, string(os.PathSeparator)), true
+}
+
+func NewSecretObjectList(mountDir, translate, stripPrefix, objectSpec string, pod PodMetadata) (objects []*SecretObject, e error) {
 
 	// See if we should substitite underscore for slash
 	if len(translate) == 0 {
@@ -81,18 +411,23 @@ func NewSecretObjectList(mountDir, translate, objectSpec string) (objects []*Sec
 	}
 
 	// Unpack the SecretProviderClass mount specification
-	specObjects := make([]*SecretObject, 0)
-	err := yaml.Unmarshal([]byte(objectSpec), &specObjects)
+	specObjects, err := expandSpecObjects(objectSpec)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to load SecretProviderClass: %+v", err)
+		return nil, err
+	}
+	if MaxObjectsPerMount > 0 && len(specObjects) > MaxObjectsPerMount {
+		return nil, fmt.Errorf("The mount has %d objects, more than the limit of %d objects per mount", len(specObjects), MaxObjectsPerMount)
 	}
 
-	// Validate each record and check for duplicates
-	names := make(map[string]bool)
+	// Validate each record and check that no two outputs share a final file
+	// name, whatever kind of output they are
+	names := make(mountFileNames)
+	mergeTargets := make(map[string]bool) // mergeInto files may be shared by entries
 	for _, specObj := range specObjects {
-		specObj.translate = translate
-		specObj.mountDir = mountDir
-		err = specObj.validateSecretObject()
+		if len(specObj.StripPrefix) == 0 {
+			specObj.StripPrefix = stripPrefix // Use the mount level prefix
+		}
+		err = specObj.processSecretObject(mountDir, translate, pod)
 		if err != nil {
 			return nil, err
 		}
@@ -100,17 +435,50 @@ func NewSecretObjectList(mountDir, translate, objectSpec string) (objects []*Sec
 		// Group secrets of the same type together to allow batching requests
 		objects = append(objects, specObj)
 
-		// Check for duplicate names
-		if names[specObj.ObjectName] && names[specObj.ObjectAlias] && ExistsWithSameNameAndType(objects, specObj) {
-			return nil, fmt.Errorf("Name already in use for objectName: %s", specObj.ObjectName)
+		// The file of an object not writing its raw secret is free
+		if specObj.emitsRaw() {
+			field := "objectName"
+			if len(specObj.ObjectAlias) > 0 {
+				field = "objectAlias"
+			}
+			if err = names.claim(specObj.GetFileName(), field, specObj); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(specObj.CiphertextAlias) > 0 {
+			ciphertextObj := specObj.getCiphertextSecretObject()
+			if err = names.claim(ciphertextObj.GetFileName(), "ciphertextAlias", specObj); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(specObj.TagsFile) > 0 {
+			tagsObj := specObj.getTagsFileSecretObject()
+			if err = names.claim(tagsObj.GetFileName(), "tagsFile", specObj); err != nil {
+				return nil, err
+			}
+		}
+
+		if specObj.PKCS12 != nil {
+			bundleObj := specObj.getPKCS12SecretObject()
+			if err = names.claim(bundleObj.GetFileName(), "pkcs12", specObj); err != nil {
+				return nil, err
+			}
+		}
+
+		if specObj.InfoFile {
+			infoObj := specObj.getInfoFileSecretObject()
+			if err = names.claim(infoObj.GetFileName(), "infoFile", specObj); err != nil {
+				return nil, err
+			}
 		}
-		names[specObj.ObjectName] = true
 
-		if len(specObj.ObjectAlias) > 0 {
-			if names[specObj.ObjectAlias] {
-				return nil, fmt.Errorf("Name already in use for objectAlias: %s", specObj.ObjectAlias)
+		if specObj.IncludePreviousVersion {
+			prevObj := specObj.getPreviousSecretObject()
+			if err = names.claim(prevObj.GetFileName(), "includePreviousVersion", specObj); err != nil {
+				return nil, err
 			}
-			names[specObj.ObjectAlias] = true
 		}
 
 		if len(specObj.JMESPath) == 0 { //jmesPath not used. No more checks
@@ -118,19 +486,324 @@ func NewSecretObjectList(mountDir, translate, objectSpec string) (objects []*Sec
 		}
 		klog.Infof("found jmes defined in spc %s", specObj.ObjectName)
 
-		for _, JMESPathObject := range specObj.JMESPath {
-			if names[JMESPathObject.ObjectAlias] {
-				return nil, fmt.Errorf("Name already in use for objectAlias: %s", JMESPathObject.ObjectAlias)
+		for i := range specObj.JMESPath {
+			JMESPathObject := &specObj.JMESPath[i]
+			if JMESPathObject.FanOut { // Names are only known after fetching
+				continue
+			}
+			if len(JMESPathObject.MergeInto) > 0 { // The alias is a key of the merged file
+				mergeObj := specObj.getMergeFileSecretObject(JMESPathObject.MergeInto)
+				mergeFile := mergeObj.GetFileName()
+				if mergeTargets[mergeFile] {
+					continue
+				}
+				if err = names.claim(mergeFile, "mergeInto", specObj); err != nil {
+					return nil, err
+				}
+				mergeTargets[mergeFile] = true
+				continue
+			}
+			jmesObj := specObj.getJmesEntrySecretObject(JMESPathObject)
+			if err = names.claim(jmesObj.GetFileName(), "objectAlias", specObj); err != nil {
+				return nil, err
 			}
-
-			names[JMESPathObject.ObjectAlias] = true
 		}
 
+		if len(specObj.EnvFile) > 0 {
+			envObj := specObj.getEnvFileSecretObject()
+			if err = names.claim(envObj.GetFileName(), "envFile", specObj); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// The files of a pkcs12 bundle must be written by other outputs of the mount
+	for _, specObj := range objects {
+		if specObj.PKCS12 == nil {
+			continue
+		}
+		bundleObj := specObj.getPKCS12SecretObject()
+		for _, file := range specObj.PKCS12.sourceFiles() {
+			if _, ok := names[file[1]]; !ok || file[1] == bundleObj.GetFileName() {
+				return nil, fmt.Errorf("%s %s of the pkcs12 bundle of object %s is not a file of the mount", file[0], file[1], specObj.ObjectName)
+			}
+		}
+	}
+	if MaxFilesPerMount > 0 && len(names) > MaxFilesPerMount {
+		return nil, fmt.Errorf("The mount produces %d files, more than the limit of %d files per mount", len(names), MaxFilesPerMount)
 	}
 
 	return objects, nil
 }
 
+// The final file names of the outputs of a mount, as written under the mount
+// directory after aliases, prefixes and path translation are applied.
+type mountFileNames map[string]string
+
+// Record the file name of an output of the object, failing when another output
+// of the mount already writes it. Names are compared after translation, rewrite
+// rules and leadingSlash are applied, and the error names both outputs.
+func (n mountFileNames) claim(fileName, field string, secObj *SecretObject) error {
+	owner := fmt.Sprintf("%s of object %s", field, secObj.ObjectName)
+	if other, ok := n[fileName]; ok {
+		return fmt.Errorf("Name already in use for %s: %s, also used by %s", owner, fileName, other)
+	}
+	n[fileName] = owner
+	return nil
+}
+
+// Unmarshal the objects of the mount specification. An entry whose objectName
+// is a list of names is expanded into one object per name, sharing all other
+// fields, with each object mounted under its own name or its objectAliases entry.
+func expandSpecObjects(objectSpec string) ([]*SecretObject, error) {
+	if hasMultipleDocuments(objectSpec) {
+		return nil, fmt.Errorf("Failed to load SecretProviderClass: objects must be a single YAML document")
+	}
+	// Anchors, aliases and merge keys are resolved while converting the YAML to
+	// JSON, so shared fields reach decodeSpecObject already expanded.
+	rawObjects := make([]map[string]interface{}, 0)
+	err := yaml.Unmarshal([]byte(objectSpec), &rawObjects)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load SecretProviderClass: %+v", err)
+	}
+
+	specObjects := make([]*SecretObject, 0, len(rawObjects))
+	for _, raw := range rawObjects {
+		names, isList := raw["objectName"].([]interface{})
+		if !isList {
+			if _, hasAliases := raw["objectAliases"]; hasAliases {
+				return nil, fmt.Errorf("objectAliases can only be used when objectName is a list")
+			}
+			specObj, err := decodeSpecObject(raw)
+			if err != nil {
+				return nil, err
+			}
+			specObjects = append(specObjects, specObj)
+			continue
+		}
+
+		if _, hasAlias := raw["objectAlias"]; hasAlias {
+			return nil, fmt.Errorf("objectAlias can not be used when objectName is a list, use objectAliases instead")
+		}
+		if _, hasJmes := raw["jmesPath"]; hasJmes {
+			return nil, fmt.Errorf("jmesPath can not be used when objectName is a list")
+		}
+		if _, hasEnv := raw["envFile"]; hasEnv {
+			return nil, fmt.Errorf("envFile can not be used when objectName is a list")
+		}
+		aliases, _ := raw["objectAliases"].(map[string]interface{})
+		delete(raw, "objectAliases")
+
+		seen := make(map[string]bool)
+		for _, n := range names {
+			name, ok := n.(string)
+			if !ok {
+				return nil, fmt.Errorf("objectName list entries must be strings: %v", n)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("Name already in use for objectName: %s", name)
+			}
+			seen[name] = true
+
+			expanded := make(map[string]interface{}, len(raw)+1)
+			for k, v := range raw {
+				expanded[k] = v
+			}
+			expanded["objectName"] = name
+			if alias, ok := aliases[name]; ok {
+				expanded["objectAlias"] = alias
+			}
+			specObj, err := decodeSpecObject(expanded)
+			if err != nil {
+				return nil, err
+			}
+			specObjects = append(specObjects, specObj)
+		}
+		for name := range aliases {
+			if !seen[name] {
+				return nil, fmt.Errorf("objectAliases entry %s is not in the objectName list", name)
+			}
+		}
+	}
+	return specObjects, nil
+}
+
+// Decode a single unpacked entry of the mount specification.
+func decodeSpecObject(raw map[string]interface{}) (*SecretObject, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load SecretProviderClass: %+v", err)
+	}
+	specObj := &SecretObject{}
+	if err = json.Unmarshal(data, specObj); err != nil {
+		return nil, fmt.Errorf("Failed to load SecretProviderClass: %+v", err)
+	}
+	return specObj, nil
+}
+
+// processSecretObject fills in the mount level settings of an object, resolves
+// any placeholders in its aliases and validates the result.
+func (s *SecretObject) processSecretObject(mountDir, translate string, pod PodMetadata) error {
+	s.translate = translate
+	s.mountDir = mountDir
+	s.ObjectType = strings.ToLower(strings.TrimSpace(s.ObjectType))
+	if err := s.splitARNVersion(); err != nil {
+		return err
+	}
+	if err := s.splitNameVersion(); err != nil {
+		return err
+	}
+
+	alias, err := resolveAlias(s.ObjectAlias, pod)
+	if err != nil {
+		return err
+	}
+	s.ObjectAlias = alias
+	for i := range s.JMESPath {
+		alias, err = resolveAlias(s.JMESPath[i].ObjectAlias, pod)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(alias, versionPlaceholder) {
+			return fmt.Errorf("The %s placeholder is only supported in the objectAlias of an object: %s", versionPlaceholder, alias)
+		}
+		s.JMESPath[i].ObjectAlias = alias
+	}
+
+	return s.validateSecretObject()
+}
+
+// Move the version suffix of a KMS secret ARN, as in
+// acs:kms:<region>:<account>:secret/<name>:<version>, into ObjectVersion, or
+// into ObjectVersionLabel for the ACSCurrent and ACSPrevious stages. A "*"
+// suffix selects the current version. The object then behaves exactly like one
+// using objectName and objectVersion.
+func (s *SecretObject) splitARNVersion() error {
+	if !s.isKMS() || !strings.HasPrefix(s.ObjectName, "acs:") {
+		return nil
+	}
+	objARN, err := utils.ParseARN(s.ObjectName)
+	if err != nil || objARN.Service != "kms" || !strings.HasPrefix(objARN.Resource, "secret/") {
+		return nil // Reported by validateSecretObject
+	}
+	i := strings.LastIndex(objARN.Resource, ":")
+	if i < 0 {
+		return nil
+	}
+	version := objARN.Resource[i+1:]
+	s.ObjectName = strings.TrimSuffix(s.ObjectName, ":"+version)
+
+	if version == "" {
+		return fmt.Errorf("Empty version in ARN: %s", s.ObjectName)
+	}
+	return s.setNameVersion(version, "ARN")
+}
+
+// Move the version of a name@version shorthand, e.g. db-password@ACSPrevious,
+// into ObjectVersionLabel, splitting at the last "@". Only the ACSCurrent,
+// ACSPrevious and LATEST labels and "*" are split off by default, so names
+// such as ops@team are kept as they are; nameVersionShorthand also splits off
+// any other suffix as ObjectVersion. ARNs are never split, so a KMS secret
+// whose name contains "@" can always be fetched with its ARN.
+func (s *SecretObject) splitNameVersion() error {
+	if s.isDataKey() || s.isLocalFile() || strings.HasPrefix(s.ObjectName, "acs:") {
+		return nil
+	}
+	i := strings.LastIndex(s.ObjectName, "@")
+	if i < 0 {
+		return nil
+	}
+	name, version := s.ObjectName[:i], s.ObjectName[i+1:]
+	if !s.NameVersionShorthand && !isNameVersionStage(version) {
+		return nil
+	}
+	if len(name) == 0 || len(version) == 0 {
+		return fmt.Errorf("Invalid name@version shorthand in object name: %s", s.ObjectName)
+	}
+	s.ObjectName = name
+	return s.setNameVersion(version, "name@version shorthand")
+}
+
+// Report whether a name@version suffix is unambiguously a version selector.
+func isNameVersionStage(version string) bool {
+	switch version {
+	case "*", KMS_CURRENT_VERSION_STAGE, KMS_PREVIOUS_VERSION_STAGE, versionLabelLatest:
+		return true
+	}
+	return false
+}
+
+// Apply the version given with the object name, in an ARN or a shorthand,
+// failing when objectVersion or objectVersionLabel asks for another one.
+func (s *SecretObject) setNameVersion(version, source string) error {
+	switch version {
+	case "*":
+	case KMS_CURRENT_VERSION_STAGE, KMS_PREVIOUS_VERSION_STAGE, versionLabelLatest:
+		if len(s.ObjectVersionLabel) > 0 && s.ObjectVersionLabel != version {
+			return fmt.Errorf("version stage %s in %s %s conflicts with objectVersionLabel %s", version, source, s.ObjectName, s.ObjectVersionLabel)
+		}
+		s.ObjectVersionLabel = version
+	default:
+		if len(s.ObjectVersion) > 0 && s.ObjectVersion != version {
+			return fmt.Errorf("version %s in %s %s conflicts with objectVersion %s", version, source, s.ObjectName, s.ObjectVersion)
+		}
+		s.ObjectVersion = version
+	}
+	return nil
+}
+
+// Render the pod metadata placeholders in an alias. Unknown placeholders are an error.
+// The {{.Version}} placeholder is left in place, it is rendered once the
+// version of the secret is known (see withVersion).
+func resolveAlias(alias string, pod PodMetadata) (string, error) {
+	if !strings.Contains(alias, "{{") {
+		return alias, nil
+	}
+	tmpl, err := template.New("objectAlias").Option("missingkey=error").Parse(alias)
+	if err != nil {
+		return "", fmt.Errorf("Invalid placeholder in objectAlias %s: %+v", alias, err)
+	}
+	data := struct {
+		PodMetadata
+		Version string
+	}{pod, versionPlaceholder}
+	var b strings.Builder
+	if err = tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("Failed to resolve placeholder in objectAlias %s: %+v", alias, err)
+	}
+	return b.String(), nil
+}
+
+// Return a copy of the object whose alias has the {{.Version}} placeholder
+// replaced by the given version.
+func (s SecretObject) withVersion(version string) SecretObject {
+	s.ObjectAlias = strings.ReplaceAll(s.ObjectAlias, versionPlaceholder, version)
+	return s
+}
+
+// Whether the alias of the object is rendered with the version of the secret.
+func (s *SecretObject) hasVersionPlaceholder() bool {
+	return strings.Contains(s.ObjectAlias, versionPlaceholder)
+}
+
+// Report whether a spec has a document separator after its first document.
+// Only the first document would be unmarshalled, silently dropping the others.
+func hasMultipleDocuments(objectSpec string) bool {
+	content := false
+	for _, line := range strings.Split(objectSpec, "\n") {
+		trimmed := strings.TrimRight(line, " \t\r")
+		if trimmed == "---" || strings.HasPrefix(trimmed, "--- ") {
+			if content {
+				return true
+			}
+			continue
+		}
+		if len(trimmed) > 0 && !strings.HasPrefix(strings.TrimSpace(trimmed), "#") {
+			content = true
+		}
+	}
+	return false
+}
+
 // check if there exists an object with the same name and type.
 func ExistsWithSameNameAndType(objects []*SecretObject, specObj *SecretObject) bool {
 	for _, obj := range objects {
@@ -153,18 +826,129 @@ func (s *SecretObject) validateSecretObject() error {
 		return fmt.Errorf("Object name must be specified")
 	}
 
+	switch s.ObjectType {
+	case "", ObjectTypeKMS, ObjectTypeOOS:
+		if len(s.EncryptionContext) > 0 {
+			// GetSecretValue and GetSecretParameter decrypt with the context stored with the value.
+			return fmt.Errorf("encryptionContext is only supported for datakey objects, kms secrets and oos parameters are decrypted by the service: %s", s.ObjectName)
+		}
+		if s.ObjectType == ObjectTypeOOS && len(s.ObjectVersion) > 0 {
+			if v, err := strconv.Atoi(s.ObjectVersion); err != nil || v < 1 {
+				return fmt.Errorf("objectVersion of oos parameter %s must be a parameter version number: %s", s.ObjectName, s.ObjectVersion)
+			}
+		}
+	case ObjectTypeDataKey:
+		if err := s.validateDataKey(); err != nil {
+			return err
+		}
+	case ObjectTypeFile:
+		if err := s.validateLocalFile(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Invalid objectType %q for object %s, supported types are %q, %q, %q and %q", s.ObjectType, s.ObjectName, ObjectTypeKMS, ObjectTypeOOS, ObjectTypeDataKey, ObjectTypeFile)
+	}
+
+	if len(s.ObjectVersion) > 0 && len(s.ObjectVersionLabel) > 0 {
+		return fmt.Errorf("objectVersion and objectVersionLabel can not both be specified for object: %s", s.ObjectName)
+	}
+
+	if s.ObjectVersionLabel == versionLabelLatest {
+		s.AlwaysLatest = true
+		s.ObjectVersionLabel = ""
+	}
+	if s.AlwaysLatest && len(s.ObjectVersion) > 0 {
+		return fmt.Errorf("alwaysLatest can not be combined with objectVersion for object: %s", s.ObjectName)
+	}
+
+	if err := s.validateRetryPolicy(); err != nil {
+		return err
+	}
+
+	if err := s.validateLabels(); err != nil {
+		return err
+	}
+
+	if len(s.FileMode) > 0 {
+		mode, err := ParseFileMode(s.FileMode)
+		if err != nil {
+			return fmt.Errorf("fileMode of object %s: %+v", s.ObjectName, err)
+		}
+		s.fileMode = &mode
+	}
+
 	var objARN utils.ARN
 	var err error
+	s.arn = nil
 	hasARN := strings.HasPrefix(s.ObjectName, "acs:")
 	if hasARN {
 		objARN, err = utils.ParseARN(s.ObjectName)
 		if err != nil {
 			return fmt.Errorf("Invalid ARN format in object name: %s", s.ObjectName)
 		}
-		// Make sure the ARN is for a supported service
-		if objARN.Service != "kms" {
+		// Make sure the ARN is for a supported service, and the one of the object type
+		if objARN.Service != "kms" && objARN.Service != "oos" {
 			return fmt.Errorf("Invalid service in ARN: %s", objARN.Service)
 		}
+		wantService := "kms"
+		if s.ObjectType == ObjectTypeOOS {
+			wantService = "oos"
+		}
+		if objARN.Service != wantService {
+			return fmt.Errorf("A %s ARN can not be used for object %s of type %s, expected an ARN of service %s", objARN.Service, s.ObjectName, s.ObjectType, wantService)
+		}
+		if wantService == "oos" && (objARN.ResourceType() != oosParameterResourceType || len(objARN.ResourceName()) == 0) {
+			return fmt.Errorf("Invalid OOS parameter ARN, expected acs:oos:<region>:<account id>:%s/<name>: %s", oosParameterResourceType, s.ObjectName)
+		}
+		if len(s.Region) > 0 && len(objARN.Region) > 0 && s.Region != objARN.Region {
+			return fmt.Errorf("region %s does not match the ARN region %s: %s", s.Region, objARN.Region, s.ObjectName)
+		}
+		s.arn = &objARN
+	}
+
+	if len(s.AssumeRole) > 0 {
+		roleARN, err := utils.ParseARN(s.AssumeRole)
+		if err != nil || roleARN.Service != "ram" || len(roleARN.AccountID) == 0 ||
+			!strings.HasPrefix(roleARN.Resource, "role/") || len(roleARN.Resource) == len("role/") {
+			return fmt.Errorf("Invalid assumeRole ARN for object %s, expected acs:ram::<account id>:role/<role name>: %s", s.ObjectName, s.AssumeRole)
+		}
+	}
+
+	if err := s.compileNameRewrite(); err != nil {
+		return err
+	}
+
+	if stripped, ok := stripNamePrefix(s.ObjectName, s.StripPrefix); ok && len(s.ObjectAlias) == 0 && len(stripped) == 0 {
+		return fmt.Errorf("stripPrefix %s leaves an empty file name for object: %s", s.StripPrefix, s.ObjectName)
+	}
+
+	if len(s.KmsEndpoint) > 0 {
+		if !s.isKMS() && !s.isDataKey() {
+			return fmt.Errorf("kmsEndpoint is only supported for kms and datakey objects: %s", s.ObjectName)
+		}
+		if err := ValidateEndpoint(s.KmsEndpoint); err != nil {
+			return err
+		}
+	}
+	if err := s.validateFallbackEndpoints(); err != nil {
+		return err
+	}
+	if err := s.validateStringListFormat(); err != nil {
+		return err
+	}
+
+	switch s.LeadingSlash {
+	case "", leadingSlashStrip:
+	case leadingSlashTranslate:
+		if len(s.translate) == 0 {
+			return fmt.Errorf("leadingSlash %s requires pathTranslation for object: %s", s.LeadingSlash, s.ObjectName)
+		}
+	default:
+		return fmt.Errorf("Invalid leadingSlash %q for object %s, expected %q or %q", s.LeadingSlash, s.ObjectName, leadingSlashStrip, leadingSlashTranslate)
+	}
+
+	if isDirectoryName(s.GetFileName()) {
+		return fmt.Errorf("File name %q of object %s is empty or a directory, set an objectAlias", s.GetFileName(), s.ObjectName)
 	}
 
 	// Do not allow ../ in a path when translation is turned off
@@ -172,24 +956,218 @@ func (s *SecretObject) validateSecretObject() error {
 		return fmt.Errorf("path can not contain ../: %s", s.ObjectName)
 	}
 
+	if s.FailDuringRotation && !s.isKMS() {
+		return fmt.Errorf("failDuringRotation is only supported for kms secrets: %s", s.ObjectName)
+	}
+
+	if s.ExtractManagedFields && !s.isKMS() {
+		return fmt.Errorf("extractManagedFields is only supported for kms secrets: %s", s.ObjectName)
+	}
+
+	if len(s.ValuePattern) > 0 {
+		s.valuePatternRE, err = regexp.Compile(s.ValuePattern)
+		if err != nil {
+			return fmt.Errorf("Invalid valuePattern for object %s: %+v", s.ObjectName, err)
+		}
+	}
+
+	switch s.JmesBinary {
+	case "", jmesBinaryBase64, jmesBinaryFail:
+	default:
+		return fmt.Errorf("Invalid jmesBinary %q for object %s, expected %q or %q", s.JmesBinary, s.ObjectName, jmesBinaryBase64, jmesBinaryFail)
+	}
+
+	if len(s.ExpectedSha256) > 0 && !sha256RE.MatchString(s.ExpectedSha256) {
+		return fmt.Errorf("Invalid expectedSha256 for object %s, expected 64 hex characters", s.ObjectName)
+	}
+
+	if err := s.validateRequiredKeys(); err != nil {
+		return err
+	}
+
+	if err := s.validateNewlineStyle(); err != nil {
+		return err
+	}
+
+	if err := s.validateTagsFile(); err != nil {
+		return err
+	}
+
+	if err := s.validateMaxAge(); err != nil {
+		return err
+	}
+
+	if err := s.validatePreviousFileGracePeriod(); err != nil {
+		return err
+	}
+
+	if err := s.validatePKCS12(); err != nil {
+		return err
+	}
+
+	if err := s.validateIndirection(); err != nil {
+		return err
+	}
+
+	if err := s.validateJQFilter(); err != nil {
+		return err
+	}
+
+	if s.EmitRawWhenJmes != nil && len(s.JMESPath) == 0 {
+		return fmt.Errorf("emitRawWhenJmes requires jmesPath entries: %s", s.ObjectName)
+	}
+
+	if len(s.EnvFile) > 0 {
+		if len(s.JMESPath) == 0 {
+			return fmt.Errorf("envFile requires jmesPath entries: %s", s.ObjectName)
+		}
+		envObj := s.getEnvFileSecretObject()
+		if isDirectoryName(envObj.GetFileName()) {
+			return fmt.Errorf("File name of envFile %q is empty or a directory", s.EnvFile)
+		}
+		if badPathRE.MatchString(envObj.GetFileName()) {
+			return fmt.Errorf("path can not contain ../: %s", s.EnvFile)
+		}
+	}
+
 	if len(s.JMESPath) == 0 { //jmesPath not specified no more checks
 		return nil
 	}
+	if DisableJMESPath {
+		return fmt.Errorf("jmesPath is disabled by the provider policy, only whole secrets can be mounted: %s", s.ObjectName)
+	}
 
 	//ensure each jmesPath entry has a path and an objectalias
-	for _, jmesPathEntry := range s.JMESPath {
+	for i, jmesPathEntry := range s.JMESPath {
 		if len(jmesPathEntry.Path) == 0 {
 			return fmt.Errorf("Path must be specified for JMES object")
 		}
+		if err := s.JMESPath[i].compile(); err != nil {
+			return err
+		}
+
+		if ext := jmesPathEntry.Extension; len(ext) > 0 {
+			if len(jmesPathEntry.MergeInto) > 0 {
+				return fmt.Errorf("extension can not be used with mergeInto: %s", jmesPathEntry.Path)
+			}
+			if ext != extensionAuto && !extensionRE.MatchString(strings.TrimPrefix(ext, ".")) {
+				return fmt.Errorf("Invalid extension %q for JMES path: %s", ext, jmesPathEntry.Path)
+			}
+		}
+
+		switch jmesPathEntry.Encoding {
+		case "", jmesEncodingNone, jmesEncodingBase64, jmesEncodingHex:
+		default:
+			return fmt.Errorf("Invalid encoding %q for JMES path %s, expected %q, %q or %q", jmesPathEntry.Encoding, jmesPathEntry.Path, jmesEncodingBase64, jmesEncodingHex, jmesEncodingNone)
+		}
+
+		if len(jmesPathEntry.MergeInto) > 0 {
+			if jmesPathEntry.encodes() || jmesPathEntry.TrimSpace {
+				return fmt.Errorf("encoding and trimSpace can not be used with mergeInto: %s", jmesPathEntry.Path)
+			}
+			if jmesPathEntry.FanOut || len(s.EnvFile) > 0 {
+				return fmt.Errorf("mergeInto can not be used with fanOut or envFile: %s", s.ObjectName)
+			}
+			mergeObj := SecretObject{ObjectAlias: jmesPathEntry.MergeInto, LeadingSlash: s.LeadingSlash, translate: s.translate}
+			if isDirectoryName(mergeObj.GetFileName()) {
+				return fmt.Errorf("File name of mergeInto %q is empty or a directory", jmesPathEntry.MergeInto)
+			}
+			if badPathRE.MatchString(mergeObj.GetFileName()) {
+				return fmt.Errorf("path can not contain ../: %s", jmesPathEntry.MergeInto)
+			}
+		}
+
+		if jmesPathEntry.FanOut {
+			if len(s.EnvFile) > 0 {
+				return fmt.Errorf("fanOut can not be used with envFile: %s", s.ObjectName)
+			}
+			continue // objectAlias is an optional prefix for fanOut
+		}
 
 		if len(jmesPathEntry.ObjectAlias) == 0 {
 			return fmt.Errorf("Object alias must be specified for JMES object")
 		}
+
+		if len(jmesPathEntry.MergeInto) == 0 {
+			entryObj := s.getJmesEntrySecretObject(&jmesPathEntry)
+			if isDirectoryName(entryObj.GetFileName()) {
+				return fmt.Errorf("File name of JMES object alias %q is empty or a directory", jmesPathEntry.ObjectAlias)
+			}
+			if badPathRE.MatchString(entryObj.GetFileName()) {
+				return fmt.Errorf("path can not contain ../: %s", entryObj.ObjectAlias)
+			}
+		}
+	}
+
+	if len(s.EnvFile) > 0 {
+		if _, err := s.envKeys(); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// isKMS reports whether the object is a KMS secret.
+func (s *SecretObject) isKMS() bool {
+	return s.ObjectType == ObjectTypeKMS || len(s.ObjectType) == 0
+}
+
+// emitsRaw reports whether the raw secret is written to the file of the
+// object, which emitRawWhenJmes false turns off for objects with jmesPath, as
+// does the split stringListFormat.
+func (s *SecretObject) emitsRaw() bool {
+	if s.StringListFormat == stringListSplit {
+		return false
+	}
+	return len(s.JMESPath) == 0 || s.EmitRawWhenJmes == nil || *s.EmitRawWhenJmes
+}
+
+// isRequired reports whether a missing secret should fail the mount.
+func (s *SecretObject) isRequired() bool {
+	return s.Required == nil || *s.Required
+}
+
+// getRegion returns the region the object lives in, or an empty string for the mount region.
+func (s *SecretObject) getRegion() string {
+	if len(s.Region) > 0 {
+		return s.Region
+	}
+	if objARN, ok := s.GetARN(); ok {
+		return objARN.Region
+	}
+	return ""
+}
+
+// GetARN returns the parsed objectName when it is an ARN, without a version
+// suffix, and false otherwise.
+func (s *SecretObject) GetARN() (utils.ARN, bool) {
+	if s.arn != nil {
+		return *s.arn, true
+	}
+	if !strings.HasPrefix(s.ObjectName, "acs:") { // Not validated, e.g. a derived object
+		return utils.ARN{}, false
+	}
+	objARN, err := utils.ParseARN(s.ObjectName)
+	return objARN, err == nil
+}
+
+// Return the name of an OOS parameter, taken from its ARN when the objectName
+// is an ARN, as the OOS APIs only accept names.
+func (s *SecretObject) parameterName() string {
+	if objARN, ok := s.GetARN(); ok && objARN.Service == "oos" {
+		return objARN.ResourceName()
+	}
+	return s.ObjectName
+}
+
+// GetAccountID returns the account id of the ARN of the object, or an empty
+// string when objectName is not an ARN or the ARN has no account.
+func (s *SecretObject) GetAccountID() string {
+	objARN, _ := s.GetARN()
+	return objARN.AccountID
+}
+
 // GetMountDir return the mount point directory
 func (s *SecretObject) GetMountDir() string {
 	return s.mountDir
@@ -202,7 +1180,42 @@ func (s *SecretObject) GetMountPath() string {
 
 func (p *SecretObject) getJmesEntrySecretObject(j *JMESPathObject) (d SecretObject) {
 	return SecretObject{
-		ObjectAlias: j.ObjectAlias,
+		ObjectAlias:  j.fileAlias(),
+		LeadingSlash: p.LeadingSlash,
+		translate:    p.translate,
+		mountDir:     p.mountDir,
+	}
+}
+
+func (p *SecretObject) getMergeFileSecretObject(mergeInto string) (d SecretObject) {
+	return SecretObject{
+		ObjectAlias:  mergeInto,
+		LeadingSlash: p.LeadingSlash,
+		translate:    p.translate,
+		mountDir:     p.mountDir,
+	}
+}
+
+func (p *SecretObject) getEnvFileSecretObject() (d SecretObject) {
+	return SecretObject{
+		ObjectAlias:  p.EnvFile,
+		LeadingSlash: p.LeadingSlash,
+		translate:    p.translate,
+		mountDir:     p.mountDir,
+	}
+}
+
+func (p *SecretObject) getInfoFileSecretObject() (d SecretObject) {
+	return SecretObject{
+		ObjectAlias: p.GetFileName() + infoFileSuffix,
+		translate:   p.translate,
+		mountDir:    p.mountDir,
+	}
+}
+
+func (p *SecretObject) getManagedFieldSecretObject(field string) (d SecretObject) {
+	return SecretObject{
+		ObjectAlias: p.GetFileName() + "-" + field,
 		translate:   p.translate,
 		mountDir:    p.mountDir,
 	}
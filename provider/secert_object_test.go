@@ -1,6 +1,14 @@
 package provider
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"testing"
+
+	oos "github.com/alibabacloud-go/oos-20190601/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
 
 func TestSecretObject_validateSecretObject(t *testing.T) {
 	type fields struct {
@@ -9,6 +17,7 @@ func TestSecretObject_validateSecretObject(t *testing.T) {
 		ObjectVersion      string
 		ObjectVersionLabel string
 		JMESPath           []JMESPathObject
+		AssumeRole         string
 		translate          string
 		mountDir           string
 	}
@@ -26,6 +35,23 @@ func TestSecretObject_validateSecretObject(t *testing.T) {
 	f4 := fields{
 		ObjectName: "test/..",
 	}
+	f5 := fields{
+		ObjectName:         "MySecret",
+		ObjectVersion:      "v1",
+		ObjectVersionLabel: "ACSCurrent",
+	}
+	f6 := fields{
+		ObjectName: "MySecret",
+		AssumeRole: "acs:ram::12345678:role/reader",
+	}
+	f7 := fields{
+		ObjectName: "MySecret",
+		AssumeRole: "acs:ram::12345678:user/reader",
+	}
+	f8 := fields{
+		ObjectName: "MySecret",
+		AssumeRole: "reader",
+	}
 	tests := []struct {
 		name    string
 		fields  fields
@@ -36,6 +62,10 @@ func TestSecretObject_validateSecretObject(t *testing.T) {
 		{"validate-secret-obj-2", f2, true},
 		{"validate-secret-obj-3", f3, true},
 		{"validate-secret-obj-4", f4, true},
+		{"validate-secret-obj-5", f5, true},
+		{"validate-secret-obj-6", f6, false},
+		{"validate-secret-obj-7", f7, true},
+		{"validate-secret-obj-8", f8, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -45,6 +75,7 @@ func TestSecretObject_validateSecretObject(t *testing.T) {
 				ObjectVersion:      tt.fields.ObjectVersion,
 				ObjectVersionLabel: tt.fields.ObjectVersionLabel,
 				JMESPath:           tt.fields.JMESPath,
+				AssumeRole:         tt.fields.AssumeRole,
 				translate:          tt.fields.translate,
 				mountDir:           tt.fields.mountDir,
 			}
@@ -54,3 +85,625 @@ func TestSecretObject_validateSecretObject(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSecretObjectListAliasPlaceholders(t *testing.T) {
+	pod := PodMetadata{Namespace: "default", PodName: "nginx", ServiceAccount: "sa"}
+	badPod := PodMetadata{Namespace: ".."}
+	tests := []struct {
+		name    string
+		spec    string
+		pod     PodMetadata
+		want    []string
+		wantErr bool
+	}{
+		{
+			"alias-placeholders",
+			`[{"objectName": "MySecret", "objectAlias": "{{.Namespace}}-{{.PodName}}", "jmesPath": [{"path": "username", "objectAlias": "{{.ServiceAccount}}-user"}]}]`,
+			pod,
+			[]string{"default-nginx", "sa-user"},
+			false,
+		},
+		{"unknown-placeholder", `[{"objectName": "MySecret", "objectAlias": "{{.Cluster}}"}]`, pod, nil, true},
+		{"malformed-placeholder", `[{"objectName": "MySecret", "objectAlias": "{{.Namespace"}]`, pod, nil, true},
+		{"resolved-bad-path", `[{"objectName": "MySecret", "objectAlias": "a/{{.Namespace}}"}]`, badPod, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "False", "", tt.spec, tt.pod)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := []string{objects[0].GetFileName(), objects[0].JMESPath[0].ObjectAlias}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("NewSecretObjectList() got = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListNameList(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      string
+		wantFiles []string
+		wantErr   bool
+	}{
+		{
+			"expand-list",
+			`
+- objectName: ["app/db-user", "app/db-password", "app/token"]
+  objectType: "oos"
+  objectAliases:
+    app/token: "token"
+- objectName: "MySecret"
+`,
+			[]string{"app_db-user", "app_db-password", "token", "MySecret"},
+			false,
+		},
+		{"duplicate-in-list", `[{"objectName": ["a", "a"]}]`, nil, true},
+		{"alias-with-list", `[{"objectName": ["a", "b"], "objectAlias": "c"}]`, nil, true},
+		{"jmes-with-list", `[{"objectName": ["a", "b"], "jmesPath": [{"path": "x", "objectAlias": "x"}]}]`, nil, true},
+		{"unknown-alias-key", `[{"objectName": ["a", "b"], "objectAliases": {"c": "d"}}]`, nil, true},
+		{"aliases-without-list", `[{"objectName": "a", "objectAliases": {"a": "d"}}]`, nil, true},
+		{"non-string-name", `[{"objectName": ["a", 1]}]`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(objects) != len(tt.wantFiles) {
+				t.Fatalf("NewSecretObjectList() returned %d objects, want %d", len(objects), len(tt.wantFiles))
+			}
+			for i, obj := range objects {
+				if obj.GetFileName() != tt.wantFiles[i] {
+					t.Errorf("object %d file name = %s, want %s", i, obj.GetFileName(), tt.wantFiles[i])
+				}
+			}
+			if !tt.wantErr && objects[0].ObjectType != "oos" {
+				t.Errorf("expanded object did not keep the shared objectType")
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListYAMLAnchors(t *testing.T) {
+	spec := `
+- &oos
+  objectName: "app/db-user"
+  objectType: "oos"
+  region: &region "cn-shanghai"
+  trimSpace: true
+- <<: *oos
+  objectName: "app/db-password"
+  objectAlias: "password"
+- <<: *oos
+  objectName: "app/token"
+  objectType: "kms"
+- objectName: "other"
+  region: *region
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	want := []SecretObject{
+		{ObjectName: "app/db-user", ObjectType: "oos", Region: "cn-shanghai", TrimSpace: true},
+		{ObjectName: "app/db-password", ObjectAlias: "password", ObjectType: "oos", Region: "cn-shanghai", TrimSpace: true},
+		{ObjectName: "app/token", ObjectType: "kms", Region: "cn-shanghai", TrimSpace: true},
+		{ObjectName: "other", Region: "cn-shanghai"},
+	}
+	if len(objects) != len(want) {
+		t.Fatalf("NewSecretObjectList() returned %d objects, want %d", len(objects), len(want))
+	}
+	for i, obj := range objects {
+		if obj.ObjectName != want[i].ObjectName || obj.ObjectAlias != want[i].ObjectAlias || obj.ObjectType != want[i].ObjectType ||
+			obj.Region != want[i].Region || obj.TrimSpace != want[i].TrimSpace {
+			t.Errorf("object %d = %+v, want %+v", i, *obj, want[i])
+		}
+	}
+
+	// Expanded objects are validated like any other, duplicates included.
+	if _, err = NewSecretObjectList("/mnt", "", "", "- &a {objectName: a, objectAlias: b}\n- *a\n", PodMetadata{}); err == nil {
+		t.Errorf("expected an error for an aliased duplicate object")
+	}
+	if _, err = NewSecretObjectList("/mnt", "", "", "- <<: *missing\n  objectName: a\n", PodMetadata{}); err == nil {
+		t.Errorf("expected an error for an unknown anchor")
+	}
+
+	// Documents after the first one would be ignored.
+	if _, err = NewSecretObjectList("/mnt", "", "", "---\n- objectName: a\n", PodMetadata{}); err != nil {
+		t.Errorf("NewSecretObjectList() error = %v for a single document", err)
+	}
+	if _, err = NewSecretObjectList("/mnt", "", "", "- objectName: a\n---\n- objectName: b\n", PodMetadata{}); err == nil {
+		t.Errorf("expected an error for a multi-document spec")
+	}
+}
+
+func TestNewSecretObjectListLeadingSlash(t *testing.T) {
+	tests := []struct {
+		name      string
+		translate string
+		spec      string
+		wantFile  string
+		wantErr   bool
+	}{
+		{"translate-on", "", `[{"objectName": "/app/db"}]`, "_app_db", false},
+		{"translate-on-strip", "", `[{"objectName": "/app/db", "leadingSlash": "strip"}]`, "app_db", false},
+		{"translate-on-alias-strip", "", `[{"objectName": "db", "objectAlias": "/app/db", "leadingSlash": "strip"}]`, "app_db", false},
+		{"translate-off-with-slash", "False", `[{"objectName": "/app/db"}]`, "app/db", false},
+		{"translate-off-strip", "False", `[{"objectName": "//app/db", "leadingSlash": "strip"}]`, "app/db", false},
+		{"translate-off-translate", "False", `[{"objectName": "/app/db", "leadingSlash": "translate"}]`, "", true},
+		{"custom-translate-char", "-", `[{"objectName": "/app/db"}]`, "-app-db", false},
+		{"custom-translate-char-strip", "-", `[{"objectName": "/app/db", "leadingSlash": "strip"}]`, "app-db", false},
+		{"invalid-value", "", `[{"objectName": "/app/db", "leadingSlash": "keep"}]`, "", true},
+		{"strip-traversal", "False", `[{"objectName": "/../etc/passwd", "leadingSlash": "strip"}]`, "", true},
+		{"translate-off-traversal", "False", `[{"objectName": "/app/../../etc/passwd"}]`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", tt.translate, "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && objects[0].GetFileName() != tt.wantFile {
+				t.Errorf("GetFileName() = %s, want %s", objects[0].GetFileName(), tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListDirectoryNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		translate string
+		spec      string
+		wantErr   bool
+	}{
+		{"slash", "False", `[{"objectName": "/"}]`, true},
+		{"slashes", "False", `[{"objectName": "///"}]`, true},
+		{"slash-translated", "", `[{"objectName": "/"}]`, false},
+		{"slash-translated-strip", "", `[{"objectName": "///", "leadingSlash": "strip"}]`, true},
+		{"slash-with-alias", "False", `[{"objectName": "/", "objectAlias": "root"}]`, false},
+		{"trailing-slash", "False", `[{"objectName": "app/db/"}]`, true},
+		{"dot-component", "False", `[{"objectName": "app/."}]`, true},
+		{"alias-slash", "False", `[{"objectName": "db", "objectAlias": "/"}]`, true},
+		{"strip-to-slash", "False", `[{"objectName": "app//", "stripPrefix": "app"}]`, true},
+		{"jmes-alias-slash", "False", `[{"objectName": "db", "jmesPath": [{"path": "x", "objectAlias": "dir/"}]}]`, true},
+		{"env-file-slash", "False", `[{"objectName": "db", "envFile": "/", "jmesPath": [{"path": "x", "objectAlias": "x"}]}]`, true},
+		{"merge-into-slash", "False", `[{"objectName": "db", "jmesPath": [{"path": "x", "objectAlias": "x", "mergeInto": "conf/"}]}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", tt.translate, "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListFileNameCollisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		translate string
+		spec      string
+		wantErr   bool
+	}{
+		{"translated-name-and-alias", "", `[{"objectName": "app/db"}, {"objectName": "other", "objectAlias": "app_db"}]`, true},
+		{"jmes-alias-and-translated-name", "", `[{"objectName": "app/db"}, {"objectName": "other", "jmesPath": [{"path": "x", "objectAlias": "app_db"}]}]`, true},
+		{"jmes-alias-and-object-name", "", `[{"objectName": "db", "jmesPath": [{"path": "x", "objectAlias": "user"}]}, {"objectName": "user", "objectType": "oos"}]`, true},
+		{"jmes-alias-across-objects", "", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x"}]}, {"objectName": "b", "jmesPath": [{"path": "y", "objectAlias": "x"}]}]`, true},
+		{"stripped-name-and-alias", "", `[{"objectName": "app/db", "stripPrefix": "app/"}, {"objectName": "other", "objectAlias": "db"}]`, true},
+		{"same-name-other-type", "", `[{"objectName": "db"}, {"objectName": "db", "objectType": "oos"}]`, true},
+		{"info-file-and-jmes-alias", "", `[{"objectName": "db", "infoFile": true}, {"objectName": "other", "jmesPath": [{"path": "x", "objectAlias": "db.info"}]}]`, true},
+		{"env-file-and-object", "", `[{"objectName": "db", "envFile": "app.env", "jmesPath": [{"path": "x", "objectAlias": "x"}]}, {"objectName": "app.env"}]`, true},
+		{"merge-file-and-translated-name", "", `[{"objectName": "conf/app"}, {"objectName": "db", "jmesPath": [{"path": "x", "objectAlias": "x", "mergeInto": "conf_app"}]}]`, true},
+		{"rewritten-names", "", `[{"objectName": "prod/db", "nameRewrite": {"pattern": "^prod/", "replacement": ""}}, {"objectName": "staging/db", "nameRewrite": {"pattern": "^staging/", "replacement": ""}}]`, true},
+		{"lowercased-name-and-alias", "", `[{"objectName": "App/DB", "nameRewrite": {"lowercase": true}}, {"objectName": "other", "objectAlias": "app_db"}]`, true},
+		{"shared-merge-file", "", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "mergeInto": "m"}]}, {"objectName": "b", "jmesPath": [{"path": "y", "objectAlias": "y", "mergeInto": "m"}]}]`, false},
+		{"untranslated-names-differ", "False", `[{"objectName": "app/db"}, {"objectName": "other", "objectAlias": "app_db"}]`, false},
+		{"raw-not-emitted", "", `[{"objectName": "db", "emitRawWhenJmes": false, "jmesPath": [{"path": "x", "objectAlias": "x"}]}, {"objectName": "other", "objectAlias": "db"}]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", tt.translate, "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListFileNameCollisionError(t *testing.T) {
+	spec := `[{"objectName": "a/b"}, {"objectName": "other", "objectAlias": "a_b"}]`
+	_, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	want := "Name already in use for objectAlias of object other: a_b, also used by objectName of object a/b"
+	if err == nil || err.Error() != want {
+		t.Errorf("NewSecretObjectList() error = %v, want %s", err, want)
+	}
+}
+
+func TestNewSecretObjectListMountLimits(t *testing.T) {
+	oldObjects, oldFiles := MaxObjectsPerMount, MaxFilesPerMount
+	t.Cleanup(func() { MaxObjectsPerMount, MaxFilesPerMount = oldObjects, oldFiles })
+	MaxObjectsPerMount, MaxFilesPerMount = 2, 4
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{"within-limits", `[{"objectName": "a"}, {"objectName": "b", "infoFile": true}]`, ""},
+		{"too-many-objects", `[{"objectName": "a"}, {"objectName": "b"}, {"objectName": "c"}]`, "3 objects, more than the limit of 2"},
+		{"expanded-list", `[{"objectName": ["a", "b", "c"]}]`, "3 objects, more than the limit of 2"},
+		{"at-file-limit", `[{"objectName": "a", "infoFile": true, "jmesPath": [{"path": "x", "objectAlias": "x"}, {"path": "y", "objectAlias": "y"}]}]`, ""},
+		{"files-over-limit", `[{"objectName": "a", "infoFile": true, "jmesPath": [{"path": "x", "objectAlias": "x"}, {"path": "y", "objectAlias": "y"}, {"path": "z", "objectAlias": "z"}]}]`, "5 files, more than the limit of 4"},
+		{"shared-merge-file", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "mergeInto": "m"}, {"path": "y", "objectAlias": "y", "mergeInto": "m"}, {"path": "z", "objectAlias": "z", "mergeInto": "m"}]}]`, ""},
+		{"fan-out-not-counted", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x-", "fanOut": true}]}]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("NewSecretObjectList() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewSecretObjectList() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListObjectType(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		wantType string
+		wantErr  bool
+	}{
+		{"kms-encryption-context", `[{"objectName": "a", "encryptionContext": {"k": "v"}}]`, "", true},
+		{"default", `[{"objectName": "a"}]`, "", false},
+		{"upper-case-kms", `[{"objectName": "a", "objectType": "KMS"}]`, ObjectTypeKMS, false},
+		{"mixed-case-oos", `[{"objectName": "a", "objectType": " Oos "}]`, ObjectTypeOOS, false},
+		{"unsupported", `[{"objectName": "a", "objectType": "vault"}]`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && objects[0].ObjectType != tt.wantType {
+				t.Errorf("objectType = %q, want %q", objects[0].ObjectType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListStripPrefix(t *testing.T) {
+	tests := []struct {
+		name        string
+		stripPrefix string
+		spec        string
+		wantFiles   []string
+		wantErr     bool
+	}{
+		{
+			"mount-prefix",
+			"/app/prod",
+			`[{"objectName": "/app/prod/db/password", "objectType": "oos"}, {"objectName": "/app/production/token"}, {"objectName": "/app/prod/x", "objectAlias": "y"}]`,
+			[]string{"db_password", "_app_production_token", "y"},
+			false,
+		},
+		{
+			"object-prefix-overrides-mount",
+			"/app/prod/",
+			`[{"objectName": "/app/prod/db/password", "stripPrefix": "/app/prod/db"}]`,
+			[]string{"password"},
+			false,
+		},
+		{"empty-name", "", `[{"objectName": "/app/prod", "stripPrefix": "/app/prod"}]`, nil, true},
+		{"escape-mount-dir", "", `[{"objectName": "/app/../x", "stripPrefix": "/app"}]`, []string{".._x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", tt.stripPrefix, tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(objects) != len(tt.wantFiles) {
+				t.Fatalf("NewSecretObjectList() returned %d objects, want %d", len(objects), len(tt.wantFiles))
+			}
+			for i, obj := range objects {
+				if obj.GetFileName() != tt.wantFiles[i] {
+					t.Errorf("object %d file name = %s, want %s", i, obj.GetFileName(), tt.wantFiles[i])
+				}
+			}
+		})
+	}
+
+	if _, err := NewSecretObjectList("/mnt", "False", "/app", `[{"objectName": "/app/../x"}]`, PodMetadata{}); err == nil {
+		t.Errorf("expected an error for a stripped name escaping the mount dir")
+	}
+}
+
+func TestNewSecretObjectListARNVersion(t *testing.T) {
+	const arn = "acs:kms:cn-hangzhou:12345678:secret/MySecret"
+	tests := []struct {
+		name        string
+		spec        string
+		wantVersion string
+		wantLabel   string
+		wantErr     bool
+	}{
+		{"without-version", `[{"objectName": "` + arn + `"}]`, "", "", false},
+		{"version-id", `[{"objectName": "` + arn + `:v1"}]`, "v1", "", false},
+		{"same-as-objectVersion", `[{"objectName": "` + arn + `:v1", "objectVersion": "v1"}]`, "v1", "", false},
+		{"stage", `[{"objectName": "` + arn + `:ACSPrevious"}]`, "", "ACSPrevious", false},
+		{"wildcard", `[{"objectName": "` + arn + `:*"}]`, "", "", false},
+		{"conflicting-version", `[{"objectName": "` + arn + `:v1", "objectVersion": "v2"}]`, "", "", true},
+		{"version-and-label", `[{"objectName": "` + arn + `:v1", "objectVersionLabel": "ACSCurrent"}]`, "", "", true},
+		{"empty-version", `[{"objectName": "` + arn + `:"}]`, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			obj := objects[0]
+			if obj.ObjectName != arn || obj.ObjectVersion != tt.wantVersion || obj.ObjectVersionLabel != tt.wantLabel {
+				t.Errorf("object = %s version %q label %q, want %s version %q label %q",
+					obj.ObjectName, obj.ObjectVersion, obj.ObjectVersionLabel, arn, tt.wantVersion, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListNameVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		wantName    string
+		wantFile    string
+		wantVersion string
+		wantLabel   string
+		latest      bool
+		wantErr     bool
+	}{
+		{"version", `[{"objectName": "db-password@v3", "nameVersionShorthand": true}]`, "db-password", "db-password", "v3", "", false, false},
+		{"alias", `[{"objectName": "db-password@v3", "objectAlias": "pw", "nameVersionShorthand": true}]`, "db-password", "pw", "v3", "", false, false},
+		{"stage", `[{"objectName": "db@ACSPrevious"}]`, "db", "db", "", "ACSPrevious", false, false},
+		{"latest", `[{"objectName": "db@LATEST"}]`, "db", "db", "", "", true, false},
+		{"current", `[{"objectName": "db@*"}]`, "db", "db", "", "", false, false},
+		{"name-with-at", `[{"objectName": "ops@team"}]`, "ops@team", "ops@team", "", "", false, false},
+		{"name-with-at-and-version", `[{"objectName": "ops@team", "objectVersion": "v2"}]`, "ops@team", "ops@team", "v2", "", false, false},
+		{"name-with-at-and-stage", `[{"objectName": "ops@team@ACSCurrent"}]`, "ops@team", "ops@team", "", "ACSCurrent", false, false},
+		{"last-at", `[{"objectName": "team@corp@v1", "nameVersionShorthand": true}]`, "team@corp", "team@corp", "v1", "", false, false},
+		{"oos", `[{"objectName": "param@2", "objectType": "oos", "nameVersionShorthand": true}]`, "param", "param", "2", "", false, false},
+		{"same-as-objectVersion", `[{"objectName": "db@v3", "objectVersion": "v3", "nameVersionShorthand": true}]`, "db", "db", "v3", "", false, false},
+		{"arn-not-split", `[{"objectName": "acs:kms:cn-hangzhou:123:secret/user@corp"}]`, "acs:kms:cn-hangzhou:123:secret/user@corp", "", "", "", false, false},
+		{"arn-version", `[{"objectName": "acs:kms:cn-hangzhou:123:secret/user@corp:v2"}]`, "acs:kms:cn-hangzhou:123:secret/user@corp", "", "v2", "", false, false},
+		{"conflicting-version", `[{"objectName": "db@v3", "objectVersion": "v4", "nameVersionShorthand": true}]`, "", "", "", "", false, true},
+		{"conflicting-label", `[{"objectName": "db@ACSCurrent", "objectVersionLabel": "ACSPrevious"}]`, "", "", "", "", false, true},
+		{"version-and-label", `[{"objectName": "db@v3", "objectVersionLabel": "ACSCurrent", "nameVersionShorthand": true}]`, "", "", "", "", false, true},
+		{"empty-version", `[{"objectName": "db@", "nameVersionShorthand": true}]`, "", "", "", "", false, true},
+		{"empty-name", `[{"objectName": "@v3", "nameVersionShorthand": true}]`, "", "", "", "", false, true},
+		{"empty-name-stage", `[{"objectName": "@ACSCurrent"}]`, "", "", "", "", false, true},
+		{"oos-bad-version", `[{"objectName": "param@v2", "objectType": "oos", "nameVersionShorthand": true}]`, "", "", "", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			obj := objects[0]
+			if obj.ObjectName != tt.wantName || obj.ObjectVersion != tt.wantVersion || obj.ObjectVersionLabel != tt.wantLabel || obj.AlwaysLatest != tt.latest {
+				t.Errorf("object = %s version %q label %q latest %t, want %s version %q label %q latest %t",
+					obj.ObjectName, obj.ObjectVersion, obj.ObjectVersionLabel, obj.AlwaysLatest, tt.wantName, tt.wantVersion, tt.wantLabel, tt.latest)
+			}
+			if len(tt.wantFile) > 0 && obj.GetFileName() != tt.wantFile {
+				t.Errorf("file name = %s, want %s", obj.GetFileName(), tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestSecretObjectGetARN(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		wantARN     bool
+		wantAccount string
+		wantRegion  string
+		wantName    string
+		wantErr     bool
+	}{
+		{"name", `[{"objectName": "MySecret"}]`, false, "", "", "", false},
+		{"arn", `[{"objectName": "acs:kms:cn-beijing:12345678:secret/MySecret"}]`, true, "12345678", "cn-beijing", "MySecret", false},
+		{"arn-with-version", `[{"objectName": "acs:kms:cn-beijing:12345678:secret/MySecret:v1"}]`, true, "12345678", "cn-beijing", "MySecret", false},
+		{"arn-without-region", `[{"objectName": "acs:kms::12345678:secret/MySecret", "region": "cn-shanghai"}]`, true, "12345678", "cn-shanghai", "MySecret", false},
+		{"too-few-sections", `[{"objectName": "acs:kms:cn-beijing:secret/MySecret"}]`, false, "", "", "", true},
+		{"other-service", `[{"objectName": "acs:ram::12345678:role/MyRole"}]`, false, "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			obj := objects[0]
+			objARN, ok := obj.GetARN()
+			if ok != tt.wantARN {
+				t.Fatalf("GetARN() ok = %v, want %v", ok, tt.wantARN)
+			}
+			if obj.GetAccountID() != tt.wantAccount || obj.getRegion() != tt.wantRegion || objARN.ResourceName() != tt.wantName {
+				t.Errorf("account %q region %q name %q, want %q %q %q",
+					obj.GetAccountID(), obj.getRegion(), objARN.ResourceName(), tt.wantAccount, tt.wantRegion, tt.wantName)
+			}
+			if ok && objARN.ResourceType() != "secret" {
+				t.Errorf("ResourceType() = %q, want secret", objARN.ResourceType())
+			}
+		})
+	}
+}
+
+func TestOOSParameterARN(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       string
+		wantName   string
+		wantRegion string
+		wantErr    bool
+	}{
+		{"parameter", `[{"objectName": "acs:oos:cn-beijing:123:secretparameter/db", "objectType": "oos"}]`, "db", "cn-beijing", false},
+		{"hierarchical", `[{"objectName": "acs:oos:cn-beijing:123:secretparameter/app/db", "objectType": "oos", "objectAlias": "db"}]`, "app/db", "cn-beijing", false},
+		{"kms-arn-on-oos", `[{"objectName": "acs:kms:cn-beijing:123:secret/db", "objectType": "oos"}]`, "", "", true},
+		{"oos-arn-on-kms", `[{"objectName": "acs:oos:cn-beijing:123:secretparameter/db"}]`, "", "", true},
+		{"oos-arn-on-datakey", `[{"objectName": "acs:oos:cn-beijing:123:secretparameter/db", "objectType": "datakey", "objectAlias": "k", "ciphertextAlias": "c"}]`, "", "", true},
+		{"other-resource", `[{"objectName": "acs:oos:cn-beijing:123:template/db", "objectType": "oos"}]`, "", "", true},
+		{"empty-name", `[{"objectName": "acs:oos:cn-beijing:123:secretparameter/", "objectType": "oos", "objectAlias": "db"}]`, "", "", true},
+		{"region-mismatch", `[{"objectName": "acs:oos:cn-beijing:123:secretparameter/db", "objectType": "oos", "region": "cn-hangzhou"}]`, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (objects[0].parameterName() != tt.wantName || objects[0].getRegion() != tt.wantRegion) {
+				t.Errorf("parameter %q in %q, want %q in %q", objects[0].parameterName(), objects[0].getRegion(), tt.wantName, tt.wantRegion)
+			}
+		})
+	}
+}
+
+func TestOOSParameterARNFetch(t *testing.T) {
+	setupFetchTest(t)
+	var name, region string
+	client := newVersionedOosClient("value")
+	getSecretParameter := client.getSecretParameter
+	client.getSecretParameter = func(request *oos.GetSecretParameterRequest) (*oos.GetSecretParameterResponse, error) {
+		name = tea.StringValue(request.Name)
+		return getSecretParameter(request)
+	}
+	p := &SecretsManagerProvider{Region: "cn-hangzhou", NewOosClient: func(r string) (OosAPI, error) {
+		region = r
+		return client, nil
+	}}
+	objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "acs:oos:cn-beijing:123:secretparameter/db", "objectType": "oos", "objectAlias": "db"}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	values, err := p.GetSecretValues(context.Background(), objects, map[string]*v1alpha1.ObjectVersion{})
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if name != "db" || region != "cn-beijing" || string(values[0].Value) != "value" {
+		t.Errorf("fetched parameter %q in %q, want db in cn-beijing", name, region)
+	}
+}
+
+func TestJMESPathFileAlias(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry JMESPathObject
+		want  string
+	}{
+		{"no-extension", JMESPathObject{Path: "config", ObjectAlias: "config"}, "config"},
+		{"explicit", JMESPathObject{Path: "config", ObjectAlias: "config", Extension: "yaml"}, "config.yaml"},
+		{"leading-dot", JMESPathObject{Path: "config", ObjectAlias: "config", Extension: ".yaml"}, "config.yaml"},
+		{"alias-has-extension", JMESPathObject{Path: "config", ObjectAlias: "config.yaml", Extension: "yaml"}, "config.yaml"},
+		{"auto-quoted", JMESPathObject{Path: `files."app.config.yaml"`, ObjectAlias: "app", Extension: "auto"}, "app.yaml"},
+		{"auto-unquoted", JMESPathObject{Path: "files.yaml", ObjectAlias: "app", Extension: "auto"}, "app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.fileAlias(); got != tt.want {
+				t.Errorf("fileAlias() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListDottedNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		translate string
+		spec      string
+		wantErr   bool
+	}{
+		{"extension", "", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "extension": "yaml"}]}]`, false},
+		{"duplicate-with-extension", "", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "extension": "yaml"}, {"path": "y", "objectAlias": "x.yaml"}]}]`, true},
+		{"invalid-extension", "", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "extension": "/../y"}]}]`, true},
+		{"extension-with-mergeInto", "", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x", "extension": "yaml", "mergeInto": "m"}]}]`, true},
+		{"dots-in-name", "False", `[{"objectName": "dir/a..b", "jmesPath": [{"path": "x", "objectAlias": "dir/ab"}]}]`, false},
+		{"parent-alias", "", `[{"objectName": "a", "objectAlias": ".."}]`, true},
+		{"parent-jmes-alias", "False", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "../x"}]}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSecretObjectList("/mnt", tt.translate, "", tt.spec, PodMetadata{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func FuzzNewSecretObjectList(f *testing.F) {
+	for _, seed := range []string{
+		`[{"objectName": "s", "objectAlias": "a/b", "jmesPath": [{"path": "x[0].y", "objectAlias": "y"}]}]`,
+		"- objectName: \"acs:kms:cn-hangzhou:123:secret/db:v1\"\n  assumeRole: \"acs:ram::123:role/r\"\n",
+		"- objectName: db@ACSCurrent\n  fileMode: \"0400\"\n  labels: {team: payments}\n",
+		"- objectName: *a\n- &a [[[[[[[[]]]]]]]]",
+		"- objectName: k\n  objectType: datakey\n  objectAlias: k\n  ciphertextAlias: k.enc\n",
+		`[{"objectName": "é​", "objectAlias": "{{.PodName}}-{{.Version}}", "jmesPath": [{"path": "a | [?b == '\xff']", "objectAlias": "..", "mergeInto": "m"}]}]`,
+	} {
+		f.Add(seed, "")
+		f.Add(seed, "False")
+	}
+	pod := PodMetadata{Namespace: "ns", PodName: "pod", ServiceAccount: "sa"}
+	f.Fuzz(func(t *testing.T, spec, translate string) {
+		objects, err := NewSecretObjectList("/mnt", translate, "", spec, pod)
+		if err != nil {
+			return
+		}
+		p := &SecretsManagerProvider{}
+		p.DescribeSpec(objects)
+		CurrentVersionKeys(objects)
+		if _, err = SpecHash(objects); err != nil {
+			t.Errorf("SpecHash() of a valid spec failed: %v", err)
+		}
+	})
+}
+
+func TestNewSecretObjectListDisableJMESPath(t *testing.T) {
+	oldDisable := DisableJMESPath
+	t.Cleanup(func() { DisableJMESPath = oldDisable })
+	DisableJMESPath = true
+	if _, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "a"}]`, PodMetadata{}); err != nil {
+		t.Errorf("NewSecretObjectList() error = %v", err)
+	}
+	_, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "a", "jmesPath": [{"path": "x", "objectAlias": "x"}]}]`, PodMetadata{})
+	if err == nil || !strings.Contains(err.Error(), "policy") {
+		t.Errorf("expected a policy error for jmesPath entries, got %v", err)
+	}
+}
@@ -2,16 +2,19 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"math"
+	"os"
+	"strconv"
 	"time"
 
-	"github.com/AliyunContainerService/ack-secret-manager/pkg/utils"
+	secretUtils "github.com/AliyunContainerService/ack-secret-manager/pkg/utils"
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/metrics"
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/utils"
 	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
 	oos "github.com/alibabacloud-go/oos-20190601/v4/client"
 	"github.com/alibabacloud-go/tea/tea"
-	sdkErr "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
 )
@@ -25,23 +28,171 @@ const (
 var (
 	BACKOFF_DEFAULT_RETRY_INTERVAL = time.Second
 	BACKOFF_DEFAULT_CAPACITY       = time.Duration(10) * time.Second
+	BACKOFF_DEFAULT_MAX_RETRIES    = 1
+	FETCH_DEFAULT_TIMEOUT          = 5 * time.Minute
 )
 
 const (
 	ObjectTypeKMS = "kms"
 	ObjectTypeOOS = "oos"
+	// A data key generated under the KMS CMK named by objectName.
+	ObjectTypeDataKey = "datakey"
+	// A local file under LocalFileSourceDir, for development and tests.
+	ObjectTypeFile = "file"
 )
 
+// Resource type of OOS secret parameters in their ARNs.
+const oosParameterResourceType = "secretparameter"
+
+const (
+	KMS_CURRENT_VERSION_STAGE  = "ACSCurrent"
+	KMS_PREVIOUS_VERSION_STAGE = "ACSPrevious"
+	KMS_PENDING_VERSION_STAGE  = "ACSPending"
+	versionPageSize            = int32(100)
+
+	// objectVersionLabel forcing a fetch on every sync, same as alwaysLatest.
+	versionLabelLatest = "LATEST"
+)
+
+// RetryableErrorCodes lists additional service error codes that are retried
+// on top of the built-in throttling and availability codes.
+var RetryableErrorCodes []string
+
+// CheckCurrentVersion enables a lightweight version lookup for unpinned KMS
+// objects that are already mounted, skipping the value fetch when the upstream
+// version is unchanged. It costs one ListSecretVersionIds call per object.
+var CheckCurrentVersion = false
+
+// MaxFilesPerObject caps the files a single object may produce, including its
+// fanOut entries, so an unexpectedly large array fails the mount instead of
+// writing thousands of files (0 disables the limit).
+var MaxFilesPerObject = 1000
+
+// MaxObjectsPerMount and MaxFilesPerMount cap the objects of a mount, after
+// objectName lists are expanded, and the files they are known to produce
+// before fetching, so an accidentally huge SecretProviderClass is rejected
+// before any request is made (0 disables a limit). FanOut entries and split
+// StringList elements are only known after fetching and are not counted.
+var (
+	MaxObjectsPerMount = 500
+	MaxFilesPerMount   = 5000
+)
+
+// DisableJMESPath rejects objects declaring jmesPath entries, for platforms
+// that only allow whole secrets to be mounted.
+var DisableJMESPath = false
+
 type Limiter struct {
 	Kms KmsLimiter
 	OOS OosLimiter
+
+	// Caps SDK calls in flight across all mounts, composed with the rate limiters.
+	InFlight *ConcurrencyLimiter
 }
 
 var LimiterInstance Limiter
 
+// KmsAPI is the subset of the KMS client used by the provider.
+type KmsAPI interface {
+	GetSecretValue(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error)
+	ListSecretVersionIds(request *kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error)
+	GenerateDataKey(request *kms.GenerateDataKeyRequest) (*kms.GenerateDataKeyResponse, error)
+	RotateSecret(request *kms.RotateSecretRequest) (*kms.RotateSecretResponse, error)
+	DescribeSecret(request *kms.DescribeSecretRequest) (*kms.DescribeSecretResponse, error)
+}
+
+// OosAPI is the subset of the OOS client used by the provider.
+type OosAPI interface {
+	GetSecretParameter(request *oos.GetSecretParameterRequest) (*oos.GetSecretParameterResponse, error)
+	ListSecretParameterVersions(request *oos.ListSecretParameterVersionsRequest) (*oos.ListSecretParameterVersionsResponse, error)
+}
+
+// SecretsManagerProvider fetches the secrets of a mount. KmsClient and OosClient
+// serve objects in Region with no assumeRole (nor kmsEndpoint for KMS), the
+// factories build the clients of every other object, so a client injected here
+// never sees an endpoint override.
 type SecretsManagerProvider struct {
-	KmsClient *kms.Client
-	OosClient *oos.Client
+	KmsClient KmsAPI
+	OosClient OosAPI
+
+	// Region of KmsClient and OosClient.
+	Region string
+
+	// Optional factories used to build clients for objects in other regions.
+	// The KMS endpoint is empty to use the default endpoint of the region.
+	NewKmsClient func(region, endpoint string) (KmsAPI, error)
+	NewOosClient func(region string) (OosAPI, error)
+
+	// Optional factories used to build clients acting as the assumeRole of an object.
+	NewRoleKmsClient func(region, roleArn, endpoint string) (KmsAPI, error)
+	NewRoleOosClient func(region, roleArn string) (OosAPI, error)
+
+	// Optional KMS endpoints by region, used for clients built by the factories
+	// unless the object sets its own kmsEndpoint.
+	RegionEndpointMap map[string]string
+
+	// Optional KMS endpoints tried in order when the endpoint of a kms secret
+	// in Region can not be reached, unless the secret sets its own
+	// kmsEndpoint or kmsFallbackEndpoints.
+	KmsFallbackEndpoints []string
+
+	// Optional predicate marking additional errors as retryable, consulted
+	// after the built-in and RetryableErrorCodes codes.
+	RetryPredicate func(error) bool
+
+	// Optional retry and timeout settings of every object, overridden by the
+	// maxRetries, retryInterval and fetchTimeout of an object. Unset values
+	// use BACKOFF_DEFAULT_MAX_RETRIES, BACKOFF_DEFAULT_RETRY_INTERVAL and
+	// FETCH_DEFAULT_TIMEOUT.
+	MaxRetries    *int
+	RetryInterval time.Duration
+	FetchTimeout  time.Duration
+
+	// Optional file system used to read mounted files (defaults to the OS).
+	FS FileSystem
+
+	// Allow RotateSecret to be called (defaults to false).
+	EnableRotation bool
+
+	// Optional name of a single file holding every file of the mount as a
+	// base64 encoded data map, instead of one file per object.
+	DataMapFile string
+
+	// Optional name of a file listing the name, type, version and digest of
+	// every mounted file, see ValidateManifestFile.
+	ManifestFile string
+
+	// Optional identity of the credentials of the mount, such as their access
+	// key id, scoping the values it shares through the cache of
+	// SharedCacheSize. An empty scope bypasses the shared cache.
+	CacheScope string
+
+	// Optional mode of the files of objects without a fileMode, and bits
+	// cleared from the mode of every file, see SecretFiles.
+	DefaultFileMode *os.FileMode
+	FileUmask       os.FileMode
+
+	clients clientRegistry
+
+	// Contents of the mounted DataMapFile, loaded on the first reload.
+	mountedDataMap map[string]string
+
+	// Digests of the mounted manifest file by file name, see VerifyMountedFiles.
+	mountedManifest map[string]string
+
+	// Optional processors run on every fetched value before it is written,
+	// in order, see SecretProcessor.
+	Processors []SecretProcessor
+
+	// Records of the objects handled by the last call, see FetchStats.
+	fetchStats []*ObjectFetchStat
+
+	// Look up the current version of unpinned KMS secrets, for GetChangedSecretValues.
+	checkVersions bool
+
+	// Version stages of the KMS secrets shared by several objects of the sync,
+	// see preresolveVersions.
+	resolvedStages map[string]map[string]string
 }
 
 type SecretFile struct {
@@ -53,41 +204,244 @@ type SecretFile struct {
 }
 
 // Get the secret from KMS secrets manager.
+//
+// Values are returned in a stable order: objects in spec order, each followed
+// by its retained previous files (sorted by version), jmesPath entries (in
+// spec order, fanOut keys sorted), split StringList elements, env file,
+// managed fields, data key ciphertext, tags file, info file and previous
+// version, then the mergeInto files in the order they are first used and the
+// pkcs12 bundles in spec order. With DataMapFile they are all returned in that
+// single file instead. The manifest file, if any, comes last.
 func (p *SecretsManagerProvider) GetSecretValues(
+	ctx context.Context,
 	secretObjs []*SecretObject,
 	curMap map[string]*v1alpha1.ObjectVersion,
 ) (v []*SecretValue, e error) {
+	v, _, e = p.getSecretValues(ctx, secretObjs, curMap)
+	return v, e
+}
+
+// GetChangedSecretValues is GetSecretValues for the resync of an existing
+// mount: it returns only the values whose version differs from curMap, along
+// with the ids (curMap keys) of the updated objects. The upstream version of
+// unpinned KMS secrets is looked up first, as with CheckCurrentVersion, so
+// unchanged secrets are not fetched again. Callers must leave the files they
+// do not get untouched; the secrets store CSI driver replaces the whole mount
+// with the files of a response, so the server always uses GetSecretValues.
+func (p *SecretsManagerProvider) GetChangedSecretValues(
+	ctx context.Context,
+	secretObjs []*SecretObject,
+	curMap map[string]*v1alpha1.ObjectVersion,
+) (changed []*SecretValue, updated []string, e error) {
+	p.checkVersions = true
+	defer func() { p.checkVersions = false }()
+	values, updated, err := p.getSecretValues(ctx, secretObjs, curMap)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, value := range values {
+		if value.changed {
+			changed = append(changed, value)
+		}
+	}
+	return changed, updated, nil
+}
+
+// Fetch the values of a mount once, see getSecretValues for the batch retries.
+func (p *SecretsManagerProvider) getSecretValuesOnce(
+	ctx context.Context,
+	secretObjs []*SecretObject,
+	curMap map[string]*v1alpha1.ObjectVersion,
+) (v []*SecretValue, updated []string, e error) {
+
+	if PrevalidateSecrets {
+		if err := p.prevalidateSecrets(ctx, secretObjs, curMap); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// Fetch each secret
+	p.mountedDataMap = nil // Reload from the data map file as it is mounted now
+	p.mountedManifest = nil
+	p.preresolveVersions(ctx, secretObjs, curMap)
+	p.fetchStats = nil
 	var values []*SecretValue
+	var merged mergedFiles
+	fileNames := make(map[string]string) // file name -> object name
+	var stat *ObjectFetchStat            // Record of the object being handled
+	var refreshed []*SecretObject        // Objects fetched with a refreshToken
+	defer func() {
+		if e != nil && stat != nil {
+			stat.finish(e)
+			e = p.fetchError(stat.ObjectName, stat.ObjectType, e)
+		}
+	}()
 	for _, secObj := range secretObjs {
+		var objCtx context.Context
+		objCtx, stat = p.startFetchStat(ctx, secObj)
+		prior := curMap[secObj.GetFileName()]
 
 		// Don't re-fetch if we already have the current version.
-		isCurrent, version, err := p.isCurrent(secObj, curMap)
+		isCurrent, version, err := p.isCurrent(objCtx, secObj, curMap)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// If version is current, read it back in, otherwise pull it down
 		var secret *SecretValue
 		if isCurrent {
-			secret, err = p.reloadSecret(secObj)
-			if err != nil {
-				return nil, err
+			stat.Source = FetchSourceMounted
+			versionedObj := secObj.withVersion(version)
+			secret, err = p.reloadSecret(&versionedObj)
+			if err == nil {
+				secret.SecretType, _ = secObj.recordedSecretType(curMap)
+			}
+			if errors.Is(err, errMountedMismatch) {
+				klog.Warningf("fetching %s again: %v", secObj.ObjectName, err)
+				isCurrent, err = false, nil
+			} else if err != nil {
+				return nil, nil, err
+			}
+		}
+		if !isCurrent { // Fetch the latest version, or the mounted one again when its file is corrupted.
+			if secObj.FailDuringRotation {
+				err = p.checkRotation(objCtx, secObj)
+			}
+			if err == nil && secObj.verifiesVersionStage() {
+				err = p.verifyVersionStage(objCtx, secObj)
+			}
+			if err == nil {
+				version, secret, err = p.fetchSecret(objCtx, secObj)
+			}
+			if err == nil && secObj.FollowIndirection {
+				version, secret, err = p.followIndirection(objCtx, secObj, secret)
 			}
-
-		} else { // Fetch the latest version.
-			version, secret, err = p.fetchSecret(secObj)
 			if err != nil {
-				return nil, err
+				if !secObj.isRequired() && isNotFound(err) {
+					klog.Infof("skipping optional object %s which does not exist", secObj.ObjectName)
+					stat.Source = FetchSourceSkipped
+					stat.finish(nil)
+					continue
+				}
+				stale, staleVersion := p.staleSecret(secObj, curMap, err)
+				if stale == nil {
+					return nil, nil, err
+				}
+				secret, version, isCurrent = stale, staleVersion, true
+				stat.Source = FetchSourceStale
+			} else {
+				stat.Source = FetchSourceFetched
+				refreshed = append(refreshed, secObj)
+				secret.SecretObj = secret.SecretObj.withVersion(version)
+				secret.transform()
+				if err = secret.validateValue(); err != nil {
+					return nil, nil, err
+				}
+				if err = p.process(objCtx, secObj, secret); err != nil {
+					return nil, nil, err
+				}
+				if err = secret.formatStringList(); err != nil {
+					return nil, nil, err
+				}
+				if err = secret.checkRequiredKeys(); err != nil {
+					return nil, nil, err
+				}
+				if err = secret.applyJQFilter(objCtx); err != nil {
+					return nil, nil, err
+				}
 			}
 
 		}
-		values = append(values, secret) // Build up the slice of values
+		// A {{.Version}} alias is only known now, check it like any other name.
+		fileName := secret.SecretObj.GetFileName()
+		if secObj.hasVersionPlaceholder() && badPathRE.MatchString(fileName) {
+			return nil, nil, fmt.Errorf("File name %s of object %s rendered from version %s is not valid", fileName, secObj.ObjectName, version)
+		}
+		emitsRaw := secObj.emitsRaw()
+		if emitsRaw {
+			if other, ok := fileNames[fileName]; ok {
+				return nil, nil, fmt.Errorf("File name %s of object %s is already used by object %s", fileName, secObj.ObjectName, other)
+			}
+			fileNames[fileName] = secObj.ObjectName
+		}
+		changed := prior == nil || prior.Version != version
+		if changed {
+			updated = append(updated, secObj.GetFileName())
+		}
+		secret.changed = changed
+		secret.setSource(secObj, version)
+		if emitsRaw {
+			values = append(values, secret) // Build up the slice of values
+			values = append(values, p.retainedPreviousFiles(secObj, prior, version, curMap, fileNames, time.Now())...)
+		}
 		//support individual json key value pairs based on jmesPath
 		jsonSecrets, err := secret.getJsonSecrets()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		listSecrets, err := secret.stringListSecrets()
+		if err != nil {
+			return nil, nil, err
+		}
+		jsonSecrets = append(jsonSecrets, listSecrets...)
+		if len(secObj.EnvFile) > 0 {
+			envSecret, err := secret.getEnvFileSecret(jsonSecrets)
+			if err != nil {
+				return nil, nil, err
+			}
+			jsonSecrets = append(jsonSecrets, envSecret)
+		}
+		managedSecrets, err := secret.getManagedSecrets()
+		if err != nil {
+			return nil, nil, err
+		}
+		jsonSecrets = append(jsonSecrets, managedSecrets...)
+		if secObj.isDataKey() {
+			ciphertextSecret, err := p.ciphertextSecretFor(secret, isCurrent)
+			if err != nil {
+				return nil, nil, err
+			}
+			jsonSecrets = append(jsonSecrets, ciphertextSecret)
+		}
+		if len(secObj.TagsFile) > 0 {
+			tagsSecret, err := p.tagsSecretFor(objCtx, secret, isCurrent)
+			if err != nil {
+				return nil, nil, err
+			}
+			jsonSecrets = append(jsonSecrets, tagsSecret)
+		}
+		if secObj.InfoFile {
+			jsonSecrets = append(jsonSecrets, p.infoSecretFor(secret, version, isCurrent))
+		}
+		if secObj.IncludePreviousVersion {
+			prevSecret, err := p.previousSecretFor(objCtx, secret, version, isCurrent)
+			if err != nil {
+				return nil, nil, err
+			}
+			if prevSecret != nil {
+				jsonSecrets = append(jsonSecrets, prevSecret)
+			}
+		}
+		files := len(jsonSecrets)
+		if emitsRaw {
+			files++
+		}
+		if MaxFilesPerObject > 0 && files > MaxFilesPerObject {
+			return nil, nil, fmt.Errorf("Object %s produces %d files, more than the limit of %d files per object", secObj.ObjectName, files, MaxFilesPerObject)
+		}
+		// Names of fanOut entries, split StringList elements and managed fields
+		// are only known now, none may overwrite a file of another output.
+		for _, jsonSecret := range jsonSecrets {
+			jsonFile := jsonSecret.SecretObj.GetFileName()
+			if other, ok := fileNames[jsonFile]; ok {
+				return nil, nil, fmt.Errorf("File name %s of a jmesPath or derived file of object %s is already used by object %s", jsonFile, secObj.ObjectName, other)
+			}
+			fileNames[jsonFile] = secObj.ObjectName
+		}
+		for _, jsonSecret := range jsonSecrets {
+			jsonSecret.changed = changed
+			jsonSecret.setSource(secObj, version)
+			jsonSecret.SecretObj.fileMode = secObj.fileMode // Derived files share the mode of the object
 		}
 		if len(jsonSecrets) > 0 {
 			values = append(values, jsonSecrets...)
@@ -101,17 +455,61 @@ func (p *SecretsManagerProvider) GetSecretValues(
 			}
 		}
 
-		// Update the version in the current version map.
-		curMap[secObj.GetFileName()] = &v1alpha1.ObjectVersion{
-			Id:      secObj.GetFileName(),
-			Version: version,
+		if err = merged.add(secret, version); err != nil {
+			return nil, nil, err
 		}
+
+		// Update the version in the current version map. The key is the name
+		// before the version is rendered, so the next mount can find it.
+		if emitsRaw {
+			curMap[secObj.GetFileName()] = &v1alpha1.ObjectVersion{
+				Id:      secObj.GetFileName(),
+				Version: version,
+			}
+			if stat.Source == FetchSourceFetched {
+				secObj.recordFetchedAt(curMap, time.Now())
+			}
+			secObj.recordSecretType(curMap, secret.SecretType)
+		}
+		stat.finish(nil)
 	}
+	stat = nil // Later errors are not caused by a single object
 
-	return values, nil
+	mergedSecrets, err := merged.values(curMap)
+	if err != nil {
+		return nil, nil, err
+	}
+	values = append(values, mergedSecrets...)
+	bundles, err := p.pkcs12Values(secretObjs, values, curMap)
+	if err != nil {
+		return nil, nil, err
+	}
+	values = append(values, bundles...)
+	var manifest *SecretValue
+	if len(p.ManifestFile) > 0 && len(values) > 0 {
+		if manifest, err = p.manifestValue(values); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(p.DataMapFile) > 0 && len(values) > 0 {
+		dataMap, err := p.dataMapValue(values)
+		if err != nil {
+			return nil, nil, err
+		}
+		values = []*SecretValue{dataMap}
+	}
+	if manifest != nil {
+		values = append(values, manifest)
+	}
+	// Only a mount that succeeded honors the tokens, a failed one tries again.
+	for _, secObj := range refreshed {
+		secObj.honorRefreshToken()
+	}
+	return values, updated, nil
 }
 
 func (p *SecretsManagerProvider) isCurrent(
+	ctx context.Context,
 	secObj *SecretObject,
 	curMap map[string]*v1alpha1.ObjectVersion,
 ) (cur bool, ver string, e error) {
@@ -122,45 +520,207 @@ func (p *SecretsManagerProvider) isCurrent(
 		return false, "", nil
 	}
 
+	// Without its raw file there is nothing mounted to reload.
+	if !secObj.emitsRaw() {
+		return false, "", nil
+	}
+
+	// A refreshToken not honored yet fetches the object once, whatever is mounted.
+	if secObj.refreshPending() {
+		return false, "", nil
+	}
+
+	// The version of a pointer says nothing about the secret it points to.
+	if secObj.FollowIndirection {
+		return false, "", nil
+	}
+
+	// A value older than its maxAge is fetched again, whatever its version.
+	if secObj.expired(curMap, time.Now()) {
+		return false, "", nil
+	}
+
+	// Managed fields are extracted by the secret type KMS reported, which is
+	// not known for a value mounted without it.
+	if secObj.missingSecretType(curMap) {
+		return false, "", nil
+	}
+
+	// A data key is different on every call, keep the mounted one.
+	if secObj.isDataKey() {
+		return true, curVer.Version, nil
+	}
+
+	// Objects asking for the latest version are always fetched.
+	if secObj.AlwaysLatest {
+		return false, "", nil
+	}
+
 	// If the secret is pinned to a version see if that is what we have.
 	if len(secObj.ObjectVersion) > 0 {
 		return curVer.Version == secObj.ObjectVersion, curVer.Version, nil
 	}
-	return
+
+	// Otherwise optionally ask KMS which version the label currently points to.
+	if !(CheckCurrentVersion || p.checkVersions) || !secObj.isKMS() {
+		return false, "", nil
+	}
+	upstream, err := p.describeCurrentVersion(ctx, secObj)
+	if err != nil {
+		klog.Warningf("failed to check the current version of %s, fetching it instead: %s", secObj.ObjectName, err.Error())
+		return false, "", nil
+	}
+	return upstream == curVer.Version, curVer.Version, nil
+}
+
+// Look up the version id the object's version stage (ACSCurrent by default)
+// points to, without fetching the secret value.
+func (p *SecretsManagerProvider) describeCurrentVersion(ctx context.Context, secObj *SecretObject) (string, error) {
+	stage := secObj.ObjectVersionLabel
+	if len(stage) == 0 {
+		stage = KMS_CURRENT_VERSION_STAGE
+	}
+	versionId, found, err := p.findVersionStage(ctx, secObj, stage)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("version stage %s not found for secret %s", stage, secObj.ObjectName)
+	}
+	return versionId, nil
+}
+
+// List the versions of a KMS secret to find the one in a version stage. The
+// stages resolved for the secret by preresolveVersions are used when present.
+func (p *SecretsManagerProvider) findVersionStage(ctx context.Context, secObj *SecretObject, stage string) (versionId string, found bool, e error) {
+	if stages, ok := p.resolvedStages[versionStagesKey(secObj)]; ok {
+		versionId, found = stages[stage]
+		return versionId, found, nil
+	}
+	err := p.scanVersionStages(ctx, secObj, func(id, s string) bool {
+		if s == stage {
+			versionId, found = id, true
+		}
+		return !found
+	})
+	return versionId, found, err
+}
+
+// Call visit with the version id and stage of each version stage of a KMS
+// secret, page by page, until visit returns false or all pages are listed.
+func (p *SecretsManagerProvider) scanVersionStages(ctx context.Context, secObj *SecretObject, visit func(versionId, stage string) bool) error {
+	fetchTimeoutCtx, cancel := p.fetchContext(ctx, secObj)
+	defer cancel()
+	if err := waitForToken(fetchTimeoutCtx, LimiterInstance.Kms); err != nil {
+		return err
+	}
+	client, err := p.kmsClientFor(secObj)
+	if err != nil {
+		return err
+	}
+
+	for page := int32(1); ; page++ {
+		var response *kms.ListSecretVersionIdsResponse
+		err = p.withRetry(fetchTimeoutCtx, ObjectTypeKMS, secObj, func() (err error) {
+			response, err = client.ListSecretVersionIds(&kms.ListSecretVersionIdsRequest{
+				SecretName: tea.String(secObj.ObjectName),
+				PageNumber: tea.Int32(page),
+				PageSize:   tea.Int32(versionPageSize),
+			})
+			return err
+		})
+		if err != nil {
+			return p.accessDeniedError(err, "kms:ListSecretVersionIds", secObj)
+		}
+		if response.Body == nil || response.Body.VersionIds == nil || len(response.Body.VersionIds.VersionId) == 0 {
+			return nil
+		}
+		for _, v := range response.Body.VersionIds.VersionId {
+			if v.VersionStages == nil {
+				continue
+			}
+			for _, vs := range v.VersionStages.VersionStage {
+				if !visit(tea.StringValue(v.VersionId), tea.StringValue(vs)) {
+					return nil
+				}
+			}
+		}
+		if page*versionPageSize >= tea.Int32Value(response.Body.TotalCount) {
+			return nil
+		}
+	}
 }
 
 // Private helper to fetch a given secret.
 //
 // This method builds up the GetSecretValue request using the objectName from
 // the request and any objectVersion or objectVersionLabel parameters.
-func (smp *SecretsManagerProvider) fetchSecret(secObj *SecretObject) (ver string, val *SecretValue, e error) {
-	waitTimeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+func (smp *SecretsManagerProvider) fetchSecret(ctx context.Context, secObj *SecretObject) (ver string, val *SecretValue, e error) {
+	load := func() (string, *SecretValue, error) {
+		return smp.fetchFromBackend(ctx, secObj)
+	}
+	if key, ok := smp.coalesceKey(secObj); ok {
+		fetch := load
+		load = func() (string, *SecretValue, error) {
+			return coalesceFetch(ctx, key, secObj, fetch)
+		}
+	}
+	if key, ok := smp.sharedCacheKey(secObj); ok {
+		backend := ObjectTypeKMS
+		if secObj.ObjectType == ObjectTypeOOS {
+			backend = ObjectTypeOOS
+		}
+		return sharedValues.fetch(ctx, key, backend, secObj, load)
+	}
+	return load()
+}
+
+// Fetch the secret from its backend, bypassing the shared cache.
+func (smp *SecretsManagerProvider) fetchFromBackend(ctx context.Context, secObj *SecretObject) (ver string, val *SecretValue, e error) {
+	if fallbacks := smp.kmsFallbackEndpointsFor(secObj); len(fallbacks) > 0 {
+		return smp.fetchKMSSecretWithFallback(ctx, secObj, fallbacks)
+	}
+	fetchTimeoutCtx, cancel := smp.fetchContext(ctx, secObj)
 	defer cancel()
 	switch secObj.ObjectType {
 	case ObjectTypeKMS, "":
-		err := LimiterInstance.Kms.Wait(waitTimeoutCtx)
+		err := waitForToken(fetchTimeoutCtx, LimiterInstance.Kms)
 		if err != nil {
 			return "", nil, err
 		}
-		if smp.KmsClient == nil {
-			return "", nil, fmt.Errorf("kms client is empty")
+		client, err := smp.kmsClientFor(secObj)
+		if err != nil {
+			return "", nil, err
 		}
-		return getKMSSecret(smp.KmsClient, secObj)
+		return smp.getKMSSecret(fetchTimeoutCtx, client, secObj)
 	case ObjectTypeOOS:
-		err := LimiterInstance.OOS.Wait(waitTimeoutCtx)
+		err := waitForToken(fetchTimeoutCtx, LimiterInstance.OOS)
 		if err != nil {
 			return "", nil, err
 		}
-		if smp.OosClient == nil {
-			return "", nil, fmt.Errorf("oos client is empty")
+		client, err := smp.oosClientFor(secObj)
+		if err != nil {
+			return "", nil, err
 		}
-		return getOOSSecret(smp.OosClient, secObj)
+		return smp.getOOSSecret(fetchTimeoutCtx, client, secObj)
+	case ObjectTypeDataKey:
+		err := waitForToken(fetchTimeoutCtx, LimiterInstance.Kms)
+		if err != nil {
+			return "", nil, err
+		}
+		client, err := smp.kmsClientFor(secObj)
+		if err != nil {
+			return "", nil, err
+		}
+		return smp.getDataKey(fetchTimeoutCtx, client, secObj)
+	case ObjectTypeFile:
+		return smp.getLocalFileSecret(fetchTimeoutCtx, secObj)
 	default:
-		return "", nil, fmt.Errorf("Secret type  %s not support. Only support kms and oos", secObj.ObjectType)
+		return "", nil, fmt.Errorf("Secret type  %s not support. Only support kms, oos, datakey and file", secObj.ObjectType)
 	}
 }
 
-func getKMSSecret(c *kms.Client, secObj *SecretObject) (string, *SecretValue, error) {
+func (smp *SecretsManagerProvider) getKMSSecret(ctx context.Context, c KmsAPI, secObj *SecretObject) (string, *SecretValue, error) {
 	request := &kms.GetSecretValueRequest{
 		SecretName: tea.String(secObj.ObjectName),
 	}
@@ -170,81 +730,171 @@ func getKMSSecret(c *kms.Client, secObj *SecretObject) (string, *SecretValue, er
 	if secObj.ObjectVersionLabel != "" {
 		request.VersionStage = tea.String(secObj.ObjectVersionLabel)
 	}
-	response, err := c.GetSecretValue(request)
+	var response *kms.GetSecretValueResponse
+	err := smp.withRetry(ctx, ObjectTypeKMS, secObj, func() (err error) {
+		response, err = c.GetSecretValue(request)
+		return smp.accessDeniedError(err, "kms:GetSecretValue", secObj)
+	})
 	if err != nil {
-		klog.Error(err, "failed to get %s secret value from kms, err = %s", secObj.ObjectName, err.Error())
-		if !judgeNeedRetry(err) {
-			klog.Error(err, "failed to get secret value from kms", "key", secObj.ObjectName)
-			return "", nil, fmt.Errorf("Failed fetching secret %s: %s", secObj.ObjectName, err.Error())
-		} else {
-			time.Sleep(getWaitTimeExponential(1))
-			response, err = c.GetSecretValue(request)
-			if err != nil {
-				klog.Error(err, "failed to get secret value from kms", "key", secObj.ObjectName)
-				return "", nil, fmt.Errorf("Failed fetching secret %s: %s", secObj.ObjectName, err.Error())
-			}
-		}
+		klog.Error(err, "failed to get secret value from kms", "key", secObj.ObjectName)
+		return "", nil, fmt.Errorf("Failed fetching secret %s: %w", secObj.ObjectName, err)
 	}
-	if *response.Body.SecretDataType == utils.BinaryType {
-		klog.Error(err, "not support binary type yet", "key", secObj.ObjectName)
-		return "", nil, fmt.Errorf("Secret type not support at %s: %s", secObj.ObjectName, err.Error())
+	if *response.Body.SecretDataType == secretUtils.BinaryType {
+		klog.Error("not support binary type yet", "key", secObj.ObjectName)
+		return "", nil, fmt.Errorf("Secret type not support at %s: %s", secObj.ObjectName, secretUtils.BinaryType)
 
 	}
 
-	return *response.Body.VersionId, &SecretValue{Value: []byte(*response.Body.SecretData), SecretObj: *secObj}, nil
+	return *response.Body.VersionId, &SecretValue{
+		Value:      []byte(*response.Body.SecretData),
+		SecretObj:  *secObj,
+		SecretType: tea.StringValue(response.Body.SecretType),
+	}, nil
 }
 
-func getOOSSecret(c *oos.Client, secObj *SecretObject) (string, *SecretValue, error) {
+func (smp *SecretsManagerProvider) getOOSSecret(ctx context.Context, c OosAPI, secObj *SecretObject) (string, *SecretValue, error) {
 	request := &oos.GetSecretParameterRequest{
-		Name:           tea.String(secObj.ObjectName),
+		Name:           tea.String(secObj.parameterName()),
 		WithDecryption: tea.Bool(true),
 	}
-	response, err := c.GetSecretParameter(request)
+	if secObj.ObjectVersion != "" {
+		parameterVersion, _ := strconv.Atoi(secObj.ObjectVersion) // Checked by validateSecretObject
+		request.ParameterVersion = tea.Int32(int32(parameterVersion))
+	}
+	var response *oos.GetSecretParameterResponse
+	err := smp.withRetry(ctx, ObjectTypeOOS, secObj, func() (err error) {
+		response, err = c.GetSecretParameter(request)
+		return smp.accessDeniedError(err, "oos:GetSecretParameter", secObj)
+	})
 	if err != nil {
-		if !judgeNeedRetry(err) {
-			klog.Error(err, "failed to get secret value from oos", "key", secObj.ObjectName)
-			return "", nil, fmt.Errorf("Failed fetching secret %s: %s", secObj.ObjectName, err.Error())
-		} else {
-			time.Sleep(getWaitTimeExponential(1))
-			response, err = c.GetSecretParameter(request)
-			if err != nil {
-				klog.Error(err, "failed to get secret value from oos", "key", secObj.ObjectName)
-				return "", nil, fmt.Errorf("Failed fetching secret %s: %s", secObj.ObjectName, err.Error())
-			}
-		}
+		klog.Error(err, "failed to get secret value from oos", "key", secObj.ObjectName)
+		return "", nil, fmt.Errorf("Failed fetching secret %s: %w", secObj.ObjectName, err)
+	}
+	if err = checkStringListType(secObj, response.Body.Parameter.Type); err != nil {
+		return "", nil, err
 	}
-	if *response.Body.Parameter.Value == utils.BinaryType {
-		klog.Error(err, "not support binary type yet", "key", secObj.ObjectName)
-		return "", nil, fmt.Errorf("Secret type not support at %s: %s", secObj.ObjectName, err.Error())
+	if *response.Body.Parameter.Value == secretUtils.BinaryType {
+		klog.Error("not support binary type yet", "key", secObj.ObjectName)
+		return "", nil, fmt.Errorf("Secret type not support at %s: %s", secObj.ObjectName, secretUtils.BinaryType)
 
 	}
 
-	return "v1", &SecretValue{Value: []byte(*response.Body.Parameter.Value), SecretObj: *secObj}, nil
+	version := "v1" // Kept for responses without a version
+	if response.Body.Parameter.ParameterVersion != nil {
+		version = strconv.Itoa(int(tea.Int32Value(response.Body.Parameter.ParameterVersion)))
+	}
+	return version, &SecretValue{Value: []byte(*response.Body.Parameter.Value), SecretObj: *secObj}, nil
 }
 
-func judgeNeedRetry(err error) bool {
-	respErr, is := err.(*sdkErr.ClientError)
-	if is && (respErr.ErrorCode() == REJECTED_THROTTLING || respErr.ErrorCode() == SERVICE_UNAVAILABLE_TEMPORARY || respErr.ErrorCode() == INTERNAL_FAILURE) {
+// Report whether a failed call should be retried, based on the built-in
+// transient error codes, RetryableErrorCodes and the provider RetryPredicate.
+func (smp *SecretsManagerProvider) judgeNeedRetry(err error) bool {
+	if isAccessDenied(err) {
+		return false
+	}
+	code := getErrorCode(err)
+	if code == REJECTED_THROTTLING || code == SERVICE_UNAVAILABLE_TEMPORARY || code == INTERNAL_FAILURE {
 		return true
 	}
+	if len(code) > 0 && utils.Contains(RetryableErrorCodes, code) {
+		return true
+	}
+	if smp.RetryPredicate != nil {
+		return smp.RetryPredicate(err)
+	}
 	return false
 }
 
+// Call f, retrying errors accepted by judgeNeedRetry with exponential backoff
+// up to the maxRetries of the object's retry policy. The attempt counter is local to each
+// call, so a success never carries a prior backoff window over to later calls.
+// Calls fail fast with ErrCircuitOpen while the breaker of the backend, or of
+// the kmsEndpoint of the object, is open.
+func (smp *SecretsManagerProvider) withRetry(ctx context.Context, backend string, secObj *SecretObject, f func() error) (err error) {
+	policy := smp.retryPolicyFor(secObj)
+	breaker := BreakerInstance.forObject(backend, secObj)
+	if err = breaker.Allow(); err != nil {
+		return err
+	}
+	defer func() { breaker.Record(smp.isBackendFailure(err)) }()
+
+	for attempt := 1; ; attempt++ {
+		countAttempt(ctx)
+		err = LimiterInstance.InFlight.Do(ctx, f)
+		if err == nil || !smp.judgeNeedRetry(err) {
+			return err
+		}
+		if attempt > policy.maxRetries {
+			metrics.RetriesExhausted.Add(backend, 1)
+			return err
+		}
+		klog.Warningf("retrying failed request after attempt %d: %s", attempt, err.Error())
+		metrics.Retries.Add(backend, 1)
+		if err = sleep(ctx, exponentialWait(policy.retryInterval, attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// Sleep between retries, replaced in tests.
+var sleep = sleepContext
+
+// Wait for d, returning the context error early when ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Return 2^retryTimes * BACKOFF_DEFAULT_RETRY_INTERVAL, capped at
+// BACKOFF_DEFAULT_CAPACITY.
 func getWaitTimeExponential(retryTimes int) time.Duration {
-	sleepInterval := time.Duration(math.Pow(2, float64(retryTimes))) * BACKOFF_DEFAULT_RETRY_INTERVAL
-	if sleepInterval >= BACKOFF_DEFAULT_CAPACITY {
+	return exponentialWait(BACKOFF_DEFAULT_RETRY_INTERVAL, retryTimes)
+}
+
+// Return 2^retryTimes * interval, capped at BACKOFF_DEFAULT_CAPACITY. The
+// interval is doubled step by step rather than computed with math.Pow, which
+// overflows time.Duration at high retry counts.
+func exponentialWait(interval time.Duration, retryTimes int) time.Duration {
+	sleepInterval := interval
+	for i := 0; i < retryTimes && sleepInterval < BACKOFF_DEFAULT_CAPACITY; i++ {
+		if sleepInterval > math.MaxInt64/2 {
+			return BACKOFF_DEFAULT_CAPACITY
+		}
+		sleepInterval *= 2
+	}
+	if sleepInterval >= BACKOFF_DEFAULT_CAPACITY || sleepInterval < 0 {
 		return BACKOFF_DEFAULT_CAPACITY
-	} else {
-		return sleepInterval
 	}
+	return sleepInterval
+}
+
+// Close releases the SDK clients held by the provider. It is idempotent and
+// safe to call on a provider that never fetched anything. The shared cache
+// belongs to the process, not to the provider, so it is left untouched; it is
+// wiped by PurgeSharedCache.
+func (p *SecretsManagerProvider) Close() error {
+	p.KmsClient = nil
+	p.OosClient = nil
+	p.clients.reset()
+	return nil
 }
 
 // Reload a secret from the file system.
 func (p *SecretsManagerProvider) reloadSecret(secObj *SecretObject) (val *SecretValue, e error) {
-	sValue, err := ioutil.ReadFile(secObj.GetMountPath())
+	sValue, err := p.readMounted(secObj)
 	if err != nil {
 		return nil, err
 	}
 
-	return &SecretValue{Value: sValue, SecretObj: *secObj}, nil
+	secret := &SecretValue{Value: sValue, SecretObj: *secObj}
+	if err = p.verifyMounted(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
 }
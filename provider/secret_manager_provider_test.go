@@ -0,0 +1,809 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestClose(t *testing.T) {
+	p := &SecretsManagerProvider{KmsClient: &kms.Client{}}
+	for i := 0; i < 2; i++ {
+		if err := p.Close(); err != nil {
+			t.Fatalf("Close() call %d error = %v", i, err)
+		}
+	}
+	if p.KmsClient != nil || p.OosClient != nil {
+		t.Errorf("Close() did not release clients")
+	}
+}
+
+func TestKmsClientForRegion(t *testing.T) {
+	defaultClient := &kms.Client{}
+	created := make(map[string]int)
+	p := &SecretsManagerProvider{
+		KmsClient: defaultClient,
+		Region:    "cn-hangzhou",
+		NewKmsClient: func(region, endpoint string) (KmsAPI, error) {
+			created[region]++
+			return &kms.Client{}, nil
+		},
+	}
+
+	objects := []*SecretObject{
+		{ObjectName: "MySecret"},
+		{ObjectName: "MySecret", Region: "cn-hangzhou"},
+		{ObjectName: "acs:kms:cn-beijing:12345678:secret/MySecret"},
+		{ObjectName: "MySecret", Region: "cn-beijing"},
+		{ObjectName: "MySecret", Region: "cn-shanghai"},
+	}
+	var beijing KmsAPI
+	for _, obj := range objects {
+		c, err := p.kmsClientFor(obj)
+		if err != nil {
+			t.Fatalf("kmsClientFor() error = %v", err)
+		}
+		switch obj.getRegion() {
+		case "", "cn-hangzhou":
+			if c != defaultClient {
+				t.Errorf("expected the default client for %s", obj.ObjectName)
+			}
+		case "cn-beijing":
+			if beijing == nil {
+				beijing = c
+			} else if c != beijing {
+				t.Errorf("expected the cached cn-beijing client to be reused")
+			}
+		}
+	}
+	if created["cn-beijing"] != 1 || created["cn-shanghai"] != 1 || created["cn-hangzhou"] != 0 {
+		t.Errorf("unexpected client creations: %v", created)
+	}
+}
+
+func TestKmsClientForRole(t *testing.T) {
+	const roleArn = "acs:ram::12345678:role/reader"
+	errSTS := errors.New("sts failure")
+	created := make(map[string]int)
+	p := &SecretsManagerProvider{
+		KmsClient: &kms.Client{},
+		Region:    "cn-hangzhou",
+		NewRoleKmsClient: func(region, role, endpoint string) (KmsAPI, error) {
+			if role != roleArn {
+				return nil, errSTS
+			}
+			created[clientKey(region, role, endpoint)]++
+			return &kms.Client{}, nil
+		},
+	}
+
+	first, err := p.kmsClientFor(&SecretObject{ObjectName: "MySecret", AssumeRole: roleArn})
+	if err != nil {
+		t.Fatalf("kmsClientFor() error = %v", err)
+	}
+	if first == p.KmsClient {
+		t.Errorf("expected a role client instead of the default client")
+	}
+	second, _ := p.kmsClientFor(&SecretObject{ObjectName: "Other", AssumeRole: roleArn})
+	if second != first {
+		t.Errorf("expected the cached role client to be reused")
+	}
+	if _, err := p.kmsClientFor(&SecretObject{ObjectName: "MySecret", AssumeRole: roleArn, Region: "cn-beijing"}); err != nil {
+		t.Fatalf("kmsClientFor() error = %v", err)
+	}
+	if created[roleArn+"@cn-hangzhou"] != 1 || created[roleArn+"@cn-beijing"] != 1 {
+		t.Errorf("unexpected client creations: %v", created)
+	}
+
+	_, err = p.kmsClientFor(&SecretObject{ObjectName: "MySecret", AssumeRole: "acs:ram::12345678:role/other"})
+	if !errors.Is(err, errSTS) {
+		t.Errorf("kmsClientFor() error = %v, want wrapped %v", err, errSTS)
+	}
+	p.NewRoleKmsClient = nil
+	if _, err := p.kmsClientFor(&SecretObject{ObjectName: "MySecret", AssumeRole: "acs:ram::12345678:role/new"}); err == nil {
+		t.Errorf("expected an error without a role client factory")
+	}
+}
+
+// A KMS client returning canned responses, counting the calls made.
+type mockKmsClient struct {
+	getSecretValue       func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error)
+	listSecretVersionIds func(*kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error)
+	generateDataKey      func(*kms.GenerateDataKeyRequest) (*kms.GenerateDataKeyResponse, error)
+	rotateSecret         func(*kms.RotateSecretRequest) (*kms.RotateSecretResponse, error)
+	describeSecret       func(*kms.DescribeSecretRequest) (*kms.DescribeSecretResponse, error)
+	calls                int
+}
+
+func (m *mockKmsClient) GetSecretValue(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+	m.calls++
+	return m.getSecretValue(request)
+}
+
+func (m *mockKmsClient) ListSecretVersionIds(request *kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error) {
+	m.calls++
+	return m.listSecretVersionIds(request)
+}
+
+func (m *mockKmsClient) GenerateDataKey(request *kms.GenerateDataKeyRequest) (*kms.GenerateDataKeyResponse, error) {
+	m.calls++
+	return m.generateDataKey(request)
+}
+
+func (m *mockKmsClient) RotateSecret(request *kms.RotateSecretRequest) (*kms.RotateSecretResponse, error) {
+	m.calls++
+	return m.rotateSecret(request)
+}
+
+func (m *mockKmsClient) DescribeSecret(request *kms.DescribeSecretRequest) (*kms.DescribeSecretResponse, error) {
+	m.calls++
+	return m.describeSecret(request)
+}
+
+func kmsSecretResponse(value, version string) *kms.GetSecretValueResponse {
+	return &kms.GetSecretValueResponse{Body: &kms.GetSecretValueResponseBody{
+		SecretData:     tea.String(value),
+		SecretDataType: tea.String("text"),
+		VersionId:      tea.String(version),
+	}}
+}
+
+// Use a fast backoff and an unlimited rate limiter for the duration of a test.
+func setupFetchTest(t *testing.T) {
+	oldLimiter, oldInterval := LimiterInstance, BACKOFF_DEFAULT_RETRY_INTERVAL
+	t.Cleanup(func() { LimiterInstance, BACKOFF_DEFAULT_RETRY_INTERVAL = oldLimiter, oldInterval })
+	LimiterInstance.Kms.SecretPullLimiter = rate.NewLimiter(rate.Inf, 1)
+	LimiterInstance.OOS.SecretPullLimiter = rate.NewLimiter(rate.Inf, 1)
+	BACKOFF_DEFAULT_RETRY_INTERVAL = time.Millisecond
+}
+
+func TestRetryableErrorCodes(t *testing.T) {
+	setupFetchTest(t)
+	oldCodes := RetryableErrorCodes
+	defer func() { RetryableErrorCodes = oldCodes }()
+	RetryableErrorCodes = []string{"Custom.Transient"}
+
+	tests := []struct {
+		name      string
+		code      string
+		predicate func(error) bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{"builtin-code", REJECTED_THROTTLING, nil, 2, false},
+		{"custom-code", "Custom.Transient", nil, 2, false},
+		{"predicate", "Predicate.Transient", func(err error) bool { return getErrorCode(err) == "Predicate.Transient" }, 2, false},
+		{"unlisted-code", "Forbidden.NoPermission", nil, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockKmsClient{}
+			client.getSecretValue = func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				if client.calls == 1 {
+					return nil, &tea.SDKError{Code: tea.String(tt.code)}
+				}
+				return kmsSecretResponse("secret", "v1"), nil
+			}
+			p := &SecretsManagerProvider{KmsClient: client, RetryPredicate: tt.predicate}
+			_, _, err := p.fetchSecret(context.Background(), &SecretObject{ObjectName: "MySecret"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("fetchSecret() made %d calls, want %d", client.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestBackoffResetsAfterSuccess(t *testing.T) {
+	setupFetchTest(t)
+	var sleeps []time.Duration
+	oldSleep := sleep
+	defer func() { sleep = oldSleep }()
+	sleep = func(_ context.Context, d time.Duration) error { sleeps = append(sleeps, d); return nil }
+
+	// Every fetch is throttled once and then succeeds.
+	client := &mockKmsClient{}
+	client.getSecretValue = func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		if client.calls%2 == 1 {
+			return nil, &tea.SDKError{Code: tea.String(REJECTED_THROTTLING)}
+		}
+		return kmsSecretResponse("secret", "v1"), nil
+	}
+	p := &SecretsManagerProvider{KmsClient: client}
+	for i := 0; i < 5; i++ {
+		if _, _, err := p.fetchSecret(context.Background(), &SecretObject{ObjectName: "MySecret"}); err != nil {
+			t.Fatalf("fetchSecret() %d error = %v", i, err)
+		}
+	}
+
+	if len(sleeps) != 5 {
+		t.Fatalf("expected one backoff per fetch, got %v", sleeps)
+	}
+	for i, d := range sleeps {
+		if d != getWaitTimeExponential(1) {
+			t.Errorf("backoff for fetch %d = %s, want %s", i, d, getWaitTimeExponential(1))
+		}
+	}
+}
+
+func TestGetWaitTimeExponential(t *testing.T) {
+	oldInterval, oldCapacity := BACKOFF_DEFAULT_RETRY_INTERVAL, BACKOFF_DEFAULT_CAPACITY
+	defer func() { BACKOFF_DEFAULT_RETRY_INTERVAL, BACKOFF_DEFAULT_CAPACITY = oldInterval, oldCapacity }()
+
+	for _, capacity := range []time.Duration{10 * time.Second, time.Duration(math.MaxInt64)} {
+		BACKOFF_DEFAULT_RETRY_INTERVAL, BACKOFF_DEFAULT_CAPACITY = 200*time.Millisecond, capacity
+		prev := time.Duration(0)
+		for retryTimes := 0; retryTimes <= 40; retryTimes++ {
+			got := getWaitTimeExponential(retryTimes)
+			if got < 0 || got > capacity {
+				t.Errorf("getWaitTimeExponential(%d) = %s, want within [0, %s]", retryTimes, got, capacity)
+			}
+			if got < prev {
+				t.Errorf("getWaitTimeExponential(%d) = %s, smaller than %s for %d", retryTimes, got, prev, retryTimes-1)
+			}
+			prev = got
+		}
+	}
+
+	BACKOFF_DEFAULT_RETRY_INTERVAL, BACKOFF_DEFAULT_CAPACITY = 200*time.Millisecond, 10*time.Second
+	if got := getWaitTimeExponential(2); got != 800*time.Millisecond {
+		t.Errorf("getWaitTimeExponential(2) = %s, want 800ms", got)
+	}
+	if got := getWaitTimeExponential(1000); got != 10*time.Second {
+		t.Errorf("getWaitTimeExponential(1000) = %s, want 10s", got)
+	}
+}
+
+func TestRetrySleepHonorsCancellation(t *testing.T) {
+	setupFetchTest(t)
+	oldCapacity := BACKOFF_DEFAULT_CAPACITY
+	defer func() { BACKOFF_DEFAULT_CAPACITY = oldCapacity }()
+	BACKOFF_DEFAULT_RETRY_INTERVAL, BACKOFF_DEFAULT_CAPACITY = time.Hour, time.Hour
+
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return nil, &tea.SDKError{Code: tea.String(REJECTED_THROTTLING)}
+	}}
+	p := &SecretsManagerProvider{KmsClient: client}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, _, err := p.fetchSecret(ctx, &SecretObject{ObjectName: "MySecret"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("fetchSecret() error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("fetchSecret() returned after %s, expected it to stop at cancellation", elapsed)
+	}
+}
+
+func TestGetSecretValuesOrder(t *testing.T) {
+	setupFetchTest(t)
+	client := &mockKmsClient{getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(`{"a": "1", "b": "2", "m": {"z": "3", "y": "4", "x": "5"}}`, "v1"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client}
+	spec := `
+- objectName: "first"
+  envFile: "first.env"
+  jmesPath:
+    - {path: "b", objectAlias: "b"}
+    - {path: "a", objectAlias: "a"}
+- objectName: "second"
+  infoFile: true
+  jmesPath:
+    - {path: "b", objectAlias: "second-b"}
+    - {path: "m", objectAlias: "m-", fanOut: true}
+    - {path: "a", objectAlias: "a", mergeInto: "merged.json"}
+`
+	want := []string{"first", "b", "a", "first.env", "second", "second-b", "m-x", "m-y", "m-z", "second.info", "merged.json"}
+	for run := 0; run < 10; run++ {
+		objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+		if err != nil {
+			t.Fatalf("NewSecretObjectList() error = %v", err)
+		}
+		values, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+		if err != nil {
+			t.Fatalf("GetSecretValues() error = %v", err)
+		}
+		var got []string
+		for _, v := range values {
+			got = append(got, v.SecretObj.GetFileName())
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("run %d: GetSecretValues() order = %v, want %v", run, got, want)
+		}
+	}
+}
+
+func BenchmarkGetSecretValues(b *testing.B) {
+	oldLimiter := LimiterInstance
+	defer func() { LimiterInstance = oldLimiter }()
+	LimiterInstance.Kms.SecretPullLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	value := `{"user": "admin", "password": "pwd", "host": "db.example.com", "port": "5432"}`
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(value, "v1"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client}
+	var spec strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&spec, "- objectName: secret%d\n  jmesPath:\n", i)
+		for _, key := range []string{"user", "password", "host", "port"} {
+			fmt.Fprintf(&spec, "    - {path: %s, objectAlias: secret%d-%s}\n", key, i, key)
+		}
+	}
+	objects, err := NewSecretObjectList("/mnt", "", "", spec.String(), PodMetadata{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGetSecretValuesReloadsCurrentVersion(t *testing.T) {
+	setupFetchTest(t)
+	fs := newMemFileSystem()
+	if err := fs.WriteFile("/mnt/secrets/MySecret", []byte("mounted"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse("fetched", "v2"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	objects, err := NewSecretObjectList("/mnt/secrets", "", "", `[{"objectName": "MySecret", "objectVersion": "v1"}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+
+	curMap := map[string]*v1alpha1.ObjectVersion{"MySecret": {Id: "MySecret", Version: "v1"}}
+	values, err := p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if client.calls != 0 || string(values[0].Value) != "mounted" {
+		t.Errorf("expected the mounted value to be reloaded without API calls, got %q after %d calls", values[0].Value, client.calls)
+	}
+
+	// A missing file is an error instead of an empty secret.
+	p.FS = newMemFileSystem()
+	if _, err = p.GetSecretValues(context.Background(), objects, curMap); err == nil {
+		t.Errorf("expected an error when the mounted file is missing")
+	}
+}
+
+func TestGetSecretValuesVersionAlias(t *testing.T) {
+	setupFetchTest(t)
+	fs := newMemFileSystem()
+	if err := fs.WriteFile("/mnt/secrets/MySecret-v1", []byte("mounted"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse("fetched", "v2"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	spec := `[{"objectName": "MySecret", "objectAlias": "MySecret-{{.Version}}", "objectVersion": "v1", "infoFile": true}]`
+	objects, err := NewSecretObjectList("/mnt/secrets", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+
+	// A fetched secret is named after the version it was fetched at.
+	curMap := make(map[string]*v1alpha1.ObjectVersion)
+	values, err := p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if got := values[0].SecretObj.GetFileName(); got != "MySecret-v2" {
+		t.Errorf("file name = %s, want MySecret-v2", got)
+	}
+	if got := values[1].SecretObj.GetFileName(); got != "MySecret-v2.info" {
+		t.Errorf("info file name = %s, want MySecret-v2.info", got)
+	}
+	if curVer := curMap["MySecret-{{.Version}}"]; curVer == nil || curVer.Version != "v2" {
+		t.Errorf("expected the version to be recorded under the unrendered name, got %v", curMap)
+	}
+
+	// A current secret is reloaded from the file named after its version.
+	curMap = map[string]*v1alpha1.ObjectVersion{"MySecret-{{.Version}}": {Id: "MySecret-{{.Version}}", Version: "v1"}}
+	fs.WriteFile("/mnt/secrets/MySecret-v1.info", []byte("version=v1\n"), 0644)
+	client.calls = 0
+	values, err = p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if client.calls != 0 || string(values[0].Value) != "mounted" || values[0].SecretObj.GetFileName() != "MySecret-v1" {
+		t.Errorf("expected MySecret-v1 to be reloaded without API calls, got %s=%q after %d calls",
+			values[0].SecretObj.GetFileName(), values[0].Value, client.calls)
+	}
+
+	// A rendered name colliding with another object fails the mount.
+	spec = `[{"objectName": "MySecret", "objectAlias": "MySecret-{{.Version}}"}, {"objectName": "Other", "objectAlias": "MySecret-v2"}]`
+	objects, err = NewSecretObjectList("/mnt/secrets", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	if _, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion)); err == nil {
+		t.Errorf("expected an error for colliding file names")
+	}
+
+	// The version is only known for the object itself.
+	spec = `[{"objectName": "MySecret", "jmesPath": [{"path": "a", "objectAlias": "a-{{.Version}}"}]}]`
+	if _, err = NewSecretObjectList("/mnt/secrets", "", "", spec, PodMetadata{}); err == nil {
+		t.Errorf("expected an error for a version placeholder in a jmesPath alias")
+	}
+}
+
+func TestGetSecretValuesMaxFilesPerObject(t *testing.T) {
+	setupFetchTest(t)
+	oldMax := MaxFilesPerObject
+	defer func() { MaxFilesPerObject = oldMax }()
+	MaxFilesPerObject = 3
+
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(`{"hosts": ["a", "b", "c", "d"], "db": {"user": "admin", "password": "pwd"}}`, "v1"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client}
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{"within-limit", `[{"objectName": "s", "jmesPath": [{"path": "db", "objectAlias": "db-", "fanOut": true}]}]`, ""},
+		{"fan-out", `[{"objectName": "s", "jmesPath": [{"path": "hosts", "objectAlias": "host-", "fanOut": true}]}]`, "resolved to 4 entries"},
+		{"object", `[{"objectName": "s", "infoFile": true, "jmesPath": [{"path": "db", "objectAlias": "db-", "fanOut": true}]}]`, "produces 4 files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			values, err := p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+			if len(tt.wantErr) == 0 {
+				if err != nil || len(values) != 3 {
+					t.Errorf("expected 3 files, got %d (%v)", len(values), err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GetSecretValues() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetSecretValuesFanOutCollisions(t *testing.T) {
+	setupFetchTest(t)
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(`{"db": {"user": "admin", "password": "pwd"}}`, "v1"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client}
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{"distinct", `[{"objectName": "a", "jmesPath": [{"path": "db", "objectAlias": "db-", "fanOut": true}]}, {"objectName": "b"}]`, ""},
+		{"object-name", `[{"objectName": "a", "jmesPath": [{"path": "db", "objectAlias": "db-", "fanOut": true}]}, {"objectName": "db-user"}]`,
+			"File name db-user of object db-user is already used by object a"},
+		{"other-object-name", `[{"objectName": "db-user"}, {"objectName": "a", "jmesPath": [{"path": "db", "objectAlias": "db-", "fanOut": true}]}]`,
+			"File name db-user of a jmesPath or derived file of object a is already used by object db-user"},
+		{"jmes-alias-of-other-object", `[{"objectName": "a", "jmesPath": [{"path": "db.user", "objectAlias": "db-user"}]}, {"objectName": "b", "jmesPath": [{"path": "db", "objectAlias": "db-", "fanOut": true}]}]`,
+			"File name db-user of a jmesPath or derived file of object b is already used by object a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			_, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("GetSecretValues() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("GetSecretValues() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A ListSecretVersionIds response where version is the ACSCurrent version.
+func kmsCurrentVersionResponse(version string) *kms.ListSecretVersionIdsResponse {
+	return &kms.ListSecretVersionIdsResponse{Body: &kms.ListSecretVersionIdsResponseBody{
+		TotalCount: tea.Int32(1),
+		VersionIds: &kms.ListSecretVersionIdsResponseBodyVersionIds{
+			VersionId: []*kms.ListSecretVersionIdsResponseBodyVersionIdsVersionId{{
+				VersionId:     tea.String(version),
+				VersionStages: &kms.ListSecretVersionIdsResponseBodyVersionIdsVersionIdVersionStages{VersionStage: []*string{tea.String(KMS_CURRENT_VERSION_STAGE)}},
+			}},
+		},
+	}}
+}
+
+func TestGetChangedSecretValues(t *testing.T) {
+	setupFetchTest(t)
+	upstream := map[string]string{"same": "v1", "rotated": "v2"}
+	var fetched []string
+	client := &mockKmsClient{
+		listSecretVersionIds: func(request *kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error) {
+			return kmsCurrentVersionResponse(upstream[tea.StringValue(request.SecretName)]), nil
+		},
+		getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+			name := tea.StringValue(request.SecretName)
+			fetched = append(fetched, name)
+			return kmsSecretResponse(`{"user": "`+name+`"}`, upstream[name]), nil
+		},
+	}
+	fs := newMemFileSystem()
+	fs.WriteFile("/mnt/same", []byte(`{"user": "same"}`), 0644)
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	spec := `
+- objectName: "same"
+  jmesPath:
+    - path: "user"
+      objectAlias: "same-user"
+- objectName: "rotated"
+  jmesPath:
+    - path: "user"
+      objectAlias: "config.json"
+      mergeInto: "merged.json"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := map[string]*v1alpha1.ObjectVersion{
+		"same":        {Id: "same", Version: "v1"},
+		"same-user":   {Id: "same-user", Version: "v1"},
+		"rotated":     {Id: "rotated", Version: "v1"},
+		"merged.json": {Id: "merged.json", Version: "rotated=v1"},
+	}
+	changed, updated, err := p.GetChangedSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetChangedSecretValues() error = %v", err)
+	}
+	if strings.Join(fetched, ",") != "rotated" {
+		t.Errorf("fetched %v, expected the unchanged secret to be left alone", fetched)
+	}
+	if strings.Join(updated, ",") != "rotated" {
+		t.Errorf("updated = %v, want [rotated]", updated)
+	}
+	var names []string
+	for _, value := range changed {
+		names = append(names, value.SecretObj.GetFileName())
+	}
+	if strings.Join(names, ",") != "rotated,merged.json" {
+		t.Errorf("changed files = %v, want [rotated merged.json]", names)
+	}
+	if curMap["rotated"].Version != "v2" || curMap["same"].Version != "v1" {
+		t.Errorf("unexpected current versions %v", curMap)
+	}
+
+	// Nothing changed on the next resync, once the changed files are written.
+	for _, value := range changed {
+		fs.WriteFile(value.SecretObj.GetMountPath(), value.Value, 0644)
+	}
+	fetched = nil
+	changed, updated, err = p.GetChangedSecretValues(context.Background(), objects, curMap)
+	if err != nil || len(changed) != 0 || len(updated) != 0 {
+		t.Errorf("expected no changes, got %d values, %v updated (%v)", len(changed), updated, err)
+	}
+	if p.checkVersions {
+		t.Errorf("expected version checks to be limited to GetChangedSecretValues")
+	}
+}
+
+func TestGetSecretValuesAlwaysLatest(t *testing.T) {
+	setupFetchTest(t)
+	oldCheck := CheckCurrentVersion
+	defer func() { CheckCurrentVersion = oldCheck }()
+	CheckCurrentVersion = true
+
+	var fetched, listed []string
+	client := &mockKmsClient{
+		listSecretVersionIds: func(request *kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error) {
+			listed = append(listed, tea.StringValue(request.SecretName))
+			return kmsCurrentVersionResponse("v1"), nil
+		},
+		getSecretValue: func(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+			fetched = append(fetched, tea.StringValue(request.SecretName))
+			if request.VersionStage != nil {
+				t.Errorf("expected no version stage for %s, got %s", tea.StringValue(request.SecretName), tea.StringValue(request.VersionStage))
+			}
+			return kmsSecretResponse("value", "v1"), nil
+		},
+	}
+	fs := newMemFileSystem()
+	fs.WriteFile("/mnt/checked", []byte("value"), 0644)
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	spec := `
+- objectName: "checked"
+- objectName: "flag"
+  alwaysLatest: true
+- objectName: "label"
+  objectVersionLabel: "LATEST"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := map[string]*v1alpha1.ObjectVersion{
+		"checked": {Id: "checked", Version: "v1"},
+		"flag":    {Id: "flag", Version: "v1"},
+		"label":   {Id: "label", Version: "v1"},
+	}
+	if _, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if strings.Join(fetched, ",") != "flag,label" || strings.Join(listed, ",") != "checked" {
+		t.Errorf("fetched %v after checking %v, expected only the alwaysLatest objects to be fetched", fetched, listed)
+	}
+}
+
+func TestGetSecretValuesCheckCurrentVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		check       bool
+		upstream    string // ACSCurrent version listed by KMS, empty for a failed listing
+		wantListed  int
+		wantFetched int
+		wantValue   string
+		wantVersion string
+	}{
+		{"unchanged", true, "v1", 1, 0, "mounted", "v1"},
+		{"changed", true, "v2", 1, 1, "fetched", "v2"},
+		{"lookup-failed", true, "", 1, 1, "fetched", "v2"},
+		{"disabled", false, "v1", 0, 1, "fetched", "v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupFetchTest(t)
+			oldCheck := CheckCurrentVersion
+			defer func() { CheckCurrentVersion = oldCheck }()
+			CheckCurrentVersion = tt.check
+
+			listed, fetched := 0, 0
+			client := &mockKmsClient{
+				listSecretVersionIds: func(*kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error) {
+					listed++
+					if len(tt.upstream) == 0 {
+						return nil, &tea.SDKError{Code: tea.String("Forbidden.NoPermission")}
+					}
+					return kmsCurrentVersionResponse(tt.upstream), nil
+				},
+				getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+					fetched++
+					return kmsSecretResponse("fetched", "v2"), nil
+				},
+			}
+			fs := newMemFileSystem()
+			fs.WriteFile("/mnt/s", []byte("mounted"), 0644)
+			p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+			objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "s"}]`, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			curMap := map[string]*v1alpha1.ObjectVersion{"s": {Id: "s", Version: "v1"}}
+			values, err := p.GetSecretValues(context.Background(), objects, curMap)
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if listed != tt.wantListed || fetched != tt.wantFetched {
+				t.Errorf("listed versions %d times and fetched %d times, want %d and %d", listed, fetched, tt.wantListed, tt.wantFetched)
+			}
+			if len(values) != 1 || string(values[0].Value) != tt.wantValue {
+				t.Fatalf("GetSecretValues() = %v, want the %s value", values, tt.wantValue)
+			}
+			if curMap["s"].Version != tt.wantVersion {
+				t.Errorf("recorded version %s, want %s", curMap["s"].Version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListAlwaysLatest(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"flag", `[{"objectName": "s", "alwaysLatest": true}]`, false},
+		{"label", `[{"objectName": "s", "objectVersionLabel": "LATEST"}]`, false},
+		{"pinned", `[{"objectName": "s", "objectVersion": "v1", "alwaysLatest": true}]`, true},
+		{"pinned-label", `[{"objectName": "s", "objectVersion": "v1", "objectVersionLabel": "LATEST"}]`, true},
+		{"datakey", `[{"objectName": "key", "objectType": "datakey", "objectAlias": "k", "ciphertextAlias": "k.enc", "alwaysLatest": true}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetSecretValuesEmitRawWhenJmes(t *testing.T) {
+	setupFetchTest(t)
+	client := &mockKmsClient{getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse(`{"user": "admin", "password": "secret"}`, "v1"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client, FS: newMemFileSystem()}
+	spec := `
+- objectName: "db"
+  emitRawWhenJmes: false
+  jmesPath:
+    - path: "user"
+      objectAlias: "db"
+    - path: "password"
+      objectAlias: "password"
+`
+	objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	for pass := 1; pass <= 2; pass++ {
+		curMap := map[string]*v1alpha1.ObjectVersion{}
+		if pass == 2 { // A mounted extraction named like the object is not reloaded as the raw secret
+			curMap["db"] = &v1alpha1.ObjectVersion{Id: "db", Version: "v1"}
+		}
+		client.calls = 0
+		values, err := p.GetSecretValues(context.Background(), objects, curMap)
+		if err != nil {
+			t.Fatalf("GetSecretValues() error = %v", err)
+		}
+		var files []string
+		for _, value := range values {
+			files = append(files, value.SecretObj.GetFileName()+"="+string(value.Value))
+		}
+		if strings.Join(files, ",") != "db=admin,password=secret" {
+			t.Errorf("pass %d: files = %v, want only the extractions", pass, files)
+		}
+		if len(curMap) != 2 || curMap["db"].Version != "v1" || client.calls != 1 {
+			t.Errorf("pass %d: unexpected current versions %v after %d calls", pass, curMap, client.calls)
+		}
+	}
+	if keys := CurrentVersionKeys(objects); strings.Join(keys, ",") != "db,password" {
+		t.Errorf("CurrentVersionKeys() = %v", keys)
+	}
+}
+
+func TestNewSecretObjectListEmitRawWhenJmes(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"reuse-name", `[{"objectName": "s", "emitRawWhenJmes": false, "jmesPath": [{"path": "a", "objectAlias": "s"}]}]`, false},
+		{"raw-name-in-use", `[{"objectName": "s", "emitRawWhenJmes": true, "jmesPath": [{"path": "a", "objectAlias": "s"}]}]`, true},
+		{"no-jmes", `[{"objectName": "s", "emitRawWhenJmes": false}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
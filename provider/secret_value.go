@@ -1,18 +1,102 @@
 package provider
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/jmespath/go-jmespath"
+	"k8s.io/klog/v2"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type SecretValue struct {
 	Value     []byte
 	SecretObj SecretObject
+
+	// SecretType reported by KMS (e.g. Generic, Rds), empty for OOS parameters.
+	// Reloaded values of objects with extractManagedFields have the recorded type.
+	SecretType string
+
+	// Base64 ciphertext blob of a generated data key (datakey objects only).
+	ciphertext []byte
+
+	// Value parsed as JSON, shared by jmesPath and mergeInto extraction.
+	parsed interface{}
+
+	// The value is not valid UTF-8 and was parsed with its invalid bytes escaped.
+	binary bool
+
+	// The version differs from the one in the current version map, see GetChangedSecretValues.
+	changed bool
+
+	// Type, version and labels of the object the value comes from, for the manifest file.
+	objectType string
+	version    string
+	labels     map[string]string
+}
+
+// Parse the value as JSON once for all jmesPath entries of the object.
+func (sv *SecretValue) jsonData() (interface{}, error) {
+	if sv.parsed == nil {
+		document := sv.Value
+		if sv.binary = !utf8.Valid(document); sv.binary {
+			document = escapeInvalidUTF8(document)
+		}
+		if err := json.Unmarshal(document, &sv.parsed); err != nil {
+			return nil, fmt.Errorf("Invalid JSON used with jmesPath in secret: %s.", sv.SecretObj.ObjectName)
+		}
+	}
+	return sv.parsed, nil
 }
 
 func (sv *SecretValue) String() string { return "<REDACTED>" } // Do not log secrets
 
+// Apply the value transformations requested in the object spec to a freshly
+// fetched secret. Trimming, then newline normalization and the trailing
+// newline, run before any jmesPath extraction, so extracted values are taken
+// from the trimmed document.
+func (sv *SecretValue) transform() {
+	if sv.SecretObj.TrimSpace && utf8.Valid(sv.Value) { // Never modify binary content
+		sv.Value = bytes.TrimSpace(sv.Value)
+	}
+	sv.Value = sv.SecretObj.normalizeNewlines(sv.Value)
+	sv.Value = sv.SecretObj.ensureTrailingNewline(sv.Value)
+}
+
+// Check the fetched value against the failOnEmpty, valuePattern and
+// expectedSha256 settings of the object spec. The error never includes the
+// value itself, nor its digest.
+func (sv *SecretValue) validateValue() error {
+	if len(sv.Value) == 0 {
+		if sv.SecretObj.FailOnEmpty {
+			return fmt.Errorf("Value of secret %s is empty", sv.SecretObj.ObjectName)
+		}
+		klog.Warningf("value of secret %s is empty", sv.SecretObj.ObjectName)
+	}
+	re := sv.SecretObj.valuePatternRE
+	if re == nil && len(sv.SecretObj.ValuePattern) > 0 {
+		var err error
+		if re, err = regexp.Compile(sv.SecretObj.ValuePattern); err != nil {
+			return fmt.Errorf("Invalid valuePattern for object %s: %+v", sv.SecretObj.ObjectName, err)
+		}
+	}
+	if re != nil && !re.Match(sv.Value) {
+		return fmt.Errorf("Value of secret %s does not match valuePattern %s", sv.SecretObj.ObjectName, sv.SecretObj.ValuePattern)
+	}
+	if expected := sv.SecretObj.ExpectedSha256; len(expected) > 0 {
+		sum := sha256.Sum256(sv.Value)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), expected) {
+			return fmt.Errorf("Value of secret %s does not match expectedSha256", sv.SecretObj.ObjectName)
+		}
+	}
+	return nil
+}
+
 func (sv *SecretValue) getJsonSecrets() (s []*SecretValue, e error) {
 
 	jsonValues := make([]*SecretValue, 0)
@@ -20,18 +104,18 @@ func (sv *SecretValue) getJsonSecrets() (s []*SecretValue, e error) {
 		return jsonValues, nil
 	}
 
-	var data interface{}
-	err := json.Unmarshal(sv.Value, &data)
-	if err != nil {
-		return nil, fmt.Errorf("Invalid JSON used with jmesPath in secret: %s.", sv.SecretObj.ObjectName)
+	if _, err := sv.jsonData(); err != nil {
+		return nil, err
 	}
 	//fetch all specified key value pairs`
-	for _, jmesPathEntry := range sv.SecretObj.JMESPath {
-
-		jsonSecret, err := jmespath.Search(jmesPathEntry.Path, data)
+	for i, jmesPathEntry := range sv.SecretObj.JMESPath {
+		if len(jmesPathEntry.MergeInto) > 0 { // Written to the merged file instead
+			continue
+		}
 
+		jsonSecret, err := sv.searchJMES(&sv.SecretObj.JMESPath[i])
 		if err != nil {
-			return nil, fmt.Errorf("Invalid JMES Path: %s.", jmesPathEntry.Path)
+			return nil, err
 		}
 
 		if jsonSecret == nil {
@@ -39,19 +123,114 @@ func (sv *SecretValue) getJsonSecrets() (s []*SecretValue, e error) {
 				jmesPathEntry.Path, jmesPathEntry.ObjectAlias)
 		}
 
-		jsonSecretAsString, isString := jsonSecret.(string)
-		if !isString {
-			return nil, fmt.Errorf("Invalid JMES search result type for path:%s. Only string is allowed.", jmesPathEntry.Path)
+		if jmesPathEntry.FanOut {
+			fanOutValues, err := sv.fanOut(&jmesPathEntry, jsonSecret)
+			if err != nil {
+				return nil, err
+			}
+			jsonValues = append(jsonValues, fanOutValues...)
+			continue
+		}
+
+		value, err := sv.jmesResultValue(&jmesPathEntry, jsonSecret)
+		if err != nil {
+			return nil, err
 		}
 
 		secObj := sv.SecretObj.getJmesEntrySecretObject(&jmesPathEntry)
 
 		secretValue := SecretValue{
-			Value:     []byte(jsonSecretAsString),
+			Value:     value,
 			SecretObj: secObj,
 		}
 		jsonValues = append(jsonValues, &secretValue)
 
 	}
+
+	// fanOut names are only known now, make sure no two outputs share a file.
+	fileNames := make(map[string]bool, len(jsonValues))
+	for _, jsonValue := range jsonValues {
+		fileName := jsonValue.SecretObj.GetFileName()
+		if fileNames[fileName] {
+			return nil, fmt.Errorf("Name already in use for objectAlias: %s", fileName)
+		}
+		fileNames[fileName] = true
+	}
 	return jsonValues, nil
 }
+
+// Convert a JMES search result into the bytes to mount. Strings are written
+// as is, objects and arrays only when prettyJSON is set. The newlineStyle of
+// the object, then the trimSpace and encoding of the entry apply to the result.
+func (sv *SecretValue) jmesResultValue(jmesPathEntry *JMESPathObject, jsonSecret interface{}) ([]byte, error) {
+	value, err := jmesResultBytes(jmesPathEntry, jsonSecret)
+	if err != nil {
+		return nil, err
+	}
+	return jmesPathEntry.encode(sv.SecretObj.normalizeNewlines(value)), nil
+}
+
+func jmesResultBytes(jmesPathEntry *JMESPathObject, jsonSecret interface{}) ([]byte, error) {
+	switch v := jsonSecret.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte: // Raw bytes of a string that was not valid UTF-8, see searchJMES
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		if !jmesPathEntry.PrettyJSON {
+			return nil, fmt.Errorf("Invalid JMES search result type for path:%s. Only string is allowed.", jmesPathEntry.Path)
+		}
+		// Map keys are marshalled in sorted order, so the output is deterministic.
+		value, err := json.MarshalIndent(jsonSecret, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to format JMES search result for path:%s.", jmesPathEntry.Path)
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("Invalid JMES search result type for path:%s. Only string is allowed.", jmesPathEntry.Path)
+	}
+}
+
+// Write each key of an object result, or each element of an array result, to
+// its own file named objectAlias + key (or index). Keys are processed in
+// sorted order and every element follows the same rules as a single result.
+func (sv *SecretValue) fanOut(jmesPathEntry *JMESPathObject, jsonSecret interface{}) ([]*SecretValue, error) {
+	var keys []string
+	elements := make(map[string]interface{})
+	switch v := jsonSecret.(type) {
+	case map[string]interface{}:
+		for key, element := range v {
+			keys = append(keys, key)
+			elements[key] = element
+		}
+		sort.Strings(keys)
+	case []interface{}:
+		for i, element := range v {
+			key := strconv.Itoa(i)
+			keys = append(keys, key)
+			elements[key] = element
+		}
+	default:
+		return nil, fmt.Errorf("JMES Path - %s with fanOut must point to an object or array.", jmesPathEntry.Path)
+	}
+	if MaxFilesPerObject > 0 && len(keys) > MaxFilesPerObject { // Fail before formatting any element
+		return nil, fmt.Errorf("JMES Path - %s with fanOut resolved to %d entries, more than the limit of %d files per object.", jmesPathEntry.Path, len(keys), MaxFilesPerObject)
+	}
+
+	values := make([]*SecretValue, 0, len(keys))
+	for _, key := range keys {
+		entry := *jmesPathEntry
+		entry.ObjectAlias = jmesPathEntry.ObjectAlias + key
+		entry.FanOut = false
+		value, err := sv.jmesResultValue(&entry, elements[key])
+		if err != nil {
+			return nil, err
+		}
+		secObj := sv.SecretObj.getJmesEntrySecretObject(&entry)
+		if isDirectoryName(secObj.GetFileName()) || badPathRE.MatchString(secObj.GetFileName()) {
+			return nil, fmt.Errorf("JMES Path - %s with fanOut produced an invalid file name for key %s.", jmesPathEntry.Path, key)
+		}
+		values = append(values, &SecretValue{Value: value, SecretObj: secObj})
+	}
+	return values, nil
+}
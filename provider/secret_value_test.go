@@ -1,7 +1,9 @@
 package provider
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -70,3 +72,361 @@ func TestInvalidJMESResultType(t *testing.T) {
 
 	RunGetJsonSecretTest(t, jsonContent, path, objectAlias, expectedErrorMessage)
 }
+
+func TestTrimSpace(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     []byte
+		trimSpace bool
+		want      string
+	}{
+		{"trim-disabled", []byte(" secret\n"), false, " secret\n"},
+		{"trim-enabled", []byte(" secret\r\n"), true, "secret"},
+		{"trim-binary", []byte{0xff, ' ', 0xfe, '\n'}, true, string([]byte{0xff, ' ', 0xfe, '\n'})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &SecretValue{
+				Value:     tt.value,
+				SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, TrimSpace: tt.trimSpace},
+			}
+			sv.transform()
+			if string(sv.Value) != tt.want {
+				t.Errorf("transform() got = %q, want %q", sv.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	secretValue := SecretValue{
+		Value: []byte(`{"db": {"user": "admin", "port": 5432, "hosts": ["a", "b"]}, "name": "test"}`),
+		SecretObj: SecretObject{
+			ObjectName: TEST_OBJECT_NAME,
+			JMESPath: []JMESPathObject{
+				{Path: "db", ObjectAlias: "db.json", PrettyJSON: true},
+				{Path: "name", ObjectAlias: "name", PrettyJSON: true},
+			},
+		},
+	}
+
+	jsonSecrets, err := secretValue.getJsonSecrets()
+	if err != nil {
+		t.Fatalf("getJsonSecrets() error = %v", err)
+	}
+	want := "{\n  \"hosts\": [\n    \"a\",\n    \"b\"\n  ],\n  \"port\": 5432,\n  \"user\": \"admin\"\n}"
+	if string(jsonSecrets[0].Value) != want {
+		t.Errorf("getJsonSecrets() got = %q, want %q", jsonSecrets[0].Value, want)
+	}
+	if string(jsonSecrets[1].Value) != "test" {
+		t.Errorf("getJsonSecrets() got = %q, want %q", jsonSecrets[1].Value, "test")
+	}
+}
+
+func TestValuePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		pattern string
+		wantErr bool
+	}{
+		{"no-pattern", "anything", "", false},
+		{"match", "https://example.com", "^https://", false},
+		{"mismatch", "http://example.com", "^https://", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := SecretObject{ObjectName: TEST_OBJECT_NAME, ValuePattern: tt.pattern}
+			if err := s.validateSecretObject(); err != nil {
+				t.Fatalf("validateSecretObject() error = %v", err)
+			}
+			sv := &SecretValue{Value: []byte(tt.value), SecretObj: s}
+			err := sv.validateValue()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), tt.value) {
+				t.Errorf("validateValue() error leaks the value: %v", err)
+			}
+		})
+	}
+
+	s := SecretObject{ObjectName: TEST_OBJECT_NAME, ValuePattern: "("}
+	if err := s.validateSecretObject(); err == nil {
+		t.Errorf("expected error for invalid valuePattern")
+	}
+}
+
+func TestExpectedSha256(t *testing.T) {
+	digest := "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b" // sha256("secret")
+	tests := []struct {
+		name      string
+		value     string
+		expected  string
+		trimSpace bool
+		wantErr   bool
+	}{
+		{"match", "secret", digest, false, false},
+		{"upper-case", "secret", strings.ToUpper(digest), false, false},
+		{"trimmed-match", " secret\n", digest, true, false},
+		{"untrimmed-mismatch", " secret\n", digest, false, true},
+		{"mismatch", "tampered", digest, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := SecretObject{ObjectName: TEST_OBJECT_NAME, ExpectedSha256: tt.expected, TrimSpace: tt.trimSpace}
+			if err := s.validateSecretObject(); err != nil {
+				t.Fatalf("validateSecretObject() error = %v", err)
+			}
+			sv := &SecretValue{Value: []byte(tt.value), SecretObj: s}
+			sv.transform()
+			err := sv.validateValue()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), tt.value) {
+				t.Errorf("validateValue() error leaks the value: %v", err)
+			}
+		})
+	}
+
+	for _, expected := range []string{"abc", digest + "0", strings.Repeat("g", 64)} {
+		s := SecretObject{ObjectName: TEST_OBJECT_NAME, ExpectedSha256: expected}
+		if err := s.validateSecretObject(); err == nil {
+			t.Errorf("expected error for invalid expectedSha256 %q", expected)
+		}
+	}
+}
+
+func TestFailOnEmpty(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		trimSpace   bool
+		failOnEmpty bool
+		wantErr     bool
+	}{
+		{"empty-allowed", "", false, false, false},
+		{"empty-rejected", "", false, true, true},
+		{"blank-trimmed-rejected", " \n", true, true, true},
+		{"non-empty", "value", false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &SecretValue{
+				Value:     []byte(tt.value),
+				SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, TrimSpace: tt.trimSpace, FailOnEmpty: tt.failOnEmpty},
+			}
+			sv.transform()
+			if err := sv.validateValue(); (err != nil) != tt.wantErr {
+				t.Errorf("validateValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	jsonContent := `{"credentials": {"user": "admin", "password": "pwd"}, "hosts": ["a", "b"], "name": "test", "bad": {"": "y"}}`
+	tests := []struct {
+		name    string
+		entries []JMESPathObject
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			"object-with-prefix",
+			[]JMESPathObject{{Path: "credentials", ObjectAlias: "db-", FanOut: true}},
+			map[string]string{"db-password": "pwd", "db-user": "admin"},
+			false,
+		},
+		{
+			"array-without-prefix",
+			[]JMESPathObject{{Path: "hosts", FanOut: true}},
+			map[string]string{"0": "a", "1": "b"},
+			false,
+		},
+		{"scalar", []JMESPathObject{{Path: "name", FanOut: true}}, nil, true},
+		{"bad-key", []JMESPathObject{{Path: "bad", FanOut: true}}, nil, true},
+		{
+			"duplicate",
+			[]JMESPathObject{{Path: "credentials", FanOut: true}, {Path: "name", ObjectAlias: "user"}},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := SecretValue{
+				Value:     []byte(jsonContent),
+				SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, JMESPath: tt.entries, translate: "_"},
+			}
+			values, err := sv.getJsonSecrets()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getJsonSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(values) != len(tt.want) {
+				t.Fatalf("getJsonSecrets() returned %d values, want %d", len(values), len(tt.want))
+			}
+			for _, v := range values {
+				if tt.want[v.SecretObj.GetFileName()] != string(v.Value) {
+					t.Errorf("getJsonSecrets() %s = %q, want %q", v.SecretObj.GetFileName(), v.Value, tt.want[v.SecretObj.GetFileName()])
+				}
+			}
+		})
+	}
+}
+
+// A secret with many keys, each extracted by its own jmesPath entry.
+func benchmarkJsonSecret(entries int) *SecretValue {
+	data := make(map[string]string, entries)
+	jmesPath := make([]JMESPathObject, 0, entries)
+	for i := 0; i < entries; i++ {
+		key := fmt.Sprintf("key%d", i)
+		data[key] = strings.Repeat("v", 64)
+		jmesPath = append(jmesPath, JMESPathObject{Path: key, ObjectAlias: key})
+	}
+	value, _ := json.Marshal(data)
+	return &SecretValue{
+		Value:     value,
+		SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, JMESPath: jmesPath, translate: "_", mountDir: "/mnt"},
+	}
+}
+
+// Every iteration extracts from a new SecretValue, like every fetch does, so
+// the JSON parsed by one iteration is not reused by the next. The paths are
+// compiled once, as when the spec is validated.
+func BenchmarkGetJsonSecrets(b *testing.B) {
+	template := benchmarkJsonSecret(50)
+	for i := range template.SecretObj.JMESPath {
+		if err := template.SecretObj.JMESPath[i].compile(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sv := &SecretValue{Value: template.Value, SecretObj: template.SecretObj}
+		if _, err := sv.getJsonSecrets(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestJMESPathBinary(t *testing.T) {
+	document := "{\"bin\": \"\xff\xfeAB\", \"text\": \"ok é\", \"nested\": {\"b\": \"\xff\"}}"
+	jmesPath := []JMESPathObject{
+		{Path: "bin", ObjectAlias: "bin"},
+		{Path: "text", ObjectAlias: "text"},
+		{Path: "nested", ObjectAlias: "nested.json", PrettyJSON: true},
+	}
+	tests := []struct {
+		name       string
+		jmesBinary string
+		want       []string
+		wantErr    bool
+	}{
+		{"default", "", []string{"//5BQg==", "ok é", "{\n  \"b\": \"/w==\"\n}"}, false},
+		{"base64", jmesBinaryBase64, []string{"//5BQg==", "ok é", "{\n  \"b\": \"/w==\"\n}"}, false},
+		{"fail", jmesBinaryFail, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secretValue := SecretValue{
+				Value:     []byte(document),
+				SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, JMESPath: jmesPath, JmesBinary: tt.jmesBinary},
+			}
+			jsonSecrets, err := secretValue.getJsonSecrets()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getJsonSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if strings.Contains(err.Error(), "\xff") {
+					t.Errorf("expected the error to leave out the value, got %q", err)
+				}
+				return
+			}
+			for i, want := range tt.want {
+				if string(jsonSecrets[i].Value) != want {
+					t.Errorf("getJsonSecrets()[%d] got = %q, want %q", i, jsonSecrets[i].Value, want)
+				}
+			}
+		})
+	}
+
+	// Strings of a valid UTF-8 document are never encoded, even in fail mode.
+	secretValue := SecretValue{
+		Value:     []byte(`{"text": "ok"}`),
+		SecretObj: SecretObject{ObjectName: TEST_OBJECT_NAME, JMESPath: jmesPath[1:2], JmesBinary: jmesBinaryFail},
+	}
+	if jsonSecrets, err := secretValue.getJsonSecrets(); err != nil || string(jsonSecrets[0].Value) != "ok" {
+		t.Errorf("expected the valid string as is, got %v", err)
+	}
+}
+
+func TestJMESPathEncoding(t *testing.T) {
+	secretValue := SecretValue{
+		Value: []byte("{\"cert\": \"-----BEGIN-----\", \"user\": \"  admin \\n\", \"raw\": \"\xff\xfe\"}"),
+		SecretObj: SecretObject{
+			ObjectName: TEST_OBJECT_NAME,
+			JMESPath: []JMESPathObject{
+				{Path: "cert", ObjectAlias: "cert.b64", Encoding: "base64"},
+				{Path: "user", ObjectAlias: "user", TrimSpace: true},
+				{Path: "user", ObjectAlias: "user.hex", TrimSpace: true, Encoding: "hex"},
+				{Path: "user", ObjectAlias: "user.raw", Encoding: "none"},
+				{Path: "raw", ObjectAlias: "raw.hex", Encoding: "hex"},
+				{Path: "raw", ObjectAlias: "raw.b64"},
+			},
+		},
+	}
+	jsonSecrets, err := secretValue.getJsonSecrets()
+	if err != nil {
+		t.Fatalf("getJsonSecrets() error = %v", err)
+	}
+	want := []string{"LS0tLS1CRUdJTi0tLS0t", "admin", "61646d696e", "  admin \n", "fffe", "//4="}
+	for i, w := range want {
+		if string(jsonSecrets[i].Value) != w {
+			t.Errorf("getJsonSecrets()[%d] got = %q, want %q", i, jsonSecrets[i].Value, w)
+		}
+	}
+}
+
+func TestJMESPathEncodingValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"valid", `[{"objectName": "s", "jmesPath": [{"path": "a", "objectAlias": "a", "encoding": "hex", "trimSpace": true}]}]`, false},
+		{"bad-encoding", `[{"objectName": "s", "jmesPath": [{"path": "a", "objectAlias": "a", "encoding": "base32"}]}]`, true},
+		{"merge-encoding", `[{"objectName": "s", "jmesPath": [{"path": "a", "objectAlias": "a", "mergeInto": "m.json", "encoding": "base64"}]}]`, true},
+		{"merge-trim", `[{"objectName": "s", "jmesPath": [{"path": "a", "objectAlias": "a", "mergeInto": "m.json", "trimSpace": true}]}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func FuzzJMESPath(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"username", `{"username": "admin"}`},
+		{"a[-1].b | keys(@)", `{"a": [{"b": {"c": 1}}]}`},
+		{"length(@)", `"\u0000"`},
+		{"[?x > `1`].x", `[{"x": 1}, {"x": 2e308}]`},
+		{"a.*.b[::-1]", `{"a": {"b": [1, 2, 3]}}`},
+		{"*", "\xff\xfe"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, path, document string) {
+		entry := JMESPathObject{Path: path, ObjectAlias: "out"}
+		if err := entry.compile(); err != nil {
+			return
+		}
+		sv := &SecretValue{Value: []byte(document), SecretObj: SecretObject{ObjectName: "s", JMESPath: []JMESPathObject{entry}, mountDir: "/mnt"}}
+		sv.getJsonSecrets()
+	})
+}
@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/metrics"
+)
+
+// SharedCacheSize bounds the values kept by the cache shared by every mount of
+// the provider process, 0 disables the cache.
+var SharedCacheSize = 0
+
+// SharedCacheTTL is how long a fetched value is served from the shared cache.
+var SharedCacheTTL = 30 * time.Second
+
+// A value of the shared cache, or the fetch of it in progress.
+type cachedValue struct {
+	key        string
+	backend    string
+	ready      chan struct{} // Closed once the fetch finished
+	fetching   bool
+	version    string
+	value      []byte
+	secretType string
+	expires    time.Time
+	expiry     *time.Timer // Wipes the value once it expires
+	elem       *list.Element
+}
+
+// Values fetched from KMS and OOS, keyed by sharedCacheKey, so concurrent
+// mounts of the same secret, e.g. the pods of a deployment starting on a node,
+// make a single request. The provider is rebuilt for every mount request, so
+// the cache lives in the process.
+type sharedValueCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedValue
+	lru     list.List // Fetched entries, most recently used first
+}
+
+var sharedValues sharedValueCache
+
+// Key of the object in the shared cache, and whether its value may be shared.
+// The key holds the CacheScope identifying the credentials of the mount, so a
+// value is only shared with mounts allowed to fetch it themselves. Objects with
+// a pending refreshToken must be fetched, so they are not cached.
+func (p *SecretsManagerProvider) sharedCacheKey(secObj *SecretObject) (string, bool) {
+	if SharedCacheSize <= 0 || SharedCacheTTL <= 0 || len(p.CacheScope) == 0 || secObj.refreshPending() {
+		return "", false
+	}
+	requestKey, ok := p.fetchRequestKey(secObj)
+	if !ok {
+		return "", false
+	}
+	return p.CacheScope + "\x00" + requestKey, true
+}
+
+// Key of the request fetching the value of the object, the same for objects
+// whose fetches return the same value, and whether such fetches may share their
+// value. Data keys are generated anew by every fetch and files are read
+// locally, so only KMS secrets and OOS parameters are shared.
+func (p *SecretsManagerProvider) fetchRequestKey(secObj *SecretObject) (string, bool) {
+	objectType := secObj.ObjectType
+	switch objectType {
+	case "":
+		objectType = ObjectTypeKMS
+	case ObjectTypeKMS, ObjectTypeOOS:
+	default:
+		return "", false
+	}
+	region := secObj.getRegion()
+	if len(region) == 0 {
+		region = p.Region
+	}
+	return strings.Join([]string{objectType, clientKey(region, secObj.AssumeRole, secObj.KmsEndpoint),
+		secObj.ObjectName, secObj.ObjectVersion, secObj.ObjectVersionLabel, strconv.FormatBool(secObj.formatsStringList())}, "\x00"), true
+}
+
+// Return the value of the key from the cache, or fetch it with load and cache
+// it. Concurrent callers of a key being fetched wait for that fetch instead of
+// making their own; when it fails they fetch it themselves, so an error, such
+// as the cancelled request of another mount, is never shared.
+func (c *sharedValueCache) fetch(ctx context.Context, key, backend string, secObj *SecretObject,
+	load func() (string, *SecretValue, error)) (string, *SecretValue, error) {
+	for {
+		c.mu.Lock()
+		e, ok := c.entries[key]
+		if ok && !e.fetching && !time.Now().Before(e.expires) {
+			c.remove(e)
+			ok = false
+		}
+		if ok && !e.fetching {
+			c.lru.MoveToFront(e.elem)
+			secret := &SecretValue{Value: append([]byte(nil), e.value...), SecretObj: *secObj, SecretType: e.secretType}
+			version := e.version
+			c.mu.Unlock()
+			metrics.SharedCacheHits.Add(backend, 1)
+			return version, secret, nil
+		}
+		if ok {
+			ready := e.ready
+			c.mu.Unlock()
+			select {
+			case <-ready:
+				continue
+			case <-ctx.Done():
+				return "", nil, ctx.Err()
+			}
+		}
+		e = &cachedValue{key: key, backend: backend, ready: make(chan struct{}), fetching: true}
+		if c.entries == nil {
+			c.entries = make(map[string]*cachedValue)
+		}
+		c.entries[key] = e
+		c.mu.Unlock()
+
+		metrics.SharedCacheMisses.Add(backend, 1)
+		return c.fill(e, load)
+	}
+}
+
+// Fetch the value of a new entry with load and cache it, or drop the entry
+// when load fails or panics, so the callers waiting for it fetch it
+// themselves. The value is wiped once it expires, even if it is never asked
+// for again.
+func (c *sharedValueCache) fill(e *cachedValue, load func() (string, *SecretValue, error)) (version string, secret *SecretValue, err error) {
+	filled := false
+	defer func() {
+		if !filled {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			close(e.ready)
+			delete(c.entries, e.key)
+		}
+	}()
+	version, secret, err = load()
+	if err != nil {
+		return "", nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	filled = true
+	close(e.ready)
+	e.fetching = false
+	e.version = version
+	e.value = append([]byte(nil), secret.Value...)
+	e.secretType = secret.SecretType
+	e.expires = time.Now().Add(SharedCacheTTL)
+	e.expiry = time.AfterFunc(SharedCacheTTL, func() { c.expire(e) })
+	e.elem = c.lru.PushFront(e)
+	for c.lru.Len() > SharedCacheSize {
+		evicted := c.lru.Back().Value.(*cachedValue)
+		c.remove(evicted)
+		metrics.SharedCacheEvictions.Add(evicted.backend, 1)
+	}
+	return version, secret, nil
+}
+
+// Drop an entry whose TTL is over, unless it was already dropped.
+func (c *sharedValueCache) expire(e *cachedValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[e.key] == e && !e.fetching {
+		c.remove(e)
+	}
+}
+
+// Drop a fetched entry, wiping its value. Callers hold the lock.
+func (c *sharedValueCache) remove(e *cachedValue) {
+	if e.expiry != nil {
+		e.expiry.Stop()
+	}
+	delete(c.entries, e.key)
+	c.lru.Remove(e.elem)
+	for i := range e.value {
+		e.value[i] = 0
+	}
+	e.value = nil
+}
+
+// PurgeSharedCache drops and wipes every value of the cache shared by the
+// mounts of the process, e.g. on shutdown.
+func PurgeSharedCache() {
+	sharedValues.purge()
+}
+
+// Drop every fetched value of the cache, wiping them.
+func (c *sharedValueCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		c.remove(elem.Value.(*cachedValue))
+		elem = next
+	}
+}
@@ -0,0 +1,202 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+)
+
+func setupSharedCache(t *testing.T, size int, ttl time.Duration) {
+	oldSize, oldTTL := SharedCacheSize, SharedCacheTTL
+	t.Cleanup(func() {
+		SharedCacheSize, SharedCacheTTL = oldSize, oldTTL
+		sharedValues.purge()
+	})
+	SharedCacheSize, SharedCacheTTL = size, ttl
+	sharedValues.purge()
+}
+
+func TestSharedCache(t *testing.T) {
+	setupFetchTest(t)
+	tests := []struct {
+		name      string
+		size      int
+		scopes    [2]string // CacheScope of the two mounts
+		objects   [2]string // objectName of the two mounts
+		wantCalls int
+	}{
+		{"same-identity", 8, [2]string{"ak1", "ak1"}, [2]string{"db", "db"}, 1},
+		{"other-identity", 8, [2]string{"ak1", "ak2"}, [2]string{"db", "db"}, 2},
+		{"unknown-identity", 8, [2]string{"", ""}, [2]string{"db", "db"}, 2},
+		{"other-secret", 8, [2]string{"ak1", "ak1"}, [2]string{"db", "api"}, 2},
+		{"disabled", 0, [2]string{"ak1", "ak1"}, [2]string{"db", "db"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupSharedCache(t, tt.size, time.Minute)
+			client := &mockKmsClient{getSecretValue: func(req *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+				return kmsSecretResponse("value of "+*req.SecretName, "v1"), nil
+			}}
+			for i := range tt.scopes {
+				p := &SecretsManagerProvider{KmsClient: client, CacheScope: tt.scopes[i]}
+				value, version, err := p.GetSecret(context.Background(), &SecretObject{ObjectName: tt.objects[i]})
+				if err != nil {
+					t.Fatalf("GetSecret() error = %v", err)
+				}
+				if string(value) != "value of "+tt.objects[i] || version != "v1" {
+					t.Errorf("GetSecret() = %q at %s", value, version)
+				}
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, client.calls)
+			}
+		})
+	}
+}
+
+func TestSharedCacheExpiryAndEviction(t *testing.T) {
+	setupFetchTest(t)
+	setupSharedCache(t, 1, time.Minute)
+	client := &mockKmsClient{getSecretValue: func(req *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		return kmsSecretResponse("value", "v1"), nil
+	}}
+	p := &SecretsManagerProvider{KmsClient: client, CacheScope: "ak1"}
+	get := func(name string) {
+		if _, _, err := p.GetSecret(context.Background(), &SecretObject{ObjectName: name}); err != nil {
+			t.Fatalf("GetSecret() error = %v", err)
+		}
+	}
+
+	// A second secret evicts the first one and wipes its value.
+	get("db")
+	evicted := sharedValues.lru.Front().Value.(*cachedValue)
+	value := evicted.value
+	get("api")
+	if sharedValues.lru.Len() != 1 || string(value) != "\x00\x00\x00\x00\x00" {
+		t.Errorf("expected db to be evicted and wiped, got %d entries and %q", sharedValues.lru.Len(), value)
+	}
+	get("db")
+	if client.calls != 3 {
+		t.Errorf("expected the evicted value to be fetched again, got %d calls", client.calls)
+	}
+
+	// An expired value is fetched again.
+	sharedValues.lru.Front().Value.(*cachedValue).expires = time.Now().Add(-time.Second)
+	get("db")
+	if client.calls != 4 {
+		t.Errorf("expected the expired value to be fetched again, got %d calls", client.calls)
+	}
+}
+
+func TestSharedCacheWipesExpiredValues(t *testing.T) {
+	setupSharedCache(t, 8, 10*time.Millisecond)
+	load := func() (string, *SecretValue, error) {
+		return "v1", &SecretValue{Value: []byte("value")}, nil
+	}
+	if _, _, err := sharedValues.fetch(context.Background(), "db", ObjectTypeKMS, &SecretObject{ObjectName: "db"}, load); err != nil {
+		t.Fatalf("fetch() error = %v", err)
+	}
+	sharedValues.mu.Lock()
+	value := sharedValues.entries["db"].value
+	sharedValues.mu.Unlock()
+
+	// The value is wiped once its TTL is over, without another fetch.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sharedValues.mu.Lock()
+		_, cached := sharedValues.entries["db"]
+		sharedValues.mu.Unlock()
+		if !cached {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the expired value to be dropped")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if string(value) != "\x00\x00\x00\x00\x00" {
+		t.Errorf("expected the expired value to be wiped, got %q", value)
+	}
+}
+
+func TestPurgeSharedCache(t *testing.T) {
+	setupSharedCache(t, 8, time.Minute)
+	load := func() (string, *SecretValue, error) {
+		return "v1", &SecretValue{Value: []byte("value")}, nil
+	}
+	if _, _, err := sharedValues.fetch(context.Background(), "db", ObjectTypeKMS, &SecretObject{ObjectName: "db"}, load); err != nil {
+		t.Fatalf("fetch() error = %v", err)
+	}
+	sharedValues.mu.Lock()
+	value := sharedValues.entries["db"].value
+	sharedValues.mu.Unlock()
+
+	PurgeSharedCache()
+	sharedValues.mu.Lock()
+	defer sharedValues.mu.Unlock()
+	if len(sharedValues.entries) != 0 || sharedValues.lru.Len() != 0 {
+		t.Errorf("expected an empty cache, got %d entries", len(sharedValues.entries))
+	}
+	if string(value) != "\x00\x00\x00\x00\x00" {
+		t.Errorf("expected the purged value to be wiped, got %q", value)
+	}
+}
+
+func TestSharedCachePanickingFetch(t *testing.T) {
+	setupSharedCache(t, 8, time.Minute)
+	secObj := &SecretObject{ObjectName: "db"}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected the panic of the fetch to propagate")
+			}
+		}()
+		sharedValues.fetch(context.Background(), "db", ObjectTypeKMS, secObj, func() (string, *SecretValue, error) {
+			panic("load failed")
+		})
+	}()
+
+	// The entry of the panicking fetch is dropped, the next caller fetches again.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, secret, err := sharedValues.fetch(ctx, "db", ObjectTypeKMS, secObj, func() (string, *SecretValue, error) {
+		return "v1", &SecretValue{Value: []byte("value")}, nil
+	})
+	if err != nil || string(secret.Value) != "value" {
+		t.Errorf("expected the value to be fetched again, got %v, %v", secret, err)
+	}
+}
+
+func TestSharedCacheConcurrentFetch(t *testing.T) {
+	setupFetchTest(t)
+	setupSharedCache(t, 8, time.Minute)
+	started, release := make(chan struct{}), make(chan struct{})
+	client := &mockKmsClient{getSecretValue: func(req *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+		close(started)
+		<-release
+		return kmsSecretResponse("value", "v1"), nil
+	}}
+	get := func(errs chan<- error) {
+		p := &SecretsManagerProvider{KmsClient: client, CacheScope: "ak1"}
+		_, _, err := p.GetSecret(context.Background(), &SecretObject{ObjectName: "db"})
+		errs <- err
+	}
+
+	// A mount fetching the secret while another one does waits for its value.
+	errs := make(chan error, 2)
+	go get(errs)
+	<-started
+	go get(errs)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("GetSecret() error = %v", err)
+		}
+	}
+	if client.calls != 1 {
+		t.Errorf("expected a single call, got %d", client.calls)
+	}
+}
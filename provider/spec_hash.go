@@ -0,0 +1,28 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// SpecHash returns a stable hex digest of the YAML fields of a list of objects,
+// e.g. to detect that the effective spec of a mount drifted from the desired
+// one. The order of the objects does not matter, and internal state such as
+// the mount directory is not part of the digest. Objects should come from
+// NewSecretObjectList so that both sides are expanded and normalized alike.
+func SpecHash(objects []*SecretObject) (string, error) {
+	encoded := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return "", err
+		}
+		encoded = append(encoded, string(b))
+	}
+	sort.Strings(encoded)
+	sum := sha256.Sum256([]byte(strings.Join(encoded, "\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
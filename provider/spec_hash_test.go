@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestSpecHash(t *testing.T) {
+	base := `[{"objectName": "a", "jmesPath": [{"path": "user", "objectAlias": "user"}]}, {"objectName": "b", "objectType": "oos"}]`
+	tests := []struct {
+		name     string
+		spec     string
+		mountDir string
+		wantSame bool
+	}{
+		{"same", base, "/mnt", true},
+		{"reordered", `[{"objectName": "b", "objectType": "oos"}, {"objectName": "a", "jmesPath": [{"path": "user", "objectAlias": "user"}]}]`, "/mnt", true},
+		{"other-mount-dir", base, "/other", true},
+		{"alias", `[{"objectName": "a", "objectAlias": "x", "jmesPath": [{"path": "user", "objectAlias": "user"}]}, {"objectName": "b", "objectType": "oos"}]`, "/mnt", false},
+		{"jmes-path", `[{"objectName": "a", "jmesPath": [{"path": "password", "objectAlias": "user"}]}, {"objectName": "b", "objectType": "oos"}]`, "/mnt", false},
+		{"removed", `[{"objectName": "a", "jmesPath": [{"path": "user", "objectAlias": "user"}]}]`, "/mnt", false},
+	}
+	objects, err := NewSecretObjectList("/mnt", "", "", base, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	want, err := SpecHash(objects)
+	if err != nil {
+		t.Fatalf("SpecHash() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewSecretObjectList(tt.mountDir, "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			got, err := SpecHash(objects)
+			if err != nil {
+				t.Fatalf("SpecHash() error = %v", err)
+			}
+			if (got == want) != tt.wantSame {
+				t.Errorf("SpecHash() = %s, base %s, wantSame %t", got, want, tt.wantSame)
+			}
+		})
+	}
+}
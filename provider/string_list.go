@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// Type of the OOS parameters holding a comma separated list.
+const oosTypeStringList = "StringList"
+
+// Values of stringListFormat.
+const (
+	stringListRaw   = "raw"   // The comma separated string, as stored
+	stringListSplit = "split" // One <file name>.<index> file per element
+	stringListJSON  = "json"  // A JSON array of the elements
+)
+
+// Check the stringListFormat of the object spec.
+func (s *SecretObject) validateStringListFormat() error {
+	switch s.StringListFormat {
+	case "", stringListRaw:
+		return nil
+	case stringListSplit, stringListJSON:
+	default:
+		return fmt.Errorf("Invalid stringListFormat %q for object %s, expected %q, %q or %q", s.StringListFormat, s.ObjectName, stringListRaw, stringListSplit, stringListJSON)
+	}
+	if s.ObjectType != ObjectTypeOOS {
+		return fmt.Errorf("stringListFormat is only supported for oos parameters: %s", s.ObjectName)
+	}
+	if s.IncludePreviousVersion {
+		return fmt.Errorf("stringListFormat can not be used with includePreviousVersion: %s", s.ObjectName)
+	}
+	if s.StringListFormat == stringListSplit && len(s.JMESPath) > 0 {
+		return fmt.Errorf("stringListFormat %s can not be used with jmesPath: %s", stringListSplit, s.ObjectName)
+	}
+	return nil
+}
+
+// Whether the value of the object is reformatted, which requires a StringList parameter.
+func (s *SecretObject) formatsStringList() bool {
+	return s.StringListFormat == stringListSplit || s.StringListFormat == stringListJSON
+}
+
+// Check the type of a fetched parameter against the stringListFormat of its object.
+func checkStringListType(secObj *SecretObject, parameterType *string) error {
+	if secObj.formatsStringList() && tea.StringValue(parameterType) != oosTypeStringList {
+		return fmt.Errorf("stringListFormat %s of parameter %s requires a %s parameter, got %q", secObj.StringListFormat, secObj.ObjectName, oosTypeStringList, tea.StringValue(parameterType))
+	}
+	return nil
+}
+
+// Rewrite a fetched StringList value as a JSON array for the json format.
+func (sv *SecretValue) formatStringList() error {
+	if sv.SecretObj.StringListFormat != stringListJSON {
+		return nil
+	}
+	encoded, err := json.Marshal(strings.Split(string(sv.Value), ","))
+	if err != nil {
+		return err
+	}
+	sv.Value = encoded
+	return nil
+}
+
+// Build the <file name>.<index> files of the elements of a StringList value
+// for the split format, or nil for the other formats.
+func (sv *SecretValue) stringListSecrets() ([]*SecretValue, error) {
+	if sv.SecretObj.StringListFormat != stringListSplit {
+		return nil, nil
+	}
+	elements := strings.Split(string(sv.Value), ",")
+	if MaxFilesPerObject > 0 && len(elements) > MaxFilesPerObject {
+		return nil, fmt.Errorf("StringList parameter %s has %d elements, more than the limit of %d files per object", sv.SecretObj.ObjectName, len(elements), MaxFilesPerObject)
+	}
+	values := make([]*SecretValue, 0, len(elements))
+	for i, element := range elements {
+		values = append(values, &SecretValue{
+			Value: []byte(element),
+			SecretObj: SecretObject{
+				ObjectAlias: sv.SecretObj.GetFileName() + "." + strconv.Itoa(i),
+				translate:   sv.SecretObj.translate,
+				mountDir:    sv.SecretObj.mountDir,
+			},
+		})
+	}
+	return values, nil
+}
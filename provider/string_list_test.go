@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	oos "github.com/alibabacloud-go/oos-20190601/v4/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// An OOS client for a parameter of parameterType holding value.
+func newTypedOosClient(parameterType, value string) *mockOosClient {
+	return &mockOosClient{getSecretParameter: func(*oos.GetSecretParameterRequest) (*oos.GetSecretParameterResponse, error) {
+		return &oos.GetSecretParameterResponse{Body: &oos.GetSecretParameterResponseBody{
+			Parameter: &oos.GetSecretParameterResponseBodyParameter{
+				Value:            tea.String(value),
+				Type:             tea.String(parameterType),
+				ParameterVersion: tea.Int32(1),
+			},
+		}}, nil
+	}}
+}
+
+func TestGetSecretValuesStringList(t *testing.T) {
+	setupFetchTest(t)
+	tests := []struct {
+		name          string
+		parameterType string
+		spec          string
+		wantFiles     map[string]string
+		wantErr       bool
+	}{
+		{"default", oosTypeStringList, `[{"objectName": "hosts", "objectType": "oos"}]`,
+			map[string]string{"hosts": "a,b,c"}, false},
+		{"raw", oosTypeStringList, `[{"objectName": "hosts", "objectType": "oos", "stringListFormat": "raw"}]`,
+			map[string]string{"hosts": "a,b,c"}, false},
+		{"split", oosTypeStringList, `[{"objectName": "hosts", "objectType": "oos", "objectAlias": "h", "stringListFormat": "split"}]`,
+			map[string]string{"h.0": "a", "h.1": "b", "h.2": "c"}, false},
+		{"json", oosTypeStringList, `[{"objectName": "hosts", "objectType": "oos", "stringListFormat": "json", "jmesPath": [{"path": "[1]", "objectAlias": "second"}]}]`,
+			map[string]string{"hosts": `["a","b","c"]`, "second": "b"}, false},
+		{"not-a-list", "Secret", `[{"objectName": "hosts", "objectType": "oos", "stringListFormat": "split"}]`, nil, true},
+		{"raw-not-a-list", "Secret", `[{"objectName": "hosts", "objectType": "oos", "stringListFormat": "raw"}]`,
+			map[string]string{"hosts": "a,b,c"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &SecretsManagerProvider{OosClient: newTypedOosClient(tt.parameterType, "a,b,c"), FS: newMemFileSystem()}
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			curMap := make(map[string]*v1alpha1.ObjectVersion)
+			values, err := p.GetSecretValues(context.Background(), objects, curMap)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSecretValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(values) != len(tt.wantFiles) {
+				t.Fatalf("GetSecretValues() returned %d values, want %d", len(values), len(tt.wantFiles))
+			}
+			for _, v := range values {
+				name := v.SecretObj.GetFileName()
+				if want, ok := tt.wantFiles[name]; !ok || string(v.Value) != want {
+					t.Errorf("file %s = %q, want %q", name, v.Value, want)
+				}
+				if curMap[name] == nil {
+					t.Errorf("no version recorded for %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestNewSecretObjectListStringListFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"split", `[{"objectName": "p", "objectType": "oos", "stringListFormat": "split"}]`, false},
+		{"invalid", `[{"objectName": "p", "objectType": "oos", "stringListFormat": "lines"}]`, true},
+		{"kms", `[{"objectName": "s", "stringListFormat": "json"}]`, true},
+		{"split-with-jmes", `[{"objectName": "p", "objectType": "oos", "stringListFormat": "split", "jmesPath": [{"path": "a", "objectAlias": "a"}]}]`, true},
+		{"previous-version", `[{"objectName": "p", "objectType": "oos", "stringListFormat": "json", "includePreviousVersion": true}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
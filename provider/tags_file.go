@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// Values of tagsFormat.
+const (
+	tagsFormatJSON = "json" // A JSON object of the tags
+	tagsFormatEnv  = "env"  // One KEY=VALUE line per tag
+)
+
+// Check the tagsFile and tagsFormat of the object spec.
+func (s *SecretObject) validateTagsFile() error {
+	if len(s.TagsFile) == 0 {
+		if len(s.TagsFormat) > 0 {
+			return fmt.Errorf("tagsFormat requires a tagsFile: %s", s.ObjectName)
+		}
+		return nil
+	}
+	if !s.isKMS() {
+		return fmt.Errorf("tagsFile is only supported for kms secrets: %s", s.ObjectName)
+	}
+	switch s.TagsFormat {
+	case "", tagsFormatJSON, tagsFormatEnv:
+	default:
+		return fmt.Errorf("Invalid tagsFormat %q for object %s, expected %q or %q", s.TagsFormat, s.ObjectName, tagsFormatJSON, tagsFormatEnv)
+	}
+	tagsObj := s.getTagsFileSecretObject()
+	if isDirectoryName(tagsObj.GetFileName()) {
+		return fmt.Errorf("File name of tagsFile %q is empty or a directory", s.TagsFile)
+	}
+	if badPathRE.MatchString(tagsObj.GetFileName()) {
+		return fmt.Errorf("path can not contain ../: %s", s.TagsFile)
+	}
+	return nil
+}
+
+func (p *SecretObject) getTagsFileSecretObject() (d SecretObject) {
+	return SecretObject{
+		ObjectAlias:  p.TagsFile,
+		LeadingSlash: p.LeadingSlash,
+		translate:    p.translate,
+		mountDir:     p.mountDir,
+	}
+}
+
+// Build the tags file of a KMS secret from its DescribeSecret tags. A reloaded
+// secret keeps the tags file that is already mounted, like its other files.
+func (p *SecretsManagerProvider) tagsSecretFor(ctx context.Context, secret *SecretValue, reloaded bool) (*SecretValue, error) {
+	tagsObj := secret.SecretObj.getTagsFileSecretObject()
+	if reloaded {
+		if data, err := p.readMounted(&tagsObj); err == nil {
+			return &SecretValue{Value: data, SecretObj: tagsObj}, nil
+		}
+	}
+	response, err := p.describeSecret(ctx, &secret.SecretObj, true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed fetching the tags of secret %s: %w", secret.SecretObj.ObjectName, err)
+	}
+	tags := make(map[string]string)
+	if response.Body != nil && response.Body.Tags != nil {
+		for _, tag := range response.Body.Tags.Tag {
+			tags[tea.StringValue(tag.TagKey)] = tea.StringValue(tag.TagValue)
+		}
+	}
+	value, err := formatTags(tags, secret.SecretObj.TagsFormat)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid tags of secret %s: %+v", secret.SecretObj.ObjectName, err)
+	}
+	return &SecretValue{Value: value, SecretObj: tagsObj}, nil
+}
+
+// Write tags as a JSON object, or as KEY=VALUE lines sorted by key for the env
+// format. A secret without tags gives an empty object or file.
+func formatTags(tags map[string]string, format string) ([]byte, error) {
+	if format != tagsFormatEnv {
+		return json.MarshalIndent(tags, "", "  ") // Keys are marshalled in sorted order
+	}
+	lines := make([]string, 0, len(tags))
+	keys := make(map[string]string, len(tags))
+	for tagKey, tagValue := range tags {
+		key, err := envKey(tagKey, false)
+		if err != nil {
+			return nil, fmt.Errorf("tag %s can not be converted to an environment variable name", tagKey)
+		}
+		if other, ok := keys[key]; ok {
+			return nil, fmt.Errorf("tags %s and %s both map to %s", other, tagKey, key)
+		}
+		keys[key] = tagKey
+		lines = append(lines, key+"="+envValue(tagValue)+"\n")
+	}
+	sort.Strings(lines)
+	return []byte(strings.Join(lines, "")), nil
+}
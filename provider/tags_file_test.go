@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func describeTagsResponse(tags map[string]string) *kms.DescribeSecretResponse {
+	body := &kms.DescribeSecretResponseBody{}
+	if tags != nil {
+		body.Tags = &kms.DescribeSecretResponseBodyTags{}
+		for k, v := range tags {
+			body.Tags.Tag = append(body.Tags.Tag, &kms.DescribeSecretResponseBodyTagsTag{TagKey: tea.String(k), TagValue: tea.String(v)})
+		}
+	}
+	return &kms.DescribeSecretResponse{Body: body}
+}
+
+func TestTagsFile(t *testing.T) {
+	setupFetchTest(t)
+	tests := []struct {
+		name string
+		spec string
+		tags map[string]string
+		want string
+	}{
+		{"json", `[{"objectName": "db", "tagsFile": "db.tags"}]`, map[string]string{"owner": "payments", "env": "prod"}, "{\n  \"env\": \"prod\",\n  \"owner\": \"payments\"\n}"},
+		{"env", `[{"objectName": "db", "tagsFile": "db.tags", "tagsFormat": "env"}]`, map[string]string{"owner": "pay ments", "cost-center": "42"}, "COST_CENTER=42\nOWNER=\"pay ments\"\n"},
+		{"no-tags-json", `[{"objectName": "db", "tagsFile": "db.tags"}]`, nil, "{}"},
+		{"no-tags-env", `[{"objectName": "db", "tagsFile": "db.tags", "tagsFormat": "env"}]`, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetchTags string
+			client := &mockKmsClient{
+				getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+					return kmsSecretResponse("value", "v1"), nil
+				},
+				describeSecret: func(request *kms.DescribeSecretRequest) (*kms.DescribeSecretResponse, error) {
+					fetchTags = tea.StringValue(request.FetchTags)
+					return describeTagsResponse(tt.tags), nil
+				},
+			}
+			p := &SecretsManagerProvider{KmsClient: client, FS: newMemFileSystem()}
+			objects, err := NewSecretObjectList("/mnt", "", "", tt.spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			values, err := p.GetSecretValues(context.Background(), objects, map[string]*v1alpha1.ObjectVersion{})
+			if err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			if len(values) != 2 || values[1].SecretObj.GetFileName() != "db.tags" || string(values[1].Value) != tt.want {
+				t.Fatalf("expected the value and the tags file %q, got %d values", tt.want, len(values))
+			}
+			if fetchTags != "true" {
+				t.Errorf("expected DescribeSecret to be called with FetchTags, got %q", fetchTags)
+			}
+		})
+	}
+}
+
+func TestTagsFileReloaded(t *testing.T) {
+	setupFetchTest(t)
+	client := &mockKmsClient{describeSecret: func(*kms.DescribeSecretRequest) (*kms.DescribeSecretResponse, error) {
+		t.Fatal("expected the mounted tags file to be reloaded")
+		return nil, nil
+	}}
+	fs := newMemFileSystem()
+	fs.WriteFile("/mnt/db", []byte("value"), 0644)
+	fs.WriteFile("/mnt/db.tags", []byte(`{"env": "prod"}`), 0644)
+	p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+	objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db", "objectVersion": "v1", "tagsFile": "db.tags"}]`, PodMetadata{})
+	if err != nil {
+		t.Fatalf("NewSecretObjectList() error = %v", err)
+	}
+	curMap := map[string]*v1alpha1.ObjectVersion{"db": {Id: "db", Version: "v1"}}
+	values, err := p.GetSecretValues(context.Background(), objects, curMap)
+	if err != nil {
+		t.Fatalf("GetSecretValues() error = %v", err)
+	}
+	if len(values) != 2 || string(values[1].Value) != `{"env": "prod"}` || client.calls != 0 {
+		t.Errorf("expected the mounted tags file without API calls, got %d values after %d calls", len(values), client.calls)
+	}
+}
+
+func TestNewSecretObjectListTagsFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		translate string
+		spec      string
+		wantErr   bool
+	}{
+		{"json", "", `[{"objectName": "a", "tagsFile": "a.tags"}]`, false},
+		{"env", "", `[{"objectName": "a", "tagsFile": "a.env", "tagsFormat": "env"}]`, false},
+		{"format-without-file", "", `[{"objectName": "a", "tagsFormat": "env"}]`, true},
+		{"unknown-format", "", `[{"objectName": "a", "tagsFile": "a.tags", "tagsFormat": "yaml"}]`, true},
+		{"oos", "", `[{"objectName": "a", "objectType": "oos", "tagsFile": "a.tags"}]`, true},
+		{"parent-path", "False", `[{"objectName": "a", "tagsFile": "../a.tags"}]`, true},
+		{"collides-with-object", "", `[{"objectName": "a", "tagsFile": "b"}, {"objectName": "b"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretObjectList("/mnt", tt.translate, "", tt.spec, PodMetadata{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewSecretObjectList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
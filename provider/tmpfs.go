@@ -0,0 +1,38 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// File system magic numbers of memory backed file systems, see statfs(2).
+const (
+	tmpfsMagic = 0x01021994
+	ramfsMagic = 0x858458f6
+)
+
+var errTmpfsCheckUnsupported = errors.New("file system type check is not supported on this platform")
+
+// Return the file system type of a directory, replaced in tests.
+var fileSystemType = statfsType
+
+// VerifyTmpfs checks that dir is backed by tmpfs or ramfs, so secrets written
+// to it never reach persistent storage.
+func VerifyTmpfs(dir string) error {
+	fsType, err := fileSystemType(dir)
+	if err != nil {
+		return fmt.Errorf("Failed to check the file system of mount directory %s: %w", dir, err)
+	}
+	if fsType != tmpfsMagic && fsType != ramfsMagic {
+		return fmt.Errorf("Mount directory %s is not backed by tmpfs or ramfs (file system type 0x%x)", dir, fsType)
+	}
+	return nil
+}
+
+// CheckTmpfsSupport reports whether VerifyTmpfs can work on this node, for
+// the startup checks of the provider.
+func CheckTmpfsSupport() error {
+	_, err := fileSystemType(os.TempDir())
+	return err
+}
@@ -0,0 +1,11 @@
+package provider
+
+import "syscall"
+
+func statfsType(dir string) (uint32, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint32(st.Type), nil
+}
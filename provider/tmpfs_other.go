@@ -0,0 +1,7 @@
+//go:build !linux
+
+package provider
+
+func statfsType(dir string) (uint32, error) {
+	return 0, errTmpfsCheckUnsupported
+}
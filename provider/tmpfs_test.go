@@ -0,0 +1,32 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerifyTmpfs(t *testing.T) {
+	oldType := fileSystemType
+	defer func() { fileSystemType = oldType }()
+
+	tests := []struct {
+		name    string
+		fsType  uint32
+		err     error
+		wantErr bool
+	}{
+		{"tmpfs", tmpfsMagic, nil, false},
+		{"ramfs", ramfsMagic, nil, false},
+		{"ext4", 0xef53, nil, true},
+		{"statfs-error", 0, errors.New("no such file or directory"), true},
+		{"unsupported", 0, errTmpfsCheckUnsupported, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileSystemType = func(string) (uint32, error) { return tt.fsType, tt.err }
+			if err := VerifyTmpfs("/mnt"); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyTmpfs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// Key of the resolved version stages of a KMS secret, the secret as reached
+// through the client of the object.
+func versionStagesKey(secObj *SecretObject) string {
+	return clientKey(secObj.getRegion(), secObj.AssumeRole, secObj.KmsEndpoint) + "\x00" + secObj.ObjectName
+}
+
+// Report whether isCurrent asks KMS for the version stage of the object.
+func (p *SecretsManagerProvider) looksUpVersion(secObj *SecretObject, curMap map[string]*v1alpha1.ObjectVersion) bool {
+	return (CheckCurrentVersion || p.checkVersions) && secObj.isKMS() && curMap[secObj.GetFileName()] != nil &&
+		secObj.emitsRaw() && !secObj.AlwaysLatest && !secObj.FollowIndirection && len(secObj.ObjectVersion) == 0 && !secObj.refreshPending() && !secObj.expired(curMap, time.Now()) && !secObj.missingSecretType(curMap)
+}
+
+// Resolve the version stages of the KMS secrets whose current version is
+// looked up for several objects of the sync, e.g. the same secret mounted at
+// different version labels, with a single listing of the versions of each
+// secret instead of one per object. KMS has no API describing several secrets
+// at once, so secrets looked up for a single object keep their own lookup,
+// which stops at the first page holding the stage. A failed listing is logged
+// and its objects fall back to their own lookups.
+func (p *SecretsManagerProvider) preresolveVersions(ctx context.Context, secretObjs []*SecretObject, curMap map[string]*v1alpha1.ObjectVersion) {
+	p.resolvedStages = nil
+	var keys []string // In the order of the objects
+	lookups := make(map[string]int)
+	shared := make(map[string]*SecretObject)
+	for _, secObj := range secretObjs {
+		if !p.looksUpVersion(secObj, curMap) {
+			continue
+		}
+		key := versionStagesKey(secObj)
+		if lookups[key] == 0 {
+			keys = append(keys, key)
+			shared[key] = secObj
+		}
+		lookups[key]++
+	}
+	for _, key := range keys {
+		if lookups[key] < 2 {
+			continue
+		}
+		secObj := shared[key]
+		stages := make(map[string]string)
+		err := p.scanVersionStages(ctx, secObj, func(versionId, stage string) bool {
+			stages[stage] = versionId
+			return true
+		})
+		if err != nil {
+			klog.Warningf("failed to resolve the versions of %s, looking them up per object: %s", secObj.ObjectName, err.Error())
+			continue
+		}
+		if p.resolvedStages == nil {
+			p.resolvedStages = make(map[string]map[string]string)
+		}
+		p.resolvedStages[key] = stages
+	}
+}
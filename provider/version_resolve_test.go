@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// A listing of the versions of a secret, each in the stages given for it.
+func kmsVersionStagesResponse(stages map[string][]string) *kms.ListSecretVersionIdsResponse {
+	body := &kms.ListSecretVersionIdsResponseBody{
+		TotalCount: tea.Int32(int32(len(stages))),
+		VersionIds: &kms.ListSecretVersionIdsResponseBodyVersionIds{},
+	}
+	for version, versionStages := range stages {
+		v := &kms.ListSecretVersionIdsResponseBodyVersionIdsVersionId{
+			VersionId:     tea.String(version),
+			VersionStages: &kms.ListSecretVersionIdsResponseBodyVersionIdsVersionIdVersionStages{},
+		}
+		for _, stage := range versionStages {
+			v.VersionStages.VersionStage = append(v.VersionStages.VersionStage, tea.String(stage))
+		}
+		body.VersionIds.VersionId = append(body.VersionIds.VersionId, v)
+	}
+	return &kms.ListSecretVersionIdsResponse{Body: body}
+}
+
+func TestPreresolveVersions(t *testing.T) {
+	setupFetchTest(t)
+	oldCheck := CheckCurrentVersion
+	t.Cleanup(func() { CheckCurrentVersion = oldCheck })
+	CheckCurrentVersion = true
+
+	spec := `
+- objectName: "db"
+  objectAlias: "current"
+- objectName: "db"
+  objectAlias: "previous"
+  objectVersionLabel: "ACSPrevious"
+- objectName: "db"
+  objectAlias: "blue"
+  objectVersionLabel: "blue"
+- objectName: "api"
+`
+	tests := []struct {
+		name        string
+		listErrors  int // Listings failing before the first success
+		wantListed  map[string]int
+		wantFetched int
+	}{
+		{"batched", 0, map[string]int{"db": 1, "api": 1}, 0},
+		{"fallback", 1, map[string]int{"db": 4, "api": 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed := make(map[string]int)
+			listErrors := tt.listErrors
+			client := &mockKmsClient{
+				listSecretVersionIds: func(request *kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error) {
+					name := tea.StringValue(request.SecretName)
+					listed[name]++
+					if listErrors > 0 {
+						listErrors--
+						return nil, errors.New("listing failed")
+					}
+					if name == "api" {
+						return kmsCurrentVersionResponse("a1"), nil
+					}
+					return kmsVersionStagesResponse(map[string][]string{"v3": {KMS_CURRENT_VERSION_STAGE, "blue"}, "v2": {"ACSPrevious"}}), nil
+				},
+				getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+					return kmsSecretResponse("value", "v3"), nil
+				},
+			}
+			fs := newMemFileSystem()
+			for _, name := range []string{"current", "previous", "blue", "api"} {
+				fs.WriteFile("/mnt/"+name, []byte("value"), 0644)
+			}
+			p := &SecretsManagerProvider{KmsClient: client, FS: fs}
+			objects, err := NewSecretObjectList("/mnt", "", "", spec, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			curMap := map[string]*v1alpha1.ObjectVersion{
+				"current":  {Id: "current", Version: "v3"},
+				"previous": {Id: "previous", Version: "v2"},
+				"blue":     {Id: "blue", Version: "v3"},
+				"api":      {Id: "api", Version: "a1"},
+			}
+			if _, err = p.GetSecretValues(context.Background(), objects, curMap); err != nil {
+				t.Fatalf("GetSecretValues() error = %v", err)
+			}
+			fetched := client.calls - listed["db"] - listed["api"]
+			if listed["db"] != tt.wantListed["db"] || listed["api"] != tt.wantListed["api"] || fetched != tt.wantFetched {
+				t.Errorf("listed %v and fetched %d values, want %v and %d", listed, fetched, tt.wantListed, tt.wantFetched)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// VerifyVersionStages makes GetSecretValues check that the objectVersionLabel
+// of a KMS secret names one of its version stages before fetching its value,
+// so a mistyped label fails with the stages the secret has. KMS DescribeSecret
+// does not report the stages, so it costs a ListSecretVersionIds call per
+// fetch of such an object, unless its stages were already resolved.
+var VerifyVersionStages = false
+
+// Report whether the version label of the object is checked before its fetch.
+func (s *SecretObject) verifiesVersionStage() bool {
+	return VerifyVersionStages && s.isKMS() && len(s.ObjectVersionLabel) > 0
+}
+
+// Check that the objectVersionLabel of the object is a version stage of its
+// secret. Errors listing the stages, such as a missing secret or a policy not
+// granting kms:ListSecretVersionIds, are left for the value fetch to report.
+func (p *SecretsManagerProvider) verifyVersionStage(ctx context.Context, secObj *SecretObject) error {
+	stage := secObj.ObjectVersionLabel
+	stages, ok := p.resolvedStages[versionStagesKey(secObj)]
+	if !ok {
+		stages = make(map[string]string)
+		err := p.scanVersionStages(ctx, secObj, func(versionId, s string) bool {
+			stages[s] = versionId
+			return s != stage
+		})
+		if err != nil {
+			klog.Warningf("failed to verify version stage %s of secret %s: %s", stage, secObj.ObjectName, err.Error())
+			return nil
+		}
+	}
+	if _, found := stages[stage]; found {
+		return nil
+	}
+	available := make([]string, 0, len(stages))
+	for s := range stages {
+		available = append(available, s)
+	}
+	sort.Strings(available)
+	return fmt.Errorf("version stage %q not found for secret %q (available: %s)", stage, secObj.ObjectName, strings.Join(available, ", "))
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestVerifyVersionStages(t *testing.T) {
+	setupFetchTest(t)
+	oldVerify := VerifyVersionStages
+	t.Cleanup(func() { VerifyVersionStages = oldVerify })
+	VerifyVersionStages = true
+	stages := &kms.ListSecretVersionIdsResponse{Body: &kms.ListSecretVersionIdsResponseBody{
+		TotalCount: tea.Int32(2),
+		VersionIds: &kms.ListSecretVersionIdsResponseBodyVersionIds{
+			VersionId: []*kms.ListSecretVersionIdsResponseBodyVersionIdsVersionId{{
+				VersionId:     tea.String("v2"),
+				VersionStages: &kms.ListSecretVersionIdsResponseBodyVersionIdsVersionIdVersionStages{VersionStage: []*string{tea.String("ACSCurrent")}},
+			}, {
+				VersionId:     tea.String("v1"),
+				VersionStages: &kms.ListSecretVersionIdsResponseBodyVersionIdsVersionIdVersionStages{VersionStage: []*string{tea.String("ACSPrevious")}},
+			}},
+		},
+	}}
+	tests := []struct {
+		name      string
+		label     string
+		listErr   error
+		wantErr   string
+		wantFetch bool
+	}{
+		{"existing-stage", "ACSPrevious", nil, "", true},
+		{"missing-stage", "ACSCurent", nil, `version stage "ACSCurent" not found for secret "db" (available: ACSCurrent, ACSPrevious)`, false},
+		{"listing-fails", "ACSCurent", errors.New("Forbidden.NoPermission"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched := false
+			client := &mockKmsClient{
+				getSecretValue: func(*kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+					fetched = true
+					return kmsSecretResponse("value", "v1"), nil
+				},
+				listSecretVersionIds: func(*kms.ListSecretVersionIdsRequest) (*kms.ListSecretVersionIdsResponse, error) {
+					return stages, tt.listErr
+				},
+			}
+			p := &SecretsManagerProvider{KmsClient: client, MaxRetries: new(int)}
+			objects, err := NewSecretObjectList("/mnt", "", "", `[{"objectName": "db", "objectVersionLabel": "`+tt.label+`"}]`, PodMetadata{})
+			if err != nil {
+				t.Fatalf("NewSecretObjectList() error = %v", err)
+			}
+			_, err = p.GetSecretValues(context.Background(), objects, make(map[string]*v1alpha1.ObjectVersion))
+			if len(tt.wantErr) > 0 && (err == nil || err.Error() != tt.wantErr) || len(tt.wantErr) == 0 && err != nil {
+				t.Errorf("GetSecretValues() error = %v, want %q", err, tt.wantErr)
+			}
+			if fetched != tt.wantFetch {
+				t.Errorf("expected fetch %v, got %v", tt.wantFetch, fetched)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"net"
 	"net/http"
@@ -27,6 +28,7 @@ func (h *HealthZ) Serve() {
 
 	serveMux := http.NewServeMux()
 	serveMux.HandleFunc(h.HealthCheckURL.EscapedPath(), h.ServeHTTP)
+	serveMux.Handle("/debug/vars", expvar.Handler()) // Retry and circuit breaker metrics
 	if err := http.ListenAndServe(h.HealthCheckURL.Host, serveMux); err != nil && errors.Is(err, http.ErrServerClosed) {
 		klog.ErrorS(err, "failed to start health check server")
 		os.Exit(1)
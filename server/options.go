@@ -0,0 +1,153 @@
+package server
+
+import (
+	"time"
+
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/auth"
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/provider"
+	"github.com/aliyun/credentials-go/credentials"
+)
+
+// KmsClientFactory builds a KMS client for a region with the credentials of a
+// mount. The endpoint is the kmsEndpoint of the object or the RegionEndpointMap
+// entry of the region, and empty to use the default endpoint.
+type KmsClientFactory func(cred credentials.Credential, region, endpoint string) (provider.KmsAPI, error)
+
+// OosClientFactory builds an OOS client for a region with the credentials of a mount.
+type OosClientFactory func(cred credentials.Credential, region string) (provider.OosAPI, error)
+
+// ServerOption configures a CSIDriverProviderServer built by NewServer.
+type ServerOption func(*CSIDriverProviderServer)
+
+// WithKmsClient uses a pre-built KMS client for every KMS and data key object,
+// regardless of its region, assumeRole or endpoint override, which are all
+// ignored. It wins over WithKmsClientFactory, and mounts holding only objects
+// served by injected clients do not resolve pod credentials.
+func WithKmsClient(client provider.KmsAPI) ServerOption {
+	return func(s *CSIDriverProviderServer) {
+		s.kmsClient = client
+	}
+}
+
+// WithOosClient uses a pre-built OOS client for every OOS object, regardless of
+// its region or assumeRole. It wins over WithOosClientFactory.
+func WithOosClient(client provider.OosAPI) ServerOption {
+	return func(s *CSIDriverProviderServer) {
+		s.oosClient = client
+	}
+}
+
+// WithKmsClientFactory replaces the factory building KMS clients, e.g. to use a
+// custom transport or proxy. The factory is passed the endpoint override that
+// applies, if any, and decides how to honor it. Assumed role credentials are
+// resolved before it is called.
+func WithKmsClientFactory(f KmsClientFactory) ServerOption {
+	return func(s *CSIDriverProviderServer) {
+		s.newKmsClient = f
+	}
+}
+
+// WithOosClientFactory replaces the factory building OOS clients.
+func WithOosClientFactory(f OosClientFactory) ServerOption {
+	return func(s *CSIDriverProviderServer) {
+		s.newOosClient = f
+	}
+}
+
+// WithSecretProcessor registers a processor run on every fetched value before
+// it is written. Processors run in the order they are registered.
+func WithSecretProcessor(processor provider.SecretProcessor) ServerOption {
+	return func(s *CSIDriverProviderServer) {
+		s.processors = append(s.processors, processor)
+	}
+}
+
+// WithRetryPolicy sets the retry and timeout settings of the objects that do
+// not set their own maxRetries, retryInterval or fetchTimeout. Zero durations
+// keep the provider defaults.
+func WithRetryPolicy(maxRetries int, retryInterval, fetchTimeout time.Duration) ServerOption {
+	return func(s *CSIDriverProviderServer) {
+		s.maxRetries = &maxRetries
+		s.retryInterval = retryInterval
+		s.fetchTimeout = fetchTimeout
+	}
+}
+
+// Return the KMS client factory of the server, newKmsClient by default.
+func (s *CSIDriverProviderServer) kmsFactory() KmsClientFactory {
+	if s.newKmsClient != nil {
+		return s.newKmsClient
+	}
+	return func(cred credentials.Credential, region, endpoint string) (provider.KmsAPI, error) {
+		c, err := newKmsClient(cred, region, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// Return the OOS client factory of the server, newOosClient by default.
+func (s *CSIDriverProviderServer) oosFactory() OosClientFactory {
+	if s.newOosClient != nil {
+		return s.newOosClient
+	}
+	return func(cred credentials.Credential, region string) (provider.OosAPI, error) {
+		c, err := newOosClient(cred, region)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// Wire the clients of a mount in region into the provider: the injected
+// clients when set, else clients built by the factories with cred.
+func (s *CSIDriverProviderServer) setClients(p *provider.SecretsManagerProvider, cred credentials.Credential, region string, useKms, useOos bool) (err error) {
+	if s.kmsClient != nil {
+		p.KmsClient = s.kmsClient
+		p.NewKmsClient = func(string, string) (provider.KmsAPI, error) { return s.kmsClient, nil }
+		p.NewRoleKmsClient = func(string, string, string) (provider.KmsAPI, error) { return s.kmsClient, nil }
+	} else {
+		newKms := s.kmsFactory()
+		if useKms {
+			if p.KmsClient, err = newKms(cred, region, RegionEndpointMap[region]); err != nil {
+				return err
+			}
+		}
+		p.NewKmsClient = func(r, endpoint string) (provider.KmsAPI, error) {
+			return newKms(cred, r, endpoint)
+		}
+		p.NewRoleKmsClient = func(r, roleArn, endpoint string) (provider.KmsAPI, error) {
+			roleCred, err := auth.AssumeRole(cred, roleArn, r)
+			if err != nil {
+				return nil, err
+			}
+			return newKms(roleCred, r, endpoint)
+		}
+	}
+
+	if s.oosClient != nil {
+		p.OosClient = s.oosClient
+		p.NewOosClient = func(string) (provider.OosAPI, error) { return s.oosClient, nil }
+		p.NewRoleOosClient = func(string, string) (provider.OosAPI, error) { return s.oosClient, nil }
+	} else {
+		newOos := s.oosFactory()
+		if useOos {
+			if p.OosClient, err = newOos(cred, region); err != nil {
+				return err
+			}
+		}
+		p.NewOosClient = func(r string) (provider.OosAPI, error) {
+			return newOos(cred, r)
+		}
+		p.NewRoleOosClient = func(r, roleArn string) (provider.OosAPI, error) {
+			roleCred, err := auth.AssumeRole(cred, roleArn, r)
+			if err != nil {
+				return nil, err
+			}
+			return newOos(roleCred, r)
+		}
+	}
+	return nil
+}
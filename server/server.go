@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/auth"
 	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/provider"
@@ -20,19 +21,36 @@ import (
 	"k8s.io/klog/v2"
 	"os"
 	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Version filled in by Makefile durring build.
 var Version string
 
+// KMS endpoints by region, used instead of defaultKmsDomain (set from the command line).
+var RegionEndpointMap map[string]string
+
+// Skip the tmpfs check of mounts requesting requireTmpfs, where the file system
+// type of the mount directory can not be checked (set from the command line).
+var SkipTmpfsCheck bool
+
 const (
 	namespaceAttrib  = "csi.storage.k8s.io/pod.namespace"
 	acctAttrib       = "csi.storage.k8s.io/serviceAccount.name"
 	podnameAttrib    = "csi.storage.k8s.io/pod.name"
-	regionAttrib     = "region"          // The attribute name for the region in the SecretProviderClass
-	transAttrib      = "pathTranslation" // Path translation char
-	secProvAttrib    = "objects"         // The attributed used to pass the SecretProviderClass definition (with what to mount)
+	regionAttrib     = "region"               // The attribute name for the region in the SecretProviderClass
+	transAttrib      = "pathTranslation"      // Path translation char
+	stripAttrib      = "stripPrefix"          // Leading path removed from object names when deriving file names
+	dataMapAttrib    = "dataMapFile"          // Single file holding every secret as a data map
+	manifestAttrib   = "manifestFile"         // File listing the mounted files, "true" for the default name
+	tmpfsAttrib      = "requireTmpfs"         // Fail the mount unless the mount directory is memory backed
+	fileModeAttrib   = "fileMode"             // Default mode of the mounted files
+	umaskAttrib      = "umask"                // Bits cleared from the mode of every mounted file
+	fallbackAttrib   = "kmsFallbackEndpoints" // KMS endpoints tried when the endpoint of the region is unreachable
+	secProvAttrib    = "objects"              // The attributed used to pass the SecretProviderClass definition (with what to mount)
 	defaultKmsDomain = "kms-vpc.%s.aliyuncs.com"
 	defaultOosDomain = "oos-vpc.%s.aliyuncs.com"
 )
@@ -40,11 +58,31 @@ const (
 // A Secrets Store CSI Driver provider implementation for Alibaba Cloud Secrets Manager.
 type CSIDriverProviderServer struct {
 	*grpc.Server
+
+	// Optional pre-built clients used for every object of their type.
+	kmsClient provider.KmsAPI
+	oosClient provider.OosAPI
+
+	// Optional factories replacing newKmsClient and newOosClient.
+	newKmsClient KmsClientFactory
+	newOosClient OosClientFactory
+
+	// Processors registered with WithSecretProcessor, in order.
+	processors []provider.SecretProcessor
+
+	// Retry settings set with WithRetryPolicy, nil and zero for the defaults.
+	maxRetries    *int
+	retryInterval time.Duration
+	fetchTimeout  time.Duration
 }
 
 // Factory function to create the server to handle incoming mount requests.
-func NewServer() (srv *CSIDriverProviderServer, e error) {
-	return &CSIDriverProviderServer{}, nil
+func NewServer(opts ...ServerOption) (srv *CSIDriverProviderServer, e error) {
+	srv = &CSIDriverProviderServer{}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return srv, nil
 
 }
 
@@ -71,6 +109,62 @@ func (s *CSIDriverProviderServer) Mount(ctx context.Context, req *v1alpha1.Mount
 	podName := attrib[podnameAttrib]
 	region := attrib[regionAttrib]
 	translate := attrib[transAttrib]
+	dataMapFile := attrib[dataMapAttrib]
+	if len(dataMapFile) > 0 {
+		if err = provider.ValidateDataMapFile(dataMapFile); err != nil {
+			return nil, err
+		}
+	}
+
+	manifestFile := attrib[manifestAttrib]
+	if manifestFile == "true" {
+		manifestFile = provider.DefaultManifestFile
+	}
+	if len(manifestFile) > 0 && manifestFile != "false" {
+		if err = provider.ValidateManifestFile(manifestFile); err != nil {
+			return nil, err
+		}
+		if manifestFile == dataMapFile {
+			return nil, fmt.Errorf("%s and %s can not both be %s", manifestAttrib, dataMapAttrib, manifestFile)
+		}
+	} else {
+		manifestFile = ""
+	}
+
+	// Make sure the secrets will not reach persistent storage.
+	if len(attrib[tmpfsAttrib]) > 0 {
+		requireTmpfs, err := strconv.ParseBool(attrib[tmpfsAttrib])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s value %q, expected true or false", tmpfsAttrib, attrib[tmpfsAttrib])
+		}
+		if requireTmpfs && SkipTmpfsCheck {
+			klog.Warningf("skipping the tmpfs check of mount directory %s", mountDir)
+		} else if requireTmpfs {
+			if err = provider.VerifyTmpfs(mountDir); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var defaultFileMode *os.FileMode
+	if len(attrib[fileModeAttrib]) > 0 {
+		mode, err := provider.ParseFileMode(attrib[fileModeAttrib])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s: %+v", fileModeAttrib, err)
+		}
+		defaultFileMode = &mode
+	}
+	var umask os.FileMode
+	if len(attrib[umaskAttrib]) > 0 {
+		if umask, err = provider.ParseFileMode(attrib[umaskAttrib]); err != nil {
+			return nil, fmt.Errorf("Invalid %s: %+v", umaskAttrib, err)
+		}
+	}
+
+	fallbackEndpoints, err := provider.ParseEndpointList(attrib[fallbackAttrib])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %+v", fallbackAttrib, err)
+	}
 
 	// Lookup the region if one was not specified.
 	if len(region) <= 0 {
@@ -95,13 +189,13 @@ func (s *CSIDriverProviderServer) Mount(ctx context.Context, req *v1alpha1.Mount
 		return nil, fmt.Errorf("failed to unmarshal file permission, error: %+v", err)
 	}
 
-	// Get the pod's Alibaba Cloud creds.
-	cred, err := auth.GetKMSAuthCred(req.GetSecrets())
-	if err != nil {
-		return nil, err
-	}
 	var smProvider provider.SecretsManagerProvider
-	descriptors, err := provider.NewSecretObjectList(mountDir, translate, attrib[secProvAttrib])
+	podMeta := provider.PodMetadata{
+		Namespace:      nameSpace,
+		PodName:        podName,
+		ServiceAccount: svcAcct,
+	}
+	descriptors, err := provider.NewSecretObjectList(mountDir, translate, attrib[stripAttrib], attrib[secProvAttrib], podMeta)
 	if err != nil {
 		return nil, err
 	}
@@ -109,38 +203,67 @@ func (s *CSIDriverProviderServer) Mount(ctx context.Context, req *v1alpha1.Mount
 	objectTypeMap := make(map[string]bool)
 	for _, descriptor := range descriptors {
 		switch descriptor.ObjectType {
-		case "", provider.ObjectTypeKMS:
+		case "", provider.ObjectTypeKMS, provider.ObjectTypeDataKey:
 			objectTypeMap[provider.ObjectTypeKMS] = true
 		case provider.ObjectTypeOOS:
 			objectTypeMap[provider.ObjectTypeOOS] = true
+		case provider.ObjectTypeFile: // Read locally, without credentials
 		default:
 			return nil, fmt.Errorf("unsupported object type, only support %q and %q", provider.ObjectTypeKMS, provider.ObjectTypeOOS)
 		}
 	}
 
-	var kmsClient *kms.Client
-	var oosClient *oos.Client
-	if objectTypeMap[provider.ObjectTypeKMS] {
-		kmsClient, err = newKmsClient(cred, region)
-		if err != nil {
-			return nil, err
-		}
-	}
-	if objectTypeMap[provider.ObjectTypeOOS] {
-		oosClient, err = newOosClient(cred, region)
+	// Get the pod's Alibaba Cloud creds, unless injected clients serve every object.
+	var cred credentials.Credential
+	if objectTypeMap[provider.ObjectTypeKMS] && s.kmsClient == nil || objectTypeMap[provider.ObjectTypeOOS] && s.oosClient == nil {
+		cred, err = auth.GetKMSAuthCred(req.GetSecrets())
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	smProvider = provider.SecretsManagerProvider{
-		KmsClient: kmsClient,
-		OosClient: oosClient,
+		Region:               region,
+		DataMapFile:          dataMapFile,
+		ManifestFile:         manifestFile,
+		DefaultFileMode:      defaultFileMode,
+		FileUmask:            umask,
+		RegionEndpointMap:    RegionEndpointMap,
+		KmsFallbackEndpoints: fallbackEndpoints,
+		Processors:           s.processors,
+		MaxRetries:           s.maxRetries,
+		RetryInterval:        s.retryInterval,
+		FetchTimeout:         s.fetchTimeout,
+	}
+	if err = s.setClients(&smProvider, cred, region, objectTypeMap[provider.ObjectTypeKMS], objectTypeMap[provider.ObjectTypeOOS]); err != nil {
+		return nil, err
+	}
+	if cred != nil && provider.SharedCacheSize > 0 { // Values are only shared between mounts of the same identity
+		if accessKeyId, err := cred.GetAccessKeyId(); err == nil && accessKeyId != nil {
+			smProvider.CacheScope = *accessKeyId
+		}
+	}
+	defer smProvider.Close()
+	if klog.V(5).Enabled() {
+		klog.Infof("Resolved spec for pod %s in namespace %s:\n%s", podName, nameSpace, smProvider.DescribeSpec(descriptors))
+		if config, err := json.Marshal(smProvider.Config()); err == nil {
+			klog.Infof("Provider configuration for pod %s in namespace %s: %s", podName, nameSpace, config)
+		}
 	}
 
 	// Fetch all secrets before saving so we write nothing on failure.
 	var fetchedSecrets []*provider.SecretValue
-	secrets, err := smProvider.GetSecretValues(descriptors, curVerMap)
+	secrets, err := smProvider.GetSecretValues(ctx, descriptors, curVerMap)
+	logFetchStats(podName, nameSpace, smProvider.FetchStats())
+	var fetchErr *provider.FetchError
+	if errors.As(err, &fetchErr) {
+		if encoded, jsonErr := json.Marshal(fetchErr); jsonErr == nil {
+			klog.Errorf("Mount of pod %s in namespace %s failed: %s", podName, nameSpace, encoded)
+		}
+	}
+	if errors.Is(err, provider.ErrAccessDenied) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -148,11 +271,11 @@ func (s *CSIDriverProviderServer) Mount(ctx context.Context, req *v1alpha1.Mount
 
 	// Write out the secrets to the mount point after everything is fetched.
 	var files []*v1alpha1.File
-	for _, secret := range fetchedSecrets {
+	for _, file := range smProvider.SecretFiles(fetchedSecrets, filePermission) {
 		files = append(files, &v1alpha1.File{
-			Path:     secret.SecretObj.GetFileName(),
-			Contents: secret.Value,
-			Mode:     int32(filePermission),
+			Path:     file.Path,
+			Contents: file.Value,
+			Mode:     file.FileMode,
 		})
 	}
 	// Build the version response from the current version map and return it.
@@ -160,13 +283,29 @@ func (s *CSIDriverProviderServer) Mount(ctx context.Context, req *v1alpha1.Mount
 	for id := range curVerMap {
 		ov = append(ov, curVerMap[id])
 	}
+	sort.Slice(ov, func(i, j int) bool { return ov[i].Id < ov[j].Id }) // Stable response for identical mounts
 	return &v1alpha1.MountResponse{Files: files, ObjectVersion: ov}, nil
 
 }
 
-func newKmsClient(cred credentials.Credential, region string) (*kms.Client, error) {
+// Log a structured record per object of a mount, for SLO dashboards. The
+// records never hold secret material.
+func logFetchStats(podName, nameSpace string, stats []provider.ObjectFetchStat) {
+	if !klog.V(2).Enabled() {
+		return
+	}
+	for _, stat := range stats {
+		klog.V(2).InfoS("object fetch", "pod", podName, "namespace", nameSpace, "object", stat.ObjectName, "type", stat.ObjectType,
+			"source", stat.Source, "attempts", stat.Attempts, "duration", stat.Duration, "error", stat.Error)
+	}
+}
+
+// Build a KMS client for the region, using the endpoint when one is given.
+func newKmsClient(cred credentials.Credential, region, endpoint string) (*kms.Client, error) {
 	domain := defaultKmsDomain
-	if strings.Contains(domain, "%s") {
+	if len(endpoint) > 0 {
+		domain = endpoint
+	} else if strings.Contains(domain, "%s") {
 		domain = fmt.Sprintf(domain, region)
 	}
 	kmsClient, err := kms.NewClient(&openapi.Config{
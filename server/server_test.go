@@ -2,8 +2,18 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/AliyunContainerService/secrets-store-csi-driver-provider-alibaba-cloud/provider"
+	kms "github.com/alibabacloud-go/kms-20160120/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/credentials-go/credentials"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
 )
 
@@ -45,3 +55,270 @@ func TestMount(t *testing.T) {
 		})
 	}
 }
+
+type fakeKmsClient struct {
+	provider.KmsAPI // Only GetSecretValue is used
+	names           []string
+}
+
+func (c *fakeKmsClient) GetSecretValue(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+	c.names = append(c.names, tea.StringValue(request.SecretName))
+	return &kms.GetSecretValueResponse{Body: &kms.GetSecretValueResponseBody{
+		SecretData:     tea.String("value-" + tea.StringValue(request.SecretName)),
+		SecretDataType: tea.String("text"),
+		VersionId:      tea.String("v1"),
+	}}, nil
+}
+
+func setupMountTest(t *testing.T) {
+	oldLimiter := provider.LimiterInstance
+	t.Cleanup(func() { provider.LimiterInstance = oldLimiter })
+	provider.LimiterInstance.Kms.SecretPullLimiter = rate.NewLimiter(rate.Inf, 1)
+	provider.LimiterInstance.OOS.SecretPullLimiter = rate.NewLimiter(rate.Inf, 1)
+}
+
+func newMountRequest(objects string) *v1alpha1.MountRequest {
+	attributes, _ := json.Marshal(map[string]string{regionAttrib: "cn-hangzhou", secProvAttrib: objects})
+	return &v1alpha1.MountRequest{
+		Attributes: string(attributes),
+		TargetPath: "/mnt",
+		Permission: "420",
+	}
+}
+
+func TestMountInjectedClient(t *testing.T) {
+	setupMountTest(t)
+	client := &fakeKmsClient{}
+	testServer, err := NewServer(WithKmsClient(client), WithKmsClientFactory(func(credentials.Credential, string, string) (provider.KmsAPI, error) {
+		t.Fatal("the factory must not be used when a client is injected")
+		return nil, nil
+	}))
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	objects := `
+- objectName: "a"
+- objectName: "b"
+  region: "cn-beijing"
+  kmsEndpoint: "kms.custom.internal"
+`
+	response, err := testServer.Mount(context.TODO(), newMountRequest(objects))
+	if err != nil {
+		t.Fatalf("Mount() error = %v", err)
+	}
+	if strings.Join(client.names, ",") != "a,b" || len(response.Files) != 2 || string(response.Files[1].Contents) != "value-b" {
+		t.Errorf("expected the injected client to serve every object, fetched %v", client.names)
+	}
+}
+
+func TestMountClientFactory(t *testing.T) {
+	setupMountTest(t)
+	t.Setenv("ACCESS_KEY_ID", "ak")
+	t.Setenv("SECRET_ACCESS_KEY", "sk")
+	oldEndpoints := RegionEndpointMap
+	defer func() { RegionEndpointMap = oldEndpoints }()
+	RegionEndpointMap = map[string]string{"cn-hangzhou": "kms.hangzhou.internal"}
+
+	var endpoints []string
+	client := &fakeKmsClient{}
+	testServer, _ := NewServer(WithKmsClientFactory(func(cred credentials.Credential, region, endpoint string) (provider.KmsAPI, error) {
+		if cred == nil {
+			t.Error("expected the factory to get the pod credentials")
+		}
+		endpoints = append(endpoints, region+"="+endpoint)
+		return client, nil
+	}))
+	objects := `
+- objectName: "a"
+- objectName: "b"
+  kmsEndpoint: "kms.custom.internal"
+- objectName: "c"
+  region: "cn-beijing"
+`
+	if _, err := testServer.Mount(context.TODO(), newMountRequest(objects)); err != nil {
+		t.Fatalf("Mount() error = %v", err)
+	}
+	want := "cn-hangzhou=kms.hangzhou.internal,cn-hangzhou=kms.custom.internal,cn-beijing="
+	if strings.Join(endpoints, ",") != want {
+		t.Errorf("factory called for %v, want %s", endpoints, want)
+	}
+}
+
+func TestMountRequireTmpfs(t *testing.T) {
+	setupMountTest(t)
+	oldSkip := SkipTmpfsCheck
+	defer func() { SkipTmpfsCheck = oldSkip }()
+	testServer, _ := NewServer(WithKmsClient(&fakeKmsClient{}))
+
+	tests := []struct {
+		name    string
+		value   string
+		skip    bool
+		wantErr bool
+	}{
+		{"not-requested", "false", false, false},
+		{"invalid", "yes please", false, true},
+		{"not-tmpfs", "true", false, true}, // The mount directory does not exist
+		{"skipped", "true", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SkipTmpfsCheck = tt.skip
+			request := newMountRequest(`[{"objectName": "a"}]`)
+			attributes, _ := json.Marshal(map[string]string{regionAttrib: "cn-hangzhou", secProvAttrib: `[{"objectName": "a"}]`, tmpfsAttrib: tt.value})
+			request.Attributes = string(attributes)
+			request.TargetPath = "/nonexistent/mount"
+			if _, err := testServer.Mount(context.TODO(), request); (err != nil) != tt.wantErr {
+				t.Errorf("Mount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMountFileMode(t *testing.T) {
+	setupMountTest(t)
+	testServer, _ := NewServer(WithKmsClient(&fakeKmsClient{}))
+	tests := []struct {
+		name     string
+		fileMode string
+		umask    string
+		want     []int32
+		wantErr  bool
+	}{
+		{"driver-permission", "", "", []int32{0400, 0644}, false},
+		{"mount-default", "0440", "", []int32{0400, 0440}, false},
+		{"umask", "0660", "0027", []int32{0400, 0640}, false},
+		{"invalid-mode", "rw", "", nil, true},
+		{"invalid-umask", "", "9", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newMountRequest("")
+			attributes, _ := json.Marshal(map[string]string{
+				regionAttrib:   "cn-hangzhou",
+				secProvAttrib:  `[{"objectName": "a", "fileMode": "0400"}, {"objectName": "b"}]`,
+				fileModeAttrib: tt.fileMode,
+				umaskAttrib:    tt.umask,
+			})
+			request.Attributes = string(attributes)
+			response, err := testServer.Mount(context.TODO(), request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Mount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for i, file := range response.Files {
+				if file.Mode != tt.want[i] {
+					t.Errorf("mode of %s = %o, want %o", file.Path, file.Mode, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMountManifestFile(t *testing.T) {
+	setupMountTest(t)
+	testServer, _ := NewServer(WithKmsClient(&fakeKmsClient{}))
+	tests := []struct {
+		name     string
+		manifest string
+		dataMap  string
+		wantFile string
+		wantErr  bool
+	}{
+		{"off", "", "", "", false},
+		{"disabled", "false", "", "", false},
+		{"default-name", "true", "", provider.DefaultManifestFile, false},
+		{"custom-name", "index.json", "", "index.json", false},
+		{"with-data-map", "true", "secrets.json", provider.DefaultManifestFile, false},
+		{"path", "../index.json", "", "", true},
+		{"same-as-data-map", "secrets.json", "secrets.json", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newMountRequest("")
+			attributes, _ := json.Marshal(map[string]string{
+				regionAttrib:   "cn-hangzhou",
+				secProvAttrib:  `[{"objectName": "a"}, {"objectName": "b"}]`,
+				manifestAttrib: tt.manifest,
+				dataMapAttrib:  tt.dataMap,
+			})
+			request.Attributes = string(attributes)
+			response, err := testServer.Mount(context.TODO(), request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Mount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			last := response.Files[len(response.Files)-1].Path
+			if len(tt.wantFile) == 0 && (last == provider.DefaultManifestFile || last == "index.json") {
+				t.Errorf("unexpected manifest file %s", last)
+			}
+			if len(tt.wantFile) > 0 && last != tt.wantFile {
+				t.Errorf("last file = %s, want the manifest %s", last, tt.wantFile)
+			}
+		})
+	}
+}
+
+type suffixProcessor string
+
+func (s suffixProcessor) Process(_ context.Context, secret *provider.SecretValue) (*provider.SecretValue, error) {
+	return &provider.SecretValue{Value: append(append([]byte{}, secret.Value...), s...)}, nil
+}
+
+func TestMountSecretProcessor(t *testing.T) {
+	setupMountTest(t)
+	testServer, _ := NewServer(WithKmsClient(&fakeKmsClient{}), WithSecretProcessor(suffixProcessor("-1")), WithSecretProcessor(suffixProcessor("-2")))
+	response, err := testServer.Mount(context.TODO(), newMountRequest(`[{"objectName": "a"}]`))
+	if err != nil {
+		t.Fatalf("Mount() error = %v", err)
+	}
+	if len(response.Files) != 1 || string(response.Files[0].Contents) != "value-a-1-2" {
+		t.Errorf("expected the processors to run in registration order, got %q", response.Files[0].Contents)
+	}
+}
+
+type deniedKmsClient struct {
+	provider.KmsAPI // Only GetSecretValue is used
+}
+
+func (c *deniedKmsClient) GetSecretValue(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+	return nil, &tea.SDKError{Code: tea.String("Forbidden.NoPermission")}
+}
+
+func TestMountAccessDenied(t *testing.T) {
+	setupMountTest(t)
+	testServer, _ := NewServer(WithKmsClient(&deniedKmsClient{}))
+	_, err := testServer.Mount(context.TODO(), newMountRequest(`[{"objectName": "a"}]`))
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected a PermissionDenied status, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "kms:GetSecretValue") {
+		t.Errorf("expected the denied action in the error, got %v", err)
+	}
+}
+
+type throttledKmsClient struct {
+	provider.KmsAPI // Only GetSecretValue is used
+	calls           int
+}
+
+func (c *throttledKmsClient) GetSecretValue(request *kms.GetSecretValueRequest) (*kms.GetSecretValueResponse, error) {
+	c.calls++
+	return nil, &tea.SDKError{Code: tea.String(provider.REJECTED_THROTTLING)}
+}
+
+func TestMountRetryPolicy(t *testing.T) {
+	setupMountTest(t)
+	client := &throttledKmsClient{}
+	testServer, _ := NewServer(WithKmsClient(client), WithRetryPolicy(3, time.Millisecond, time.Minute))
+	if _, err := testServer.Mount(context.TODO(), newMountRequest(`[{"objectName": "a"}]`)); err == nil {
+		t.Fatal("expected throttled fetches to fail the mount")
+	}
+	if client.calls != 4 {
+		t.Errorf("expected the request to be retried 3 times, got %d calls", client.calls)
+	}
+}
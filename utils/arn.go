@@ -57,6 +57,24 @@ func ParseARN(arn string) (ARN, error) {
 	}, nil
 }
 
+// ResourceType returns the type of the resource, the part of Resource before
+// the first "/", e.g. secret for secret/test. It is empty when Resource has no type.
+func (arn ARN) ResourceType() string {
+	if i := strings.Index(arn.Resource, "/"); i >= 0 {
+		return arn.Resource[:i]
+	}
+	return ""
+}
+
+// ResourceName returns the name of the resource, the part of Resource after
+// the first "/", e.g. test for secret/test, or the whole Resource without a type.
+func (arn ARN) ResourceName() string {
+	if i := strings.Index(arn.Resource, "/"); i >= 0 {
+		return arn.Resource[i+1:]
+	}
+	return arn.Resource
+}
+
 // String returns the canonical representation of the ARN, only for testing
 func (arn ARN) string() string {
 	if arn.Partition == "" {
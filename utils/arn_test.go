@@ -40,3 +40,61 @@ func TestParseARN(t *testing.T) {
 		})
 	}
 }
+
+func TestARNResource(t *testing.T) {
+	tests := []struct {
+		arn          string
+		wantType     string
+		wantName     string
+		wantAccount  string
+		wantRegion   string
+		wantParseErr bool
+	}{
+		{"acs:kms:cn-hongkong:12345678:secret/test", "secret", "test", "12345678", "cn-hongkong", false},
+		{"acs:kms:cn-hongkong:12345678:secret/path/to/test", "secret", "path/to/test", "12345678", "cn-hongkong", false},
+		{"acs:ram::12345678:role/defaultrole", "role", "defaultrole", "12345678", "", false},
+		{"acs:kms:cn-hongkong:12345678:key", "", "key", "12345678", "cn-hongkong", false},
+		{"acs:kms:cn-hongkong:12345678:secret/test:v1", "secret", "test:v1", "12345678", "cn-hongkong", false},
+		{"acs:kms:cn-hongkong:12345678", "", "", "", "", true},
+		{"arn:acs:kms:cn-hongkong:12345678:secret/test", "", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arn, func(t *testing.T) {
+			got, err := ParseARN(tt.arn)
+			if (err != nil) != tt.wantParseErr {
+				t.Fatalf("ParseARN() error = %v, wantErr %v", err, tt.wantParseErr)
+			}
+			if got.ResourceType() != tt.wantType || got.ResourceName() != tt.wantName {
+				t.Errorf("resource = %q %q, want %q %q", got.ResourceType(), got.ResourceName(), tt.wantType, tt.wantName)
+			}
+			if got.AccountID != tt.wantAccount || got.Region != tt.wantRegion {
+				t.Errorf("account and region = %q %q, want %q %q", got.AccountID, got.Region, tt.wantAccount, tt.wantRegion)
+			}
+		})
+	}
+}
+
+func FuzzParseARN(f *testing.F) {
+	for _, seed := range []string{
+		"acs:ram::123456789012:role/defaultrole",
+		"acs:kms:cn-hongkong:12345678:secret/path/to/test:v1",
+		"acs:kms:cn-hongkong:12345678",
+		"acs:::::",
+		"acs:kms:é:\x00:secret/\xff",
+		"arn:acs:kms",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		arn, err := ParseARN(s)
+		if err != nil {
+			return
+		}
+		if arn.string() != s {
+			t.Errorf("ParseARN(%q) round trips to %q", s, arn.string())
+		}
+		if name := arn.ResourceName(); len(arn.ResourceType()) > 0 && arn.ResourceType()+"/"+name != arn.Resource {
+			t.Errorf("ParseARN(%q) resource %q splits into %q and %q", s, arn.Resource, arn.ResourceType(), name)
+		}
+	})
+}